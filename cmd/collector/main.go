@@ -1,11 +1,13 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"syslog-visualizer/internal/collector"
 	"syslog-visualizer/internal/framing"
@@ -66,8 +68,10 @@ func main() {
 		log.Printf("Collector error: %v", err)
 	}
 
-	// Stop collector
-	if err := col.Stop(); err != nil {
+	// Stop collector, letting messages in flight be stored
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := col.Stop(ctx); err != nil {
 		log.Printf("Error stopping collector: %v", err)
 	}
 
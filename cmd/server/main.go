@@ -5,19 +5,30 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"syslog-visualizer/internal/auth"
 	"syslog-visualizer/internal/collector"
-	"syslog-visualizer/internal/framing"
+	"syslog-visualizer/internal/config"
+	"syslog-visualizer/internal/dedup"
+	"syslog-visualizer/internal/diskqueue"
+	"syslog-visualizer/internal/enrich"
+	"syslog-visualizer/internal/forward"
+	"syslog-visualizer/internal/grok"
 	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/internal/redact"
+	"syslog-visualizer/internal/routing"
 	"syslog-visualizer/internal/storage"
 )
 
@@ -27,17 +38,66 @@ type RetentionConfig struct {
 	Enabled         bool
 }
 
+// retentionPolicy holds the retention configuration in effect, which a reload can replace
+type retentionPolicy struct {
+	current atomic.Pointer[RetentionConfig]
+	changed chan struct{}
+}
+
+func newRetentionPolicy(cfg *RetentionConfig) *retentionPolicy {
+	p := &retentionPolicy{changed: make(chan struct{}, 1)}
+	p.current.Store(cfg)
+	return p
+}
+
+// Get returns the retention configuration in effect
+func (p *retentionPolicy) Get() *RetentionConfig {
+	return p.current.Load()
+}
+
+// Set replaces the retention configuration and wakes up the cleanup loop
+func (p *retentionPolicy) Set(cfg *RetentionConfig) {
+	p.current.Store(cfg)
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
 func main() {
 	retentionPeriod := flag.String("retention", getEnv("RETENTION_PERIOD", "7d"), "Data retention period (e.g., 24h, 7d, 30d)")
 	cleanupInterval := flag.String("cleanup-interval", getEnv("CLEANUP_INTERVAL", "1h"), "Cleanup interval (e.g., 30m, 1h, 6h)")
 	enableRetention := flag.Bool("enable-retention", getEnvBool("ENABLE_RETENTION", true), "Enable automatic data cleanup")
 	enableAuth := flag.Bool("enable-auth", getEnvBool("ENABLE_AUTH", false), "Enable authentication")
 	authUsers := flag.String("auth-users", getEnv("AUTH_USERS", ""), "Comma-separated list of username:password pairs (e.g., admin:password123,user:pass456)")
+	configFile := flag.String("config", getEnv("CONFIG_FILE", ""), "Path to the YAML configuration file (listeners)")
+	spoolDir := flag.String("spool-dir", getEnv("SPOOL_DIR", "./data/spool"), "Directory where messages are spooled while storage is unavailable (empty to disable)")
+	spoolMaxSize := flag.Int("spool-max-size", getEnvInt("SPOOL_MAX_SIZE", 1024), "Maximum spool size in MB")
+	spoolSync := flag.String("spool-sync", getEnv("SPOOL_SYNC", "interval"), "Spool fsync policy: always, interval or never")
+	extractFields := flag.Bool("extract-fields", getEnvBool("EXTRACT_FIELDS", true), "Extract JSON and key=value payloads from messages into fields")
+	grokRulesFile := flag.String("grok-rules-file", getEnv("GROK_RULES_FILE", "./data/grok_rules.json"), "File where grok rules created through the API are stored")
+	tokenFile := flag.String("token-file", getEnv("API_TOKEN_FILE", "./data/tokens.json"), "File where named API tokens are stored (hashed)")
+	shutdownTimeout := flag.Duration("shutdown-timeout", getEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second), "How long to wait on shutdown for received messages to be stored")
 	flag.Parse()
 
 	fmt.Println("Syslog Visualizer starting...")
 
-	retentionCfg, err := parseRetentionConfig(*retentionPeriod, *cleanupInterval, *enableRetention)
+	cfg := config.Default()
+	if *configFile != "" {
+		var err error
+		cfg, err = config.Load(*configFile)
+		if err != nil {
+			log.Fatalf("Failed to load configuration: %v", err)
+		}
+		log.Printf("Configuration loaded from %s", *configFile)
+	}
+
+	retentionDefaults := config.RetentionConfig{
+		Period:          *retentionPeriod,
+		CleanupInterval: *cleanupInterval,
+		Enabled:         enableRetention,
+	}
+	retentionCfg, err := resolveRetention(cfg.Retention, retentionDefaults)
 	if err != nil {
 		log.Fatalf("Failed to parse retention configuration: %v", err)
 	}
@@ -52,28 +112,50 @@ func main() {
 	authManager := auth.NewAuthManager(*enableAuth)
 
 	if *enableAuth {
-		if *authUsers == "" {
-			log.Fatal("ERROR: Authentication enabled but no users configured. Use -auth-users flag or AUTH_USERS env variable")
+		if *authUsers == "" && len(cfg.Auth.Users) == 0 {
+			log.Fatal("ERROR: Authentication enabled but no users configured. Use -auth-users flag, AUTH_USERS env variable or auth.users in the configuration file")
 		}
 
-		userPairs := strings.Split(*authUsers, ",")
-		for _, pair := range userPairs {
-			parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
-			if len(parts) != 2 {
-				log.Fatalf("ERROR: Invalid user format: %s (expected username:password)", pair)
-			}
+		var usernames []string
+		if *authUsers != "" {
+			for _, pair := range strings.Split(*authUsers, ",") {
+				parts := strings.SplitN(strings.TrimSpace(pair), ":", 2)
+				if len(parts) != 2 {
+					log.Fatalf("ERROR: Invalid user format: %s (expected username:password)", pair)
+				}
 
-			username := strings.TrimSpace(parts[0])
-			password := strings.TrimSpace(parts[1])
+				username := strings.TrimSpace(parts[0])
+				password := strings.TrimSpace(parts[1])
 
-			if err := authManager.AddUser(username, password); err != nil {
-				log.Fatalf("ERROR: Failed to add user %s: %v", username, err)
+				if err := authManager.AddUser(username, password); err != nil {
+					log.Fatalf("ERROR: Failed to add user %s: %v", username, err)
+				}
+				usernames = append(usernames, username)
 			}
+		}
+		// Users of the configuration file are swapped on reload
+		if err := authManager.SetUsers(cfg.Auth.Users); err != nil {
+			log.Fatalf("ERROR: Failed to add users from the configuration file: %v", err)
+		}
+		for _, u := range cfg.Auth.Users {
+			usernames = append(usernames, u.Username)
+		}
 
-			apiToken, _ := authManager.GetAPIToken(username)
+		for _, username := range usernames {
+			apiToken, err := authManager.GetAPIToken(username)
+			if err != nil {
+				log.Fatalf("ERROR: Failed to create API token for %s: %v", username, err)
+			}
 			log.Printf("User created: %s (API Token: %s)", username, apiToken)
 		}
 
+		if *tokenFile != "" {
+			if err := authManager.SetTokenFile(*tokenFile); err != nil {
+				log.Fatalf("ERROR: Failed to load API tokens: %v", err)
+			}
+			log.Printf("API tokens stored in %s", *tokenFile)
+		}
+
 		log.Println("Authentication enabled")
 		go startSessionCleanup(authManager)
 	} else {
@@ -81,14 +163,91 @@ func main() {
 	}
 
 	dbPath := getEnv("DB_PATH", "./data/syslog.db")
-	store, err := storage.NewSQLiteStorage(dbPath)
+	sqliteStore, err := storage.NewSQLiteStorage(dbPath)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
-	defer store.Close()
 	log.Printf("Database initialized: %s", dbPath)
 
+	var store storage.Storage = sqliteStore
+	var spool *storage.SpoolStorage
+	if *spoolDir != "" {
+		spool, err = storage.NewSpoolStorage(sqliteStore, diskqueue.Options{
+			Dir:        *spoolDir,
+			MaxSize:    int64(*spoolMaxSize) << 20,
+			SyncPolicy: diskqueue.SyncPolicy(*spoolSync),
+		})
+		if err != nil {
+			log.Fatalf("Failed to initialize spool: %v", err)
+		}
+		store = spool
+		log.Printf("Spool enabled: %s (max %d MB, sync %s)", *spoolDir, *spoolMaxSize, *spoolSync)
+	} else {
+		log.Println("WARNING: Spool disabled: messages are lost while storage is unavailable")
+	}
+
+	forwarder, err := forward.New(cfg.Outputs)
+	if err != nil {
+		log.Fatalf("Failed to initialize forwarding outputs: %v", err)
+	}
+
+	grokRules, err := grok.NewRules(cfg.Grok)
+	if err != nil {
+		log.Fatalf("Failed to compile grok rules: %v", err)
+	}
+	if err := grokRules.SetFile(*grokRulesFile); err != nil {
+		log.Fatalf("Failed to load grok rules: %v", err)
+	}
+
+	redactor, err := redact.New(cfg.Redaction)
+	if err != nil {
+		log.Fatalf("Failed to compile redaction rules: %v", err)
+	}
+	if len(cfg.Redaction.Rules) > 0 {
+		log.Printf("Redaction enabled: %d rules", len(cfg.Redaction.Rules))
+	}
+
+	router, err := routing.New(cfg.Routing)
+	if err != nil {
+		log.Fatalf("Failed to compile routing rules: %v", err)
+	}
+	if len(cfg.Routing.Rules) > 0 {
+		log.Printf("Routing enabled: %d rules", len(cfg.Routing.Rules))
+	}
+
+	enricher, err := enrich.New(cfg.Enrichment)
+	if err != nil {
+		log.Fatalf("Failed to load enrichment sources: %v", err)
+	}
+	if cfg.Enrichment.Enabled() {
+		sqliteStore.SetFacetFields(enrich.FacetFields)
+		stats := enricher.Stats()
+		geoip := "disabled"
+		if stats.GeoIP != "" {
+			geoip = stats.GeoIP
+		}
+		log.Printf("Enrichment enabled: %d assets, GeoIP %s", stats.Assets, geoip)
+	}
+
+	// Identical messages are forwarded as they come, but stored once per window
+	deduplicator := dedup.New(cfg.Dedup, store.Store, sqliteStore.AddRepeats)
+	if cfg.Dedup.Window > 0 {
+		log.Printf("Deduplication enabled: identical messages stored once per %v", cfg.Dedup.Window)
+	}
+
 	handler := func(msg *parser.SyslogMessage) error {
+		grokRules.Apply(msg)
+		if *extractFields {
+			parser.ExtractFields(msg)
+		}
+		// Routing rules may drop, rewrite or route the message before it is stored
+		decision := router.Apply(msg)
+		if decision.Drop {
+			return nil
+		}
+		enricher.Apply(msg)
+		// Redact before anything leaves the handler, the log included
+		redactor.Apply(msg)
 		log.Printf("[%s] %s %s[%s]: %s",
 			msg.SeverityName(),
 			msg.Hostname,
@@ -96,24 +255,68 @@ func main() {
 			msg.PID,
 			msg.Message,
 		)
-		return store.Store(msg)
+		if decision.Targets == nil {
+			forwarder.Forward(msg)
+		} else {
+			forwarder.ForwardTo(msg, decision.Targets)
+		}
+		if !decision.Sends(routing.StorageTarget) {
+			return nil
+		}
+		return deduplicator.Store(msg)
 	}
 
-	collectorCfg := collector.Config{
-		Address:       ":514",
-		Protocol:      "udp",
-		FramingMethod: framing.NonTransparent,
-		Handler:       handler,
+	rejectHandler := func(reject *collector.Reject) {
+		err := sqliteStore.StoreReject(&storage.RejectedMessage{
+			ReceivedAt: reject.ReceivedAt,
+			Listener:   reject.Listener,
+			Protocol:   reject.Protocol,
+			Source:     reject.Source,
+			Raw:        redactor.Raw(string(reject.Raw)),
+			Error:      reject.Error,
+		})
+		if err != nil {
+			log.Printf("Failed to store rejected message from %s: %v", reject.Source, err)
+		}
 	}
 
-	col, err := collector.New(collectorCfg)
+	timezones, err := parser.NewTimezoneMap(cfg.Time.DefaultTimezone, cfg.Time.Timezones)
 	if err != nil {
-		log.Fatalf("Failed to create collector: %v", err)
+		log.Fatalf("Failed to load timezones: %v", err)
+	}
+
+	listeners := &listenerSet{}
+	for _, listener := range cfg.Collector.Listeners {
+		col, err := newCollector(listener, cfg.Time, timezones, handler, rejectHandler)
+		if err != nil {
+			log.Fatalf("Failed to create collector %s: %v", listener.Name, err)
+		}
+		listeners.put(col)
+	}
+
+	retention := newRetentionPolicy(retentionCfg)
+	reloader := &reloader{
+		cfg:               cfg,
+		file:              *configFile,
+		timeout:           *shutdownTimeout,
+		retentionDefaults: retentionDefaults,
+		retention:         retention,
+		grokRules:         grokRules,
+		deduplicator:      deduplicator,
+		redactor:          redactor,
+		router:            router,
+		enricher:          enricher,
+		store:             sqliteStore,
+		authManager:       authManager,
+		listeners:         listeners,
+		handler:           handler,
+		rejectHandler:     rejectHandler,
 	}
+	reloader.setTime(timezones, cfg.Time)
 
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/api/health", handleHealth)
+	mux.HandleFunc("/api/health", handleHealth(forwarder, spool, deduplicator, redactor, router, enricher, listeners))
 	mux.HandleFunc("/api/auth/login", handleLogin(authManager))
 	mux.HandleFunc("/api/auth/logout", handleLogout(authManager))
 
@@ -122,11 +325,33 @@ func main() {
 	protectedMux.HandleFunc("/api/filter-options", handleGetFilterOptions(store))
 	protectedMux.HandleFunc("/api/timeline", handleGetTimeline(store))
 	protectedMux.HandleFunc("/api/export", handleExport(store))
-
-	mux.Handle("/api/syslogs", authManager.Middleware(protectedMux))
-	mux.Handle("/api/filter-options", authManager.Middleware(protectedMux))
-	mux.Handle("/api/timeline", authManager.Middleware(protectedMux))
-	mux.Handle("/api/export", authManager.Middleware(protectedMux))
+	protectedMux.HandleFunc("/api/ingest", reloader.handleIngest)
+	protectedMux.HandleFunc("/api/rejects", handleGetRejects(sqliteStore))
+	protectedMux.HandleFunc("/api/rejects/summary", handleRejectSummary(sqliteStore))
+	protectedMux.HandleFunc("/api/rejects/reprocess", handleReprocessRejects(sqliteStore, listeners, handler))
+	protectedMux.HandleFunc("/api/rejects/", handleDeleteReject(sqliteStore))
+	protectedMux.HandleFunc("/api/parse/test", handleParseTest(grokRules, listeners, *extractFields))
+	protectedMux.HandleFunc("/api/parse/rules", handleGrokRules(grokRules))
+	protectedMux.HandleFunc("/api/parse/rules/", handleDeleteGrokRule(grokRules))
+	protectedMux.HandleFunc("/api/tokens", handleTokens(authManager))
+	protectedMux.HandleFunc("/api/tokens/", handleRevokeToken(authManager))
+	protectedMux.HandleFunc("/api/admin/reload", handleReload(reloader))
+
+	mux.Handle("/api/syslogs", authManager.RequireScope(auth.ScopeRead, protectedMux))
+	mux.Handle("/api/filter-options", authManager.RequireScope(auth.ScopeRead, protectedMux))
+	mux.Handle("/api/timeline", authManager.RequireScope(auth.ScopeRead, protectedMux))
+	mux.Handle("/api/export", authManager.RequireScope(auth.ScopeExport, protectedMux))
+	mux.Handle("/api/ingest", authManager.RequireScope(auth.ScopeIngest, protectedMux))
+	mux.Handle("/api/rejects", authManager.RequireScope(auth.ScopeRead, protectedMux))
+	mux.Handle("/api/rejects/summary", authManager.RequireScope(auth.ScopeRead, protectedMux))
+	mux.Handle("/api/rejects/reprocess", authManager.RequireScope(auth.ScopeAdmin, protectedMux))
+	mux.Handle("/api/rejects/", authManager.RequireScope(auth.ScopeAdmin, protectedMux))
+	mux.Handle("/api/parse/test", authManager.RequireScope(auth.ScopeRead, protectedMux))
+	mux.Handle("/api/parse/rules", authManager.RequireScope(auth.ScopeAdmin, protectedMux))
+	mux.Handle("/api/parse/rules/", authManager.RequireScope(auth.ScopeAdmin, protectedMux))
+	mux.Handle("/api/tokens", authManager.RequireScope(auth.ScopeAdmin, protectedMux))
+	mux.Handle("/api/tokens/", authManager.RequireScope(auth.ScopeAdmin, protectedMux))
+	mux.Handle("/api/admin/reload", authManager.RequireScope(auth.ScopeAdmin, protectedMux))
 
 	apiHandler := enableCORS(mux)
 
@@ -139,19 +364,30 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
-	cleanupDoneChan := make(chan struct{})
-	if retentionCfg.Enabled {
-		go startDataRetentionCleanup(store, retentionCfg, cleanupDoneChan)
-	}
-
-	collectorErrChan := make(chan error, 1)
+	// SIGHUP reloads the configuration file
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
 	go func() {
-		log.Println("Starting syslog collector...")
-		if err := col.Start(); err != nil {
-			collectorErrChan <- fmt.Errorf("collector error: %w", err)
+		for range hupChan {
+			log.Println("SIGHUP received, reloading configuration")
+			reloader.Reload()
 		}
 	}()
 
+	cleanupDoneChan := make(chan struct{})
+	go startDataRetentionCleanup(store, retention, cleanupDoneChan)
+
+	collectors := listeners.List()
+	collectorErrChan := make(chan error, len(collectors))
+	for _, col := range collectors {
+		go func(col *collector.Collector) {
+			log.Printf("Starting syslog collector %s...", col.Name())
+			if err := col.Start(); err != nil {
+				collectorErrChan <- fmt.Errorf("collector %s error: %w", col.Name(), err)
+			}
+		}(col)
+	}
+
 	apiErrChan := make(chan error, 1)
 	go func() {
 		log.Printf("Starting API server on %s", apiPort)
@@ -161,7 +397,9 @@ func main() {
 	}()
 
 	log.Println("Syslog Visualizer is running")
-	log.Printf("  - Collector listening on :514 (UDP)")
+	for _, listener := range cfg.Collector.Listeners {
+		log.Printf("  - Collector listening on %s (%s)", listener.Address, strings.ToUpper(listener.Protocol))
+	}
 	log.Printf("  - API server listening on %s", apiPort)
 	log.Println("Press Ctrl+C to stop")
 
@@ -176,23 +414,77 @@ func main() {
 
 	log.Println("Shutting down...")
 
-	if retentionCfg.Enabled {
-		close(cleanupDoneChan)
+	signal.Stop(hupChan)
+	reloader.close()
+	close(cleanupDoneChan)
+
+	// Stop the collectors first, the API server included since HTTP ingestion and
+	// reprocessing use the same handler, so that the messages they received are stored
+	// and forwarded, then flush the forwarding queues, and close the storage last
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+	apiStopped := make(chan error, 1)
+	go func() {
+		apiStopped <- apiServer.Shutdown(ctx)
+	}()
+	stopCollectors(listeners.List(), *shutdownTimeout)
+	if err := <-apiStopped; err != nil {
+		log.Printf("Error stopping API server: %v", err)
 	}
+	deduplicator.Close()
 
-	if err := col.Stop(); err != nil {
-		log.Printf("Error stopping collector: %v", err)
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer flushCancel()
+	// Each output logs the messages it could not send
+	if _, err := forwarder.Stop(flushCtx); err != nil {
+		log.Printf("Error stopping forwarding outputs: %v", err)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := apiServer.Shutdown(ctx); err != nil {
-		log.Printf("Error stopping API server: %v", err)
+	if err := store.Close(); err != nil {
+		log.Printf("Error closing storage: %v", err)
 	}
 
 	log.Println("Shutdown complete")
 }
 
+// newCollector creates the collector of a listener
+func newCollector(listener config.ListenerConfig, timeCfg config.TimeConfig, timezones *parser.TimezoneMap, handler collector.MessageHandler, rejectHandler collector.RejectHandler) (*collector.Collector, error) {
+	socketMode, _ := listener.FileMode()
+	return collector.New(collector.Config{
+		Name:           listener.Name,
+		Address:        listener.Address,
+		Protocol:       listener.Protocol,
+		FramingMethod:  listener.FramingMethod(),
+		Handler:        handler,
+		RejectHandler:  rejectHandler,
+		MaxMessageSize: listener.MaxMessageSize,
+		SocketMode:     socketMode,
+		Lenient:        listener.Lenient,
+
+		Timezones:        timezones,
+		TrustReceiveTime: timeCfg.TrustReceiveTime,
+		MaxClockSkew:     timeCfg.MaxClockSkew,
+
+		MaxConnections:          listener.MaxConnections,
+		MaxConnectionsPerSource: listener.MaxConnectionsPerSource,
+		IdleTimeout:             listener.IdleTimeout,
+		ReadTimeout:             listener.ReadTimeout,
+		RateLimit:               listener.RateLimit,
+
+		Allow:        listener.Allow,
+		Deny:         listener.Deny,
+		RecordDenied: listener.RecordDenied,
+		Hosts:        listener.Hosts,
+
+		ProxyProtocol:  listener.ProxyProtocol,
+		TrustedProxies: listener.TrustedProxies,
+
+		Readers:       listener.Readers,
+		Workers:       listener.Workers,
+		ReceiveBuffer: listener.ReceiveBuffer,
+	})
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -200,6 +492,24 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if n, err := strconv.Atoi(value); err == nil {
+			return n
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if b, err := strconv.ParseBool(value); err == nil {
@@ -209,6 +519,21 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// resolveRetention applies the retention section of the configuration file over the
+// command-line settings
+func resolveRetention(file, defaults config.RetentionConfig) (*RetentionConfig, error) {
+	if file.Period != "" {
+		defaults.Period = file.Period
+	}
+	if file.CleanupInterval != "" {
+		defaults.CleanupInterval = file.CleanupInterval
+	}
+	if file.Enabled != nil {
+		defaults.Enabled = file.Enabled
+	}
+	return parseRetentionConfig(defaults.Period, defaults.CleanupInterval, *defaults.Enabled)
+}
+
 func parseRetentionConfig(retentionStr, cleanupStr string, enabled bool) (*RetentionConfig, error) {
 	retention, err := parseDuration(retentionStr)
 	if err != nil {
@@ -238,17 +563,34 @@ func parseDuration(s string) (time.Duration, error) {
 	return time.ParseDuration(s)
 }
 
-func startDataRetentionCleanup(store storage.Storage, cfg *RetentionConfig, done <-chan struct{}) {
-	ticker := time.NewTicker(cfg.CleanupInterval)
-	defer ticker.Stop()
-
-	runCleanup(store, cfg.RetentionPeriod)
+func startDataRetentionCleanup(store storage.Storage, policy *retentionPolicy, done <-chan struct{}) {
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+	schedule := func() {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tick = nil, nil
+		}
+		cfg := policy.Get()
+		if cfg.Enabled {
+			ticker = time.NewTicker(cfg.CleanupInterval)
+			tick = ticker.C
+			runCleanup(store, cfg.RetentionPeriod)
+		}
+	}
+	schedule()
 
 	for {
 		select {
-		case <-ticker.C:
-			runCleanup(store, cfg.RetentionPeriod)
+		case <-tick:
+			runCleanup(store, policy.Get().RetentionPeriod)
+		case <-policy.changed:
+			// A new policy applies at once, e.g. a shorter retention period
+			schedule()
 		case <-done:
+			if ticker != nil {
+				ticker.Stop()
+			}
 			log.Println("Data retention cleanup stopped")
 			return
 		}
@@ -273,14 +615,17 @@ func startSessionCleanup(authManager *auth.AuthManager) {
 
 	for range ticker.C {
 		authManager.CleanupExpiredSessions()
+		if err := authManager.FlushTokens(); err != nil {
+			log.Printf("Error saving API tokens: %v", err)
+		}
 	}
 }
 
 func enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Encoding, Authorization")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -291,12 +636,35 @@ func enableCORS(next http.Handler) http.Handler {
 	})
 }
 
-func handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "healthy",
-		"time":   time.Now().Format(time.RFC3339),
-	})
+func handleHealth(forwarder *forward.Forwarder, spool *storage.SpoolStorage, deduplicator *dedup.Deduplicator, redactor *redact.Redactor, router *routing.Router, enricher *enrich.Enricher, collectors *listenerSet) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		running := collectors.List()
+		listeners := make([]collector.Stats, 0, len(running))
+		for _, col := range running {
+			listeners = append(listeners, col.Stats())
+		}
+
+		response := map[string]interface{}{
+			"status":     "healthy",
+			"time":       time.Now().Format(time.RFC3339),
+			"listeners":  listeners,
+			"outputs":    forwarder.Stats(),
+			"dedup":      deduplicator.Stats(),
+			"redactions": redactor.Counts(),
+			"routing":    router.Stats(),
+			"enrichment": enricher.Stats(),
+		}
+		if spool != nil {
+			stats := spool.Stats()
+			response["spool"] = stats
+			if stats.Depth > 0 {
+				response["status"] = "degraded"
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+	}
 }
 
 func handleGetSyslogs(store storage.Storage) http.HandlerFunc {
@@ -368,6 +736,19 @@ func handleGetSyslogs(store storage.Storage) http.HandlerFunc {
 			}
 		}
 
+		if minRepeatsStr := queryParams.Get("min_repeats"); minRepeatsStr != "" {
+			if minRepeats, err := strconv.Atoi(minRepeatsStr); err == nil && minRepeats > 0 {
+				filters.MinRepeats = minRepeats
+			}
+		}
+
+		fieldFilters, err := parseFieldFilters(queryParams)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filters.Fields = fieldFilters
+
 		messages, totalCount, err := store.QueryWithCount(filters)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -399,6 +780,35 @@ func parseIntSlice(s string) []int {
 	return result
 }
 
+// parseFieldFilters collects the "field.<name><op><value>" query parameters
+// The query string splits "field.x>=500" into the key "field.x>" and the value "500",
+// so each key and value are joined back before parsing
+func parseFieldFilters(queryParams url.Values) ([]storage.FieldFilter, error) {
+	var keys []string
+	for key := range queryParams {
+		if strings.HasPrefix(key, "field.") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	var filters []storage.FieldFilter
+	for _, key := range keys {
+		for _, value := range queryParams[key] {
+			expr := strings.TrimPrefix(key, "field.")
+			if value != "" {
+				expr += "=" + value
+			}
+			filter, err := storage.ParseFieldFilter(expr)
+			if err != nil {
+				return nil, err
+			}
+			filters = append(filters, filter)
+		}
+	}
+	return filters, nil
+}
+
 func parseStringSlice(s string) []string {
 	if s == "" {
 		return nil
@@ -474,6 +884,13 @@ func handleGetTimeline(store storage.Storage) http.HandlerFunc {
 			}
 		}
 
+		fieldFilters, err := parseFieldFilters(queryParams)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filters.Fields = fieldFilters
+
 		messages, err := store.Query(filters)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -554,8 +971,13 @@ func handleGetTimeline(store storage.Storage) http.HandlerFunc {
 				slotIndex = 0
 			}
 
-			slots[slotIndex].SeverityCounts[msg.Severity]++
-			slots[slotIndex].Total++
+			// A deduplicated message counts for each of its repeats
+			count := 1
+			if msg.RepeatCount > 1 {
+				count = msg.RepeatCount
+			}
+			slots[slotIndex].SeverityCounts[msg.Severity] += count
+			slots[slotIndex].Total += count
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -599,8 +1021,12 @@ func handleLogin(authManager *auth.AuthManager) http.HandlerFunc {
 			return
 		}
 
-		// Get API token
-		apiToken, _ := authManager.GetAPIToken(credentials.Username)
+		// Mint a new API token, replacing the previous default token
+		apiToken, err := authManager.GetAPIToken(credentials.Username)
+		if err != nil {
+			http.Error(w, "Failed to create API token", http.StatusInternalServerError)
+			return
+		}
 
 		// Set session cookie
 		http.SetCookie(w, &http.Cookie{
@@ -696,6 +1122,13 @@ func handleExport(store storage.Storage) http.HandlerFunc {
 			filters.Search = search
 		}
 
+		fieldFilters, err := parseFieldFilters(queryParams)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		filters.Fields = fieldFilters
+
 		messages, err := store.Query(filters)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -736,3 +1169,383 @@ func handleExport(store storage.Storage) http.HandlerFunc {
 		}
 	}
 }
+
+func handleParseTest(rules *grok.Rules, listeners *listenerSet, extractFields bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var request struct {
+			Raw      string     `json:"raw"`
+			Listener string     `json:"listener"` // Parse with this listener's options (e.g. lenient)
+			Rule     *grok.Rule `json:"rule"`     // Try this rule instead of the configured rules
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Raw == "" {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		var msg *parser.SyslogMessage
+		var err error
+		if request.Listener != "" {
+			col, ok := listeners.Get(request.Listener)
+			if !ok {
+				http.Error(w, fmt.Sprintf("Unknown listener: %s", request.Listener), http.StatusBadRequest)
+				return
+			}
+			msg, err = col.Reparse([]byte(request.Raw), "")
+		} else {
+			msg, err = parser.Parse(request.Raw)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error": err.Error(),
+			})
+			return
+		}
+
+		var rule string
+		if request.Rule != nil {
+			matched, err := rules.Test(*request.Rule, msg)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if matched {
+				rule = request.Rule.Name
+			}
+		} else {
+			rule, _ = rules.Apply(msg)
+		}
+		if extractFields {
+			parser.ExtractFields(msg)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": msg,
+			"rule":    rule,
+		})
+	}
+}
+
+func handleGrokRules(rules *grok.Rules) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(rules.List())
+
+		case http.MethodPost:
+			var rule grok.Rule
+			if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			if err := rules.Put(rule); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			rule.Source = grok.SourceAPI
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(rule)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func handleDeleteGrokRule(rules *grok.Rules) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		name := strings.TrimPrefix(r.URL.Path, "/api/parse/rules/")
+		if name == "" {
+			http.Error(w, "Missing rule name", http.StatusBadRequest)
+			return
+		}
+
+		if err := rules.Delete(name); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "success",
+			"message": "Rule deleted",
+		})
+	}
+}
+
+func handleTokens(authManager *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !authManager.IsEnabled() {
+			http.Error(w, "Authentication is disabled", http.StatusNotImplemented)
+			return
+		}
+
+		principal, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(authManager.ListTokens(""))
+
+		case http.MethodPost:
+			var request struct {
+				Name      string   `json:"name"`
+				Scopes    []string `json:"scopes"`
+				ExpiresIn string   `json:"expiresIn"` // e.g. 24h, 30d; empty for no expiry
+			}
+
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+
+			scopes := make([]auth.Scope, 0, len(request.Scopes))
+			for _, s := range request.Scopes {
+				scope, err := auth.ParseScope(s)
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				// A token cannot grant more than its creator holds
+				if !principal.HasScope(scope) {
+					http.Error(w, fmt.Sprintf("Cannot grant scope %q", scope), http.StatusForbidden)
+					return
+				}
+				scopes = append(scopes, scope)
+			}
+
+			var ttl time.Duration
+			if request.ExpiresIn != "" {
+				var err error
+				ttl, err = parseDuration(request.ExpiresIn)
+				if err != nil || ttl <= 0 {
+					http.Error(w, "Invalid expiresIn", http.StatusBadRequest)
+					return
+				}
+			}
+
+			token, info, err := authManager.CreateToken(principal.Username, request.Name, scopes, ttl)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"token": token,
+				"info":  info,
+			})
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+func handleRevokeToken(authManager *auth.AuthManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !authManager.IsEnabled() {
+			http.Error(w, "Authentication is disabled", http.StatusNotImplemented)
+			return
+		}
+
+		id := strings.TrimPrefix(r.URL.Path, "/api/tokens/")
+		if id == "" {
+			http.Error(w, "Missing token ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := authManager.RevokeToken(id); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "success",
+			"message": "Token revoked",
+		})
+	}
+}
+
+func handleGetRejects(rejects storage.RejectStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		queryParams := r.URL.Query()
+		filters := storage.RejectFilters{
+			Listener: queryParams.Get("listener"),
+			Source:   queryParams.Get("source"),
+			Limit:    100,
+		}
+		if limit, err := strconv.Atoi(queryParams.Get("limit")); err == nil && limit > 0 {
+			filters.Limit = limit
+		}
+		if offset, err := strconv.Atoi(queryParams.Get("offset")); err == nil && offset > 0 {
+			filters.Offset = offset
+		}
+
+		results, totalCount, err := rejects.QueryRejects(filters)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to query rejected messages: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"rejects": results,
+			"total":   totalCount,
+		})
+	}
+}
+
+func handleRejectSummary(rejects storage.RejectStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		summary, err := rejects.RejectSummary()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to summarize rejected messages: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if summary == nil {
+			summary = []storage.RejectSummary{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	}
+}
+
+// handleReprocessRejects parses rejected messages again and stores those that now succeed
+func handleReprocessRejects(rejects storage.RejectStore, listeners *listenerSet, handler collector.MessageHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		// An empty body reprocesses every rejected message (1000 at a time)
+		var req struct {
+			IDs      []uint `json:"ids"`
+			Listener string `json:"listener"`
+			Source   string `json:"source"`
+		}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+		}
+
+		results, totalCount, err := rejects.QueryRejects(storage.RejectFilters{
+			IDs:      req.IDs,
+			Listener: req.Listener,
+			Source:   req.Source,
+			Limit:    1000,
+		})
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to query rejected messages: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		var reprocessed []uint
+		failed := 0
+		for _, reject := range results {
+			// Use the listener's current options (e.g. lenient mode) when it still exists
+			var msg *parser.SyslogMessage
+			var err error
+			if col, ok := listeners.Get(reject.Listener); ok {
+				msg, err = col.Reparse([]byte(reject.Raw), reject.Source)
+			} else {
+				msg, err = collector.Reparse(reject.Protocol, []byte(reject.Raw))
+			}
+			if err == nil {
+				msg.Listener = reject.Listener
+				msg.SourceHost = reject.Source
+				err = handler(msg)
+			}
+			if err != nil {
+				failed++
+				if err := rejects.UpdateRejectError(reject.ID, err.Error()); err != nil {
+					log.Printf("Failed to update rejected message %d: %v", reject.ID, err)
+				}
+				continue
+			}
+			reprocessed = append(reprocessed, reject.ID)
+		}
+
+		if _, err := rejects.DeleteRejects(reprocessed); err != nil {
+			http.Error(w, fmt.Sprintf("Reprocessed messages could not be removed: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"reprocessed": len(reprocessed),
+			"failed":      failed,
+			"remaining":   totalCount - int64(len(reprocessed)),
+		})
+	}
+}
+
+func handleDeleteReject(rejects storage.RejectStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodDelete {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, "/api/rejects/"), 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid rejected message ID", http.StatusBadRequest)
+			return
+		}
+
+		deleted, err := rejects.DeleteRejects([]uint{uint(id)})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if deleted == 0 {
+			http.Error(w, "Rejected message not found", http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"status":  "success",
+			"message": "Rejected message deleted",
+		})
+	}
+}
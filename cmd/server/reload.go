@@ -0,0 +1,460 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"syslog-visualizer/internal/auth"
+	"syslog-visualizer/internal/collector"
+	"syslog-visualizer/internal/config"
+	"syslog-visualizer/internal/dedup"
+	"syslog-visualizer/internal/enrich"
+	"syslog-visualizer/internal/grok"
+	"syslog-visualizer/internal/ingest"
+	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/internal/redact"
+	"syslog-visualizer/internal/routing"
+	"syslog-visualizer/internal/storage"
+)
+
+// listenerStartTimeout is how long a reload waits for a new listener to fail, e.g.
+// because its address is in use, before reporting it as started
+const listenerStartTimeout = 500 * time.Millisecond
+
+// listenerSet holds the running collectors, which a reload can change
+type listenerSet struct {
+	mu         sync.RWMutex
+	collectors []*collector.Collector
+}
+
+// List returns the running collectors
+func (s *listenerSet) List() []*collector.Collector {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*collector.Collector(nil), s.collectors...)
+}
+
+// Get returns the collector with the given listener name
+func (s *listenerSet) Get(name string) (*collector.Collector, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, col := range s.collectors {
+		if col.Name() == name {
+			return col, true
+		}
+	}
+	return nil, false
+}
+
+// put adds a collector, or replaces the one with the same name
+func (s *listenerSet) put(col *collector.Collector) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.collectors {
+		if existing.Name() == col.Name() {
+			s.collectors[i] = col
+			return
+		}
+	}
+	s.collectors = append(s.collectors, col)
+}
+
+// remove drops the collector with the given listener name
+func (s *listenerSet) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, existing := range s.collectors {
+		if existing.Name() == name {
+			s.collectors = append(s.collectors[:i], s.collectors[i+1:]...)
+			return
+		}
+	}
+}
+
+// ReloadReport describes what a reload changed
+type ReloadReport struct {
+	Listeners  config.ListenerChanges `json:"listeners"`
+	Failed     map[string]string      `json:"failed,omitempty"` // Listeners that could not start, with the error
+	Grok       bool                   `json:"grok"`             // Grok patterns and rules replaced
+	Time       bool                   `json:"time"`             // Timezones and clock settings replaced
+	Retention  bool                   `json:"retention"`        // Retention policy replaced
+	Dedup      bool                   `json:"dedup"`            // Deduplication settings replaced
+	Redaction  bool                   `json:"redaction"`        // Redaction rules replaced
+	Routing    bool                   `json:"routing"`          // Routing rules replaced
+	Enrichment bool                   `json:"enrichment"`       // Inventory and GeoIP database read again
+	Users      bool                   `json:"users"`            // Users of the configuration file replaced
+
+	// Changed sections that only apply on restart
+	RestartRequired []string `json:"restartRequired"`
+}
+
+// reloader applies a changed configuration file to the running server
+type reloader struct {
+	mu      sync.Mutex // One reload at a time
+	closed  bool
+	cfg     *config.Config
+	file    string
+	timeout time.Duration // How long stopping a changed listener may take
+
+	retentionDefaults config.RetentionConfig // Command-line retention settings
+	retention         *retentionPolicy
+	grokRules         *grok.Rules
+	deduplicator      *dedup.Deduplicator
+	redactor          *redact.Redactor
+	router            *routing.Router
+	enricher          *enrich.Enricher
+	store             *storage.SQLiteStorage // Facets of the enrichment fields
+	authManager       *auth.AuthManager
+	listeners         *listenerSet
+	handler           collector.MessageHandler
+	rejectHandler     collector.RejectHandler
+	ingest            atomic.Pointer[http.HandlerFunc]
+}
+
+// setTime builds the ingest handler for the timezones and clock settings
+func (r *reloader) setTime(timezones *parser.TimezoneMap, timeCfg config.TimeConfig) {
+	handler := ingest.NewHTTPHandler(r.handler, parser.Options{
+		Timezones:        timezones,
+		TrustReceiveTime: timeCfg.TrustReceiveTime,
+		MaxClockSkew:     timeCfg.MaxClockSkew,
+	})
+	r.ingest.Store(&handler)
+}
+
+// handleIngest serves POST /api/ingest with the current clock settings
+func (r *reloader) handleIngest(w http.ResponseWriter, req *http.Request) {
+	(*r.ingest.Load())(w, req)
+}
+
+// Reload reads the configuration file again and applies what changed
+// The new configuration is checked as a whole first: when it is invalid, nothing
+// changes and the error says why
+func (r *reloader) Reload() (*ReloadReport, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report, err := r.reload()
+	if err != nil {
+		log.Printf("Configuration reload rejected: %v", err)
+		return nil, err
+	}
+	log.Printf("Configuration reloaded: %s", report)
+	return report, nil
+}
+
+func (r *reloader) reload() (*ReloadReport, error) {
+	if r.closed {
+		return nil, errors.New("server is shutting down")
+	}
+	if r.file == "" {
+		return nil, errors.New("no configuration file to reload (start the server with -config)")
+	}
+
+	cfg, err := config.Load(r.file)
+	if err != nil {
+		return nil, err
+	}
+	retention, err := resolveRetention(cfg.Retention, r.retentionDefaults)
+	if err != nil {
+		return nil, fmt.Errorf("invalid retention: %w", err)
+	}
+	timezones, err := parser.NewTimezoneMap(cfg.Time.DefaultTimezone, cfg.Time.Timezones)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezones: %w", err)
+	}
+	// The enrichment files are read on every reload: they may change while the
+	// configuration does not
+	sources, err := enrich.Load(cfg.Enrichment)
+	if err != nil {
+		return nil, fmt.Errorf("enrichment: %w", err)
+	}
+
+	report := &ReloadReport{
+		Listeners:       config.DiffListeners(r.cfg.Collector.Listeners, cfg.Collector.Listeners),
+		Failed:          make(map[string]string),
+		Grok:            !reflect.DeepEqual(r.cfg.Grok, cfg.Grok),
+		Time:            !reflect.DeepEqual(r.cfg.Time, cfg.Time),
+		Retention:       *retention != *r.retention.Get(),
+		Dedup:           r.cfg.Dedup != cfg.Dedup,
+		Redaction:       !reflect.DeepEqual(r.cfg.Redaction, cfg.Redaction),
+		Routing:         !reflect.DeepEqual(r.cfg.Routing, cfg.Routing),
+		Enrichment:      cfg.Enrichment.Enabled() || r.cfg.Enrichment != cfg.Enrichment,
+		Users:           !reflect.DeepEqual(r.cfg.Auth, cfg.Auth),
+		RestartRequired: []string{},
+	}
+	if !reflect.DeepEqual(r.cfg.Outputs, cfg.Outputs) {
+		report.RestartRequired = append(report.RestartRequired, "outputs")
+	}
+
+	// Create the new listeners before touching the running ones, so that an invalid
+	// listener rejects the whole configuration
+	listenerConfigs := make(map[string]config.ListenerConfig, len(cfg.Collector.Listeners))
+	for _, listener := range cfg.Collector.Listeners {
+		listenerConfigs[listener.Name] = listener
+	}
+	created := make(map[string]*collector.Collector)
+	for _, name := range append(report.Listeners.Added, report.Listeners.Changed...) {
+		col, err := newCollector(listenerConfigs[name], cfg.Time, timezones, r.handler, r.rejectHandler)
+		if err != nil {
+			return nil, fmt.Errorf("listener %s: %w", name, err)
+		}
+		created[name] = col
+	}
+
+	if report.Users {
+		if err := r.authManager.CheckUsers(cfg.Auth.Users); err != nil {
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	}
+
+	var redactions *redact.RuleSet
+	if report.Redaction {
+		if redactions, err = redact.Compile(cfg.Redaction); err != nil {
+			return nil, err
+		}
+	}
+	var routes *routing.RuleSet
+	if report.Routing {
+		if routes, err = routing.Compile(cfg.Routing); err != nil {
+			return nil, err
+		}
+	}
+
+	// Grok rules are the last check: Reload swaps them only when they all compile, API
+	// rules included, and every change after it cannot fail
+	if report.Grok {
+		if err := r.grokRules.Reload(cfg.Grok); err != nil {
+			return nil, err
+		}
+	}
+	if redactions != nil {
+		r.redactor.Set(redactions)
+	}
+	if routes != nil {
+		r.router.Set(routes)
+	}
+
+	if report.Users {
+		// Only writing the token file of removed users can fail here
+		if err := r.authManager.SetUsers(cfg.Auth.Users); err != nil {
+			log.Printf("Failed to replace users: %v", err)
+		}
+	}
+	if report.Time {
+		for _, col := range r.listeners.List() {
+			col.SetTimeOptions(timezones, cfg.Time.TrustReceiveTime, cfg.Time.MaxClockSkew)
+		}
+		r.setTime(timezones, cfg.Time)
+	}
+	if report.Retention {
+		r.retention.Set(retention)
+	}
+	if report.Dedup {
+		r.deduplicator.SetConfig(cfg.Dedup)
+	}
+	if report.Enrichment {
+		r.enricher.Set(sources)
+		var facets []string
+		if cfg.Enrichment.Enabled() {
+			facets = enrich.FacetFields
+		}
+		r.store.SetFacetFields(facets)
+	}
+
+	// Stop the removed and changed listeners, then start their replacements: a changed
+	// listener usually keeps its address, which must be free first
+	var stopping []*collector.Collector
+	for _, name := range append(report.Listeners.Removed, report.Listeners.Changed...) {
+		if col, ok := r.listeners.Get(name); ok {
+			stopping = append(stopping, col)
+		}
+	}
+	stopCollectors(stopping, r.timeout)
+	for _, name := range report.Listeners.Removed {
+		r.listeners.remove(name)
+	}
+
+	previous := make(map[string]config.ListenerConfig, len(r.cfg.Collector.Listeners))
+	for _, listener := range r.cfg.Collector.Listeners {
+		previous[listener.Name] = listener
+	}
+
+	running := make([]config.ListenerConfig, 0, len(cfg.Collector.Listeners))
+	for _, listener := range cfg.Collector.Listeners {
+		col, ok := created[listener.Name]
+		if !ok {
+			running = append(running, listener)
+			continue
+		}
+		log.Printf("Starting syslog collector %s...", col.Name())
+		err := startCollector(col)
+		if err == nil {
+			r.listeners.put(col)
+			running = append(running, listener)
+			continue
+		}
+
+		report.Failed[listener.Name] = err.Error()
+		r.listeners.remove(listener.Name)
+		if old, ok := previous[listener.Name]; ok {
+			if err := r.restore(old, cfg.Time, timezones); err != nil {
+				log.Printf("Failed to restore collector %s: %v", listener.Name, err)
+			} else {
+				report.Failed[listener.Name] += " (previous configuration restored)"
+				running = append(running, old)
+			}
+		}
+	}
+
+	// Listeners that failed to start are left out, or kept with their previous
+	// configuration, so that the next reload tries them again, and outputs stay those
+	// running until a restart
+	cfg.Collector.Listeners = running
+	cfg.Outputs = r.cfg.Outputs
+	r.cfg = cfg
+	return report, nil
+}
+
+// restore starts a changed listener again with its previous configuration, after the
+// new one failed to start
+func (r *reloader) restore(listener config.ListenerConfig, timeCfg config.TimeConfig, timezones *parser.TimezoneMap) error {
+	col, err := newCollector(listener, timeCfg, timezones, r.handler, r.rejectHandler)
+	if err != nil {
+		return err
+	}
+	log.Printf("Restoring syslog collector %s...", col.Name())
+	if err := startCollector(col); err != nil {
+		return err
+	}
+	r.listeners.put(col)
+	return nil
+}
+
+// close refuses further reloads, once the server is shutting down
+func (r *reloader) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+}
+
+// String summarizes the changes for the log
+func (report *ReloadReport) String() string {
+	var parts []string
+	for _, change := range []struct {
+		what  string
+		names []string
+	}{
+		{"listeners added", report.Listeners.Added},
+		{"listeners removed", report.Listeners.Removed},
+		{"listeners restarted", report.Listeners.Changed},
+	} {
+		if len(change.names) > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %s", change.what, strings.Join(change.names, ", ")))
+		}
+	}
+	for name, err := range report.Failed {
+		parts = append(parts, fmt.Sprintf("listener %s failed to start: %s", name, err))
+	}
+	for _, change := range []struct {
+		what    string
+		changed bool
+	}{
+		{"grok rules replaced", report.Grok},
+		{"time settings replaced", report.Time},
+		{"retention policy replaced", report.Retention},
+		{"deduplication settings replaced", report.Dedup},
+		{"redaction rules replaced", report.Redaction},
+		{"routing rules replaced", report.Routing},
+		{"enrichment sources reloaded", report.Enrichment},
+		{"users replaced", report.Users},
+	} {
+		if change.changed {
+			parts = append(parts, change.what)
+		}
+	}
+	if len(report.RestartRequired) > 0 {
+		parts = append(parts, "restart required for "+strings.Join(report.RestartRequired, ", "))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// startCollector starts a collector and waits briefly for a startup error
+// Errors after that are only logged
+func startCollector(col *collector.Collector) error {
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- col.Start()
+	}()
+
+	select {
+	case err := <-errChan:
+		if err == nil {
+			err = errors.New("stopped while starting")
+		}
+		return err
+	case <-time.After(listenerStartTimeout):
+		go func() {
+			if err := <-errChan; err != nil {
+				log.Printf("Collector %s error: %v", col.Name(), err)
+			}
+		}()
+		return nil
+	}
+}
+
+// stopCollectors stops collectors in parallel, letting each store the messages it
+// received until the timeout
+func stopCollectors(collectors []*collector.Collector, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, col := range collectors {
+		wg.Add(1)
+		go func(col *collector.Collector) {
+			defer wg.Done()
+			abandoned, err := col.Stop(ctx)
+			if err != nil {
+				log.Printf("Error stopping collector %s: %v", col.Name(), err)
+			}
+			if abandoned > 0 {
+				log.Printf("Collector %s abandoned %d messages", col.Name(), abandoned)
+			}
+		}(col)
+	}
+	wg.Wait()
+}
+
+// handleReload serves POST /api/admin/reload
+func handleReload(r *reloader) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		report, err := r.Reload()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Configuration rejected: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status":  "reloaded",
+			"changes": report,
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/auth"
+	"syslog-visualizer/internal/collector"
+	"syslog-visualizer/internal/config"
+	"syslog-visualizer/internal/dedup"
+	"syslog-visualizer/internal/enrich"
+	"syslog-visualizer/internal/grok"
+	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/internal/redact"
+	"syslog-visualizer/internal/routing"
+	"syslog-visualizer/internal/storage"
+)
+
+// freeAddr returns a local TCP address that nothing listens on
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	return addr
+}
+
+// accepting reports whether something accepts TCP connections on the address
+func accepting(addr string) bool {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// tcpListeners returns the listeners section of a configuration file with a TCP
+// listener per name and address pair
+func tcpListeners(pairs ...string) string {
+	var b strings.Builder
+	b.WriteString("collector:\n  listeners:\n")
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fmt.Fprintf(&b, "    - name: %s\n      protocol: tcp\n      address: %q\n", pairs[i], pairs[i+1])
+	}
+	return b.String()
+}
+
+// redactsEmail reports whether the redaction rules in effect mask an email address
+func redactsEmail(r *reloader) bool {
+	msg := &parser.SyslogMessage{Message: "sent to bob@example.com"}
+	r.redactor.Apply(msg)
+	return msg.Message != "sent to bob@example.com"
+}
+
+// writeConfig replaces the configuration file
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+// newTestReloader starts the listeners of a configuration and returns a reloader for
+// the file it was written to
+func newTestReloader(t *testing.T, content string) *reloader {
+	t.Helper()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	writeConfig(t, file, content)
+
+	cfg, err := config.Load(file)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	enabled := true
+	retentionDefaults := config.RetentionConfig{Period: "7d", CleanupInterval: "1h", Enabled: &enabled}
+	retention, err := resolveRetention(cfg.Retention, retentionDefaults)
+	if err != nil {
+		t.Fatalf("resolveRetention() error = %v", err)
+	}
+	timezones, err := parser.NewTimezoneMap(cfg.Time.DefaultTimezone, cfg.Time.Timezones)
+	if err != nil {
+		t.Fatalf("NewTimezoneMap() error = %v", err)
+	}
+
+	store, err := storage.NewSQLiteStorage(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	grokRules, err := grok.NewRules(cfg.Grok)
+	if err != nil {
+		t.Fatalf("grok.NewRules() error = %v", err)
+	}
+	redactor, err := redact.New(cfg.Redaction)
+	if err != nil {
+		t.Fatalf("redact.New() error = %v", err)
+	}
+	router, err := routing.New(cfg.Routing)
+	if err != nil {
+		t.Fatalf("routing.New() error = %v", err)
+	}
+	enricher, err := enrich.New(cfg.Enrichment)
+	if err != nil {
+		t.Fatalf("enrich.New() error = %v", err)
+	}
+	deduplicator := dedup.New(cfg.Dedup, store.Store, store.AddRepeats)
+	t.Cleanup(deduplicator.Close)
+
+	handler := func(msg *parser.SyslogMessage) error { return nil }
+	rejectHandler := func(reject *collector.Reject) {}
+
+	listeners := &listenerSet{}
+	t.Cleanup(func() { stopCollectors(listeners.List(), time.Second) })
+	for _, listener := range cfg.Collector.Listeners {
+		col, err := newCollector(listener, cfg.Time, timezones, handler, rejectHandler)
+		if err != nil {
+			t.Fatalf("newCollector(%s) error = %v", listener.Name, err)
+		}
+		if err := startCollector(col); err != nil {
+			t.Fatalf("startCollector(%s) error = %v", listener.Name, err)
+		}
+		listeners.put(col)
+	}
+
+	r := &reloader{
+		cfg:               cfg,
+		file:              file,
+		timeout:           time.Second,
+		retentionDefaults: retentionDefaults,
+		retention:         newRetentionPolicy(retention),
+		grokRules:         grokRules,
+		deduplicator:      deduplicator,
+		redactor:          redactor,
+		router:            router,
+		enricher:          enricher,
+		store:             store,
+		authManager:       auth.NewAuthManager(false),
+		listeners:         listeners,
+		handler:           handler,
+		rejectHandler:     rejectHandler,
+	}
+	r.setTime(timezones, cfg.Time)
+	return r
+}
+
+func TestReloadRejectsInvalidConfig(t *testing.T) {
+	addr, moved := freeAddr(t), freeAddr(t)
+	r := newTestReloader(t, tcpListeners("tcp", addr))
+	cfg := r.cfg
+	col, _ := r.listeners.Get("tcp")
+
+	// Each configuration also moves the listener, which must not happen
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"unsupported protocol", tcpListeners("tcp", moved) + "    - name: bad\n      protocol: carrier-pigeon\n"},
+		{"invalid grok pattern", tcpListeners("tcp", moved) +
+			"redaction:\n  rules:\n    - detector: email\n" +
+			"grok:\n  rules:\n    - name: bad\n      patterns: [\"%{NO_SUCH_PATTERN:x}\"]\n"},
+		{"unknown routing output", tcpListeners("tcp", moved) +
+			"routing:\n  rules:\n    - name: siem\n      outputs: [siem]\n"},
+		{"plaintext password", tcpListeners("tcp", moved) +
+			"auth:\n  users:\n    - username: ops\n      password_hash: secret\n"},
+	}
+	for _, tt := range tests {
+		writeConfig(t, r.file, tt.content)
+		if _, err := r.Reload(); err == nil {
+			t.Errorf("%s: Reload() error = nil, want an error", tt.name)
+		}
+		if r.cfg != cfg {
+			t.Errorf("%s: configuration replaced by a rejected reload", tt.name)
+		}
+		if current, _ := r.listeners.Get("tcp"); current != col {
+			t.Errorf("%s: listener replaced by a rejected reload", tt.name)
+		}
+		if accepting(moved) || !accepting(addr) {
+			t.Errorf("%s: listener moved by a rejected reload", tt.name)
+		}
+		if redactsEmail(r) {
+			t.Errorf("%s: redaction rules swapped by a rejected reload", tt.name)
+		}
+	}
+}
+
+func TestReloadListeners(t *testing.T) {
+	keptAddr, oldAddr, newAddr, addedAddr := freeAddr(t), freeAddr(t), freeAddr(t), freeAddr(t)
+	r := newTestReloader(t, tcpListeners("kept", keptAddr, "moved", oldAddr, "removed", freeAddr(t)))
+	kept, _ := r.listeners.Get("kept")
+
+	writeConfig(t, r.file, tcpListeners("kept", keptAddr, "moved", newAddr, "added", addedAddr))
+	report, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	want := config.ListenerChanges{Added: []string{"added"}, Removed: []string{"removed"}, Changed: []string{"moved"}}
+	if !reflect.DeepEqual(report.Listeners, want) {
+		t.Errorf("Listeners = %+v, want %+v", report.Listeners, want)
+	}
+	if len(report.Failed) != 0 {
+		t.Errorf("Failed = %v, want none", report.Failed)
+	}
+
+	// The unchanged listener keeps running, the changed one moves
+	if col, _ := r.listeners.Get("kept"); col != kept {
+		t.Errorf("unchanged listener was restarted")
+	}
+	if !accepting(keptAddr) {
+		t.Errorf("unchanged listener stopped accepting connections")
+	}
+	if accepting(oldAddr) || !accepting(newAddr) {
+		t.Errorf("changed listener still on %s or not on %s", oldAddr, newAddr)
+	}
+	if !accepting(addedAddr) {
+		t.Errorf("added listener not accepting connections on %s", addedAddr)
+	}
+	if _, ok := r.listeners.Get("removed"); ok {
+		t.Errorf("removed listener still in the listener set")
+	}
+	if n := len(r.listeners.List()); n != 3 {
+		t.Errorf("len(List()) = %d, want 3", n)
+	}
+}
+
+func TestReloadRestoresFailedListener(t *testing.T) {
+	addr := freeAddr(t)
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer busy.Close()
+
+	r := newTestReloader(t, tcpListeners("tcp", addr))
+	writeConfig(t, r.file, tcpListeners("tcp", busy.Addr().String()))
+	report, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if !strings.Contains(report.Failed["tcp"], "previous configuration restored") {
+		t.Errorf("Failed[tcp] = %q, want the previous configuration restored", report.Failed["tcp"])
+	}
+	if !accepting(addr) {
+		t.Errorf("listener not restored on %s", addr)
+	}
+	if got := r.cfg.Collector.Listeners[0].Address; got != addr {
+		t.Errorf("running listener address = %s, want %s", got, addr)
+	}
+
+	// Once the address is free, the next reload tries again
+	moved := busy.Addr().String()
+	busy.Close()
+	report, err = r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if len(report.Failed) != 0 || !reflect.DeepEqual(report.Listeners.Changed, []string{"tcp"}) {
+		t.Errorf("second Reload() = %+v, want tcp changed", report)
+	}
+	if accepting(addr) || !accepting(moved) {
+		t.Errorf("listener not moved to %s on the second reload", moved)
+	}
+}
+
+func TestReloadReport(t *testing.T) {
+	addr := freeAddr(t)
+	r := newTestReloader(t, tcpListeners("tcp", addr))
+
+	report, err := r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := report.String(); got != "no changes" {
+		t.Errorf("String() of an unchanged reload = %q, want no changes", got)
+	}
+
+	writeConfig(t, r.file, tcpListeners("tcp", addr)+`
+time:
+  max_clock_skew: 1h
+retention:
+  period: 30d
+dedup:
+  window: 1m
+redaction:
+  rules:
+    - detector: email
+outputs:
+  - name: siem
+    protocol: udp
+    targets: ["127.0.0.1:1"]
+`)
+	report, err = r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	changed := []struct {
+		name      string
+		got, want bool
+	}{
+		{"Grok", report.Grok, false},
+		{"Time", report.Time, true},
+		{"Retention", report.Retention, true},
+		{"Dedup", report.Dedup, true},
+		{"Redaction", report.Redaction, true},
+		{"Routing", report.Routing, false},
+		{"Enrichment", report.Enrichment, false},
+		{"Users", report.Users, false},
+	}
+	for _, c := range changed {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	if !reflect.DeepEqual(report.RestartRequired, []string{"outputs"}) {
+		t.Errorf("RestartRequired = %v, want [outputs]", report.RestartRequired)
+	}
+	if len(report.Listeners.Added)+len(report.Listeners.Removed)+len(report.Listeners.Changed) != 0 {
+		t.Errorf("Listeners = %+v, want no changes", report.Listeners)
+	}
+
+	// The swapped settings are in effect, while outputs wait for a restart
+	if got := r.retention.Get().RetentionPeriod; got != 30*24*time.Hour {
+		t.Errorf("retention period = %v, want 720h", got)
+	}
+	if !redactsEmail(r) {
+		t.Errorf("redaction rules not swapped in")
+	}
+	if len(r.cfg.Outputs) != 0 {
+		t.Errorf("Outputs = %+v, want those running until a restart", r.cfg.Outputs)
+	}
+
+	want := "time settings replaced; retention policy replaced; deduplication settings replaced; redaction rules replaced; restart required for outputs"
+	if got := report.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	// The outputs are reported again on every reload until the restart
+	report, err = r.Reload()
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if !reflect.DeepEqual(report.RestartRequired, []string{"outputs"}) || report.Dedup {
+		t.Errorf("repeated Reload() = %+v, want only outputs pending", report)
+	}
+}
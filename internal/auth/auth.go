@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
@@ -18,8 +19,38 @@ import (
 type User struct {
 	Username     string
 	PasswordHash string
-	APIToken     string
 	CreatedAt    time.Time
+	fromConfig   bool // Declared in the configuration file, which a reload can change
+}
+
+// Config declares users in the configuration file, in addition to those given on the
+// command line
+type Config struct {
+	Users []UserConfig `yaml:"users"`
+}
+
+// UserConfig is a user with a bcrypt password hash (e.g. from htpasswd -nB)
+type UserConfig struct {
+	Username     string `yaml:"username"`
+	PasswordHash string `yaml:"password_hash"`
+}
+
+// Validate checks that users have a name, appear once and have a bcrypt hash
+func (c Config) Validate() error {
+	names := make(map[string]bool)
+	for i, u := range c.Users {
+		if u.Username == "" {
+			return fmt.Errorf("user %d: username is required", i)
+		}
+		if names[u.Username] {
+			return fmt.Errorf("duplicate user: %s", u.Username)
+		}
+		names[u.Username] = true
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return fmt.Errorf("user %s: password_hash is not a bcrypt hash: %w", u.Username, err)
+		}
+	}
+	return nil
 }
 
 // Session represents an active user session
@@ -29,12 +60,35 @@ type Session struct {
 	ExpiresAt time.Time
 }
 
+// Principal identifies an authenticated caller and the scopes it holds
+type Principal struct {
+	Username string
+	TokenID  string // Empty when authenticated by password or session
+	Scopes   []Scope
+}
+
+// HasScope reports whether the principal holds the given scope
+func (p *Principal) HasScope(scope Scope) bool {
+	return hasScope(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal stored by the middleware, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok
+}
+
 // AuthManager manages authentication and authorization
 type AuthManager struct {
-	users    map[string]*User
-	sessions map[string]*Session
-	mu       sync.RWMutex
-	enabled  bool
+	users       map[string]*User
+	sessions    map[string]*Session
+	tokens      map[string]*Token
+	tokenFile   string
+	tokensDirty bool
+	mu          sync.RWMutex
+	enabled     bool
 }
 
 // NewAuthManager creates a new authentication manager
@@ -42,6 +96,7 @@ func NewAuthManager(enabled bool) *AuthManager {
 	return &AuthManager{
 		users:    make(map[string]*User),
 		sessions: make(map[string]*Session),
+		tokens:   make(map[string]*Token),
 		enabled:  enabled,
 	}
 }
@@ -51,7 +106,7 @@ func (am *AuthManager) IsEnabled() bool {
 	return am.enabled
 }
 
-// AddUser adds a new user with a hashed password and generates an API token
+// AddUser adds a new user with a hashed password
 func (am *AuthManager) AddUser(username, password string) error {
 	am.mu.Lock()
 	defer am.mu.Unlock()
@@ -66,22 +121,95 @@ func (am *AuthManager) AddUser(username, password string) error {
 		return fmt.Errorf("failed to hash password: %w", err)
 	}
 
-	// Generate API token
-	apiToken, err := generateAPIToken()
-	if err != nil {
-		return fmt.Errorf("failed to generate API token: %w", err)
-	}
-
-	am.users[username] = &User{
+	user := &User{
 		Username:     username,
 		PasswordHash: string(hash),
-		APIToken:     apiToken,
 		CreatedAt:    time.Now(),
 	}
+	am.users[username] = user
 
 	return nil
 }
 
+// CheckUsers reports whether SetUsers would accept the users: none may share the name of
+// a user added with AddUser
+func (am *AuthManager) CheckUsers(users []UserConfig) error {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+	return am.checkUsersLocked(users)
+}
+
+// checkUsersLocked implements CheckUsers (caller must hold the lock)
+func (am *AuthManager) checkUsersLocked(users []UserConfig) error {
+	for _, u := range users {
+		if existing, exists := am.users[u.Username]; exists && !existing.fromConfig {
+			return fmt.Errorf("user %s is already set on the command line", u.Username)
+		}
+	}
+	return nil
+}
+
+// SetUsers replaces the users of the configuration file
+// Removed users lose their sessions and tokens, and users whose password changed their
+// sessions
+func (am *AuthManager) SetUsers(users []UserConfig) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if err := am.checkUsersLocked(users); err != nil {
+		return err
+	}
+
+	current := make(map[string]UserConfig, len(users))
+	for _, u := range users {
+		current[u.Username] = u
+	}
+
+	revoked := false
+	for username, user := range am.users {
+		if !user.fromConfig {
+			continue
+		}
+		u, kept := current[username]
+		if kept && u.PasswordHash == user.PasswordHash {
+			continue
+		}
+		for token, session := range am.sessions {
+			if session.Username == username {
+				delete(am.sessions, token)
+			}
+		}
+		if kept {
+			continue
+		}
+		delete(am.users, username)
+		for id, token := range am.tokens {
+			if token.Username == username {
+				delete(am.tokens, id)
+				revoked = revoked || token.persist
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, u := range users {
+		if user, exists := am.users[u.Username]; exists && user.PasswordHash == u.PasswordHash {
+			continue
+		}
+		am.users[u.Username] = &User{
+			Username:     u.Username,
+			PasswordHash: u.PasswordHash,
+			CreatedAt:    now,
+			fromConfig:   true,
+		}
+	}
+
+	if revoked {
+		return am.saveTokensLocked()
+	}
+	return nil
+}
+
 // VerifyPassword verifies a username and password combination
 func (am *AuthManager) VerifyPassword(username, password string) bool {
 	am.mu.RLock()
@@ -98,16 +226,11 @@ func (am *AuthManager) VerifyPassword(username, password string) bool {
 
 // VerifyAPIToken verifies an API token and returns the associated username
 func (am *AuthManager) VerifyAPIToken(token string) (string, bool) {
-	am.mu.RLock()
-	defer am.mu.RUnlock()
-
-	for _, user := range am.users {
-		if user.APIToken == token {
-			return user.Username, true
-		}
+	t, valid := am.verifyToken(token)
+	if !valid {
+		return "", false
 	}
-
-	return "", false
+	return t.Username, true
 }
 
 // CreateSession creates a new session for a user
@@ -158,20 +281,27 @@ func (am *AuthManager) DeleteSession(token string) {
 	delete(am.sessions, token)
 }
 
-// GetAPIToken returns the API token for a user
+// GetAPIToken mints a new default token with every scope for a user and returns its plaintext
+// The previous default token of the user, if any, is revoked: only its hash was kept
 func (am *AuthManager) GetAPIToken(username string) (string, error) {
-	am.mu.RLock()
-	defer am.mu.RUnlock()
+	am.mu.Lock()
+	defer am.mu.Unlock()
 
-	user, exists := am.users[username]
-	if !exists {
-		return "", fmt.Errorf("user not found")
+	plaintext, token, err := am.createTokenLocked(username, defaultTokenName, AllScopes, 0)
+	if err != nil {
+		return "", err
 	}
 
-	return user.APIToken, nil
+	for id, t := range am.tokens {
+		if t.Username == username && t.Name == defaultTokenName && !t.persist && id != token.ID {
+			delete(am.tokens, id)
+		}
+	}
+
+	return plaintext, nil
 }
 
-// CleanupExpiredSessions removes expired sessions
+// CleanupExpiredSessions removes expired sessions and tokens
 func (am *AuthManager) CleanupExpiredSessions() {
 	am.mu.Lock()
 	defer am.mu.Unlock()
@@ -182,6 +312,13 @@ func (am *AuthManager) CleanupExpiredSessions() {
 			delete(am.sessions, token)
 		}
 	}
+
+	for id, token := range am.tokens {
+		if token.Expired(now) {
+			delete(am.tokens, id)
+			am.tokensDirty = am.tokensDirty || token.persist
+		}
+	}
 }
 
 // generateAPIToken generates a secure random API token
@@ -207,6 +344,12 @@ func generateSessionToken() (string, error) {
 
 // Middleware returns an HTTP middleware that requires authentication
 func (am *AuthManager) Middleware(next http.Handler) http.Handler {
+	return am.RequireScope("", next)
+}
+
+// RequireScope returns an HTTP middleware that requires authentication with the given scope
+// An empty scope accepts any authenticated caller
+func (am *AuthManager) RequireScope(scope Scope, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// If authentication is disabled, allow all requests
 		if !am.enabled {
@@ -214,44 +357,55 @@ func (am *AuthManager) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Check for API token in Authorization header (Bearer token)
-		if authHeader := r.Header.Get("Authorization"); authHeader != "" {
-			parts := strings.Split(authHeader, " ")
-			if len(parts) == 2 {
-				if parts[0] == "Bearer" {
-					if username, valid := am.VerifyAPIToken(parts[1]); valid {
-						r.Header.Set("X-Username", username)
-						next.ServeHTTP(w, r)
-						return
-					}
-				} else if parts[0] == "Basic" {
-					// Basic auth
-					payload, err := base64.StdEncoding.DecodeString(parts[1])
-					if err == nil {
-						credentials := strings.SplitN(string(payload), ":", 2)
-						if len(credentials) == 2 {
-							if am.VerifyPassword(credentials[0], credentials[1]) {
-								r.Header.Set("X-Username", credentials[0])
-								next.ServeHTTP(w, r)
-								return
-							}
+		principal, ok := am.authenticate(r)
+		if !ok {
+			// No valid authentication found
+			w.Header().Set("WWW-Authenticate", `Bearer realm="API", Basic realm="Web"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		if scope != "" && !principal.HasScope(scope) {
+			http.Error(w, fmt.Sprintf("Forbidden: token lacks %q scope", scope), http.StatusForbidden)
+			return
+		}
+
+		r.Header.Set("X-Username", principal.Username)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+	})
+}
+
+// authenticate resolves the caller from a Bearer token, Basic credentials or a session cookie
+func (am *AuthManager) authenticate(r *http.Request) (*Principal, bool) {
+	// Check for API token in Authorization header (Bearer token)
+	if authHeader := r.Header.Get("Authorization"); authHeader != "" {
+		parts := strings.Split(authHeader, " ")
+		if len(parts) == 2 {
+			if parts[0] == "Bearer" {
+				if token, valid := am.verifyToken(parts[1]); valid {
+					return &Principal{Username: token.Username, TokenID: token.ID, Scopes: token.Scopes}, true
+				}
+			} else if parts[0] == "Basic" {
+				// Basic auth
+				payload, err := base64.StdEncoding.DecodeString(parts[1])
+				if err == nil {
+					credentials := strings.SplitN(string(payload), ":", 2)
+					if len(credentials) == 2 {
+						if am.VerifyPassword(credentials[0], credentials[1]) {
+							return &Principal{Username: credentials[0], Scopes: AllScopes}, true
 						}
 					}
 				}
 			}
 		}
+	}
 
-		// Check for session cookie
-		if cookie, err := r.Cookie("session"); err == nil {
-			if username, valid := am.ValidateSession(cookie.Value); valid {
-				r.Header.Set("X-Username", username)
-				next.ServeHTTP(w, r)
-				return
-			}
+	// Check for session cookie
+	if cookie, err := r.Cookie("session"); err == nil {
+		if username, valid := am.ValidateSession(cookie.Value); valid {
+			return &Principal{Username: username, Scopes: AllScopes}, true
 		}
+	}
 
-		// No valid authentication found
-		w.Header().Set("WWW-Authenticate", `Bearer realm="API", Basic realm="Web"`)
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-	})
+	return nil, false
 }
@@ -0,0 +1,333 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Scope is a permission granted to an API token
+type Scope string
+
+const (
+	ScopeRead   Scope = "read"   // query syslogs, filters and timeline
+	ScopeExport Scope = "export" // download exports
+	ScopeIngest Scope = "ingest" // push messages over HTTP
+	ScopeAdmin  Scope = "admin"  // manage tokens and server settings
+)
+
+// defaultTokenName names the per-user token minted by GetAPIToken
+const defaultTokenName = "default"
+
+// AllScopes lists every known scope
+var AllScopes = []Scope{ScopeRead, ScopeExport, ScopeIngest, ScopeAdmin}
+
+// ParseScope validates a scope name
+func ParseScope(s string) (Scope, error) {
+	for _, scope := range AllScopes {
+		if string(scope) == strings.ToLower(strings.TrimSpace(s)) {
+			return scope, nil
+		}
+	}
+	return "", fmt.Errorf("unknown scope: %s", s)
+}
+
+// Token is a named API token belonging to a user
+// Only the SHA-256 hash of the secret is kept; the plaintext is returned once at creation
+type Token struct {
+	ID         string    `json:"id"`
+	Name       string    `json:"name"`
+	Username   string    `json:"username"`
+	Scopes     []Scope   `json:"scopes"`
+	SecretHash string    `json:"secretHash"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt,omitempty"` // Zero means never expires
+	LastUsedAt time.Time `json:"lastUsedAt,omitempty"`
+	persist    bool      // false for the per-user default token, which is minted again on startup and login
+}
+
+// TokenInfo is the public view of a token (without its hash)
+type TokenInfo struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Username   string     `json:"username"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// HasScope reports whether the token grants the given scope
+// The admin scope implies every other scope
+func (t *Token) HasScope(scope Scope) bool {
+	return hasScope(t.Scopes, scope)
+}
+
+// Expired reports whether the token has expired at the given time
+func (t *Token) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt)
+}
+
+func (t *Token) info() TokenInfo {
+	info := TokenInfo{
+		ID:        t.ID,
+		Name:      t.Name,
+		Username:  t.Username,
+		Scopes:    append([]Scope(nil), t.Scopes...),
+		CreatedAt: t.CreatedAt,
+	}
+	if !t.ExpiresAt.IsZero() {
+		expiresAt := t.ExpiresAt
+		info.ExpiresAt = &expiresAt
+	}
+	if !t.LastUsedAt.IsZero() {
+		lastUsedAt := t.LastUsedAt
+		info.LastUsedAt = &lastUsedAt
+	}
+	return info
+}
+
+func hasScope(scopes []Scope, scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateToken creates a named token for a user and returns its plaintext value
+// A zero ttl creates a token that never expires
+func (am *AuthManager) CreateToken(username, name string, scopes []Scope, ttl time.Duration) (string, TokenInfo, error) {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	plaintext, token, err := am.createTokenLocked(username, name, scopes, ttl)
+	if err != nil {
+		return "", TokenInfo{}, err
+	}
+	token.persist = true
+
+	if err := am.saveTokensLocked(); err != nil {
+		delete(am.tokens, token.ID)
+		return "", TokenInfo{}, err
+	}
+
+	return plaintext, token.info(), nil
+}
+
+func (am *AuthManager) createTokenLocked(username, name string, scopes []Scope, ttl time.Duration) (string, *Token, error) {
+	if _, exists := am.users[username]; !exists {
+		return "", nil, fmt.Errorf("user not found")
+	}
+	if name == "" {
+		return "", nil, fmt.Errorf("token name is required")
+	}
+	if len(scopes) == 0 {
+		return "", nil, fmt.Errorf("at least one scope is required")
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+	secret, err := generateAPIToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to generate API token: %w", err)
+	}
+
+	now := time.Now()
+	token := &Token{
+		ID:         id,
+		Name:       name,
+		Username:   username,
+		Scopes:     dedupeScopes(scopes),
+		SecretHash: hashSecret(secret),
+		CreatedAt:  now,
+	}
+	if ttl > 0 {
+		token.ExpiresAt = now.Add(ttl)
+	}
+
+	am.tokens[id] = token
+	return id + "." + secret, token, nil
+}
+
+// ListTokens returns the tokens of a user, or of every user if username is empty
+func (am *AuthManager) ListTokens(username string) []TokenInfo {
+	am.mu.RLock()
+	defer am.mu.RUnlock()
+
+	tokens := make([]TokenInfo, 0, len(am.tokens))
+	for _, token := range am.tokens {
+		if username == "" || token.Username == username {
+			tokens = append(tokens, token.info())
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+	return tokens
+}
+
+// RevokeToken deletes a token by ID
+func (am *AuthManager) RevokeToken(id string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	token, exists := am.tokens[id]
+	if !exists {
+		return fmt.Errorf("token not found")
+	}
+
+	delete(am.tokens, id)
+	if token.persist {
+		return am.saveTokensLocked()
+	}
+	return nil
+}
+
+// verifyToken checks a plaintext token and returns a copy of it if valid
+func (am *AuthManager) verifyToken(plaintext string) (*Token, bool) {
+	id, secret, ok := strings.Cut(plaintext, ".")
+	if !ok {
+		return nil, false
+	}
+
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	token, exists := am.tokens[id]
+	if !exists {
+		return nil, false
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(token.SecretHash)) != 1 {
+		return nil, false
+	}
+
+	now := time.Now()
+	if token.Expired(now) {
+		return nil, false
+	}
+	if _, exists := am.users[token.Username]; !exists {
+		return nil, false
+	}
+
+	token.LastUsedAt = now
+	am.tokensDirty = am.tokensDirty || token.persist
+
+	tokenCopy := *token
+	return &tokenCopy, true
+}
+
+// SetTokenFile enables persistence of named tokens to a JSON file and loads existing tokens from it
+// Tokens belonging to unknown users are ignored
+func (am *AuthManager) SetTokenFile(path string) error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	am.tokenFile = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	var tokens []*Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return fmt.Errorf("failed to parse token file: %w", err)
+	}
+
+	for _, token := range tokens {
+		if _, exists := am.users[token.Username]; !exists {
+			continue
+		}
+		token.persist = true
+		am.tokens[token.ID] = token
+	}
+
+	return nil
+}
+
+// FlushTokens writes pending last-used updates to the token file
+func (am *AuthManager) FlushTokens() error {
+	am.mu.Lock()
+	defer am.mu.Unlock()
+
+	if !am.tokensDirty {
+		return nil
+	}
+	return am.saveTokensLocked()
+}
+
+// saveTokensLocked writes persistent tokens to the token file (caller must hold the lock)
+func (am *AuthManager) saveTokensLocked() error {
+	if am.tokenFile == "" {
+		return nil
+	}
+
+	tokens := make([]*Token, 0, len(am.tokens))
+	for _, token := range am.tokens {
+		if token.persist {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.Before(tokens[j].CreatedAt)
+	})
+
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode tokens: %w", err)
+	}
+
+	tmpPath := am.tokenFile + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write token file: %w", err)
+	}
+	if err := os.Rename(tmpPath, am.tokenFile); err != nil {
+		return fmt.Errorf("failed to replace token file: %w", err)
+	}
+
+	am.tokensDirty = false
+	return nil
+}
+
+// hashSecret returns the hex-encoded SHA-256 hash of a token secret
+func hashSecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func dedupeScopes(scopes []Scope) []Scope {
+	seen := make(map[Scope]bool)
+	result := make([]Scope, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result
+}
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestManager(t *testing.T) *AuthManager {
+	t.Helper()
+	am := NewAuthManager(true)
+	if err := am.AddUser("admin", "secret"); err != nil {
+		t.Fatalf("AddUser: %v", err)
+	}
+	return am
+}
+
+func TestCreateAndVerifyToken(t *testing.T) {
+	am := newTestManager(t)
+
+	plaintext, info, err := am.CreateToken("admin", "ci", []Scope{ScopeRead, ScopeRead}, 0)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, info.ID+".") || len(info.Scopes) != 1 || info.ExpiresAt != nil {
+		t.Errorf("CreateToken() = %q, %+v, want an ID-prefixed token with one scope and no expiry", plaintext, info)
+	}
+	if strings.Contains(am.tokens[info.ID].SecretHash, strings.TrimPrefix(plaintext, info.ID+".")) {
+		t.Errorf("stored token holds the plaintext secret")
+	}
+
+	if username, ok := am.VerifyAPIToken(plaintext); !ok || username != "admin" {
+		t.Errorf("VerifyAPIToken() = %q, %v, want admin, true", username, ok)
+	}
+	if tokens := am.ListTokens("admin"); len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Errorf("ListTokens() = %+v, want one token with a last used time", tokens)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"wrong secret", info.ID + ".0000"},
+		{"wrong secret of the right length", info.ID + "." + strings.Repeat("a", 64)},
+		{"unknown id", "ffffffffffffffff." + strings.TrimPrefix(plaintext, info.ID+".")},
+		{"no separator", strings.Replace(plaintext, ".", "", 1)},
+		{"empty", ""},
+	}
+	for _, tt := range tests {
+		if _, ok := am.VerifyAPIToken(tt.token); ok {
+			t.Errorf("VerifyAPIToken(%s) = valid, want invalid", tt.name)
+		}
+	}
+
+	for _, bad := range []struct {
+		username, name string
+		scopes         []Scope
+	}{
+		{"nobody", "ci", AllScopes},
+		{"admin", "", AllScopes},
+		{"admin", "ci", nil},
+	} {
+		if _, _, err := am.CreateToken(bad.username, bad.name, bad.scopes, 0); err == nil {
+			t.Errorf("CreateToken(%q, %q, %v) error = nil, want an error", bad.username, bad.name, bad.scopes)
+		}
+	}
+}
+
+func TestRevokeToken(t *testing.T) {
+	am := newTestManager(t)
+
+	plaintext, info, err := am.CreateToken("admin", "ci", AllScopes, 0)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if err := am.RevokeToken(info.ID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, ok := am.VerifyAPIToken(plaintext); ok {
+		t.Errorf("VerifyAPIToken() of a revoked token = valid, want invalid")
+	}
+	if err := am.RevokeToken(info.ID); err == nil {
+		t.Errorf("RevokeToken() twice error = nil, want an error")
+	}
+}
+
+func TestExpiredToken(t *testing.T) {
+	am := newTestManager(t)
+
+	plaintext, info, err := am.CreateToken("admin", "ci", AllScopes, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if info.ExpiresAt == nil {
+		t.Fatalf("ExpiresAt = nil, want an expiry")
+	}
+	if _, ok := am.VerifyAPIToken(plaintext); !ok {
+		t.Errorf("VerifyAPIToken() before expiry = invalid, want valid")
+	}
+
+	am.tokens[info.ID].ExpiresAt = time.Now().Add(-time.Second)
+	if _, ok := am.VerifyAPIToken(plaintext); ok {
+		t.Errorf("VerifyAPIToken() after expiry = valid, want invalid")
+	}
+	am.CleanupExpiredSessions()
+	if tokens := am.ListTokens(""); len(tokens) != 0 {
+		t.Errorf("ListTokens() after cleanup = %+v, want none", tokens)
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	am := newTestManager(t)
+	readToken, _, err := am.CreateToken("admin", "grafana", []Scope{ScopeRead}, 0)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	adminToken, _, err := am.CreateToken("admin", "ops", []Scope{ScopeAdmin}, 0)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	handler := func(scope Scope) http.Handler {
+		return am.RequireScope(scope, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if principal, ok := PrincipalFromContext(r.Context()); !ok || principal.Username != "admin" {
+				t.Errorf("principal = %+v, want admin", principal)
+			}
+		}))
+	}
+
+	tests := []struct {
+		name  string
+		scope Scope
+		auth  string
+		want  int
+	}{
+		{"read token on read", ScopeRead, "Bearer " + readToken, http.StatusOK},
+		{"read token on export", ScopeExport, "Bearer " + readToken, http.StatusForbidden},
+		{"read token on ingest", ScopeIngest, "Bearer " + readToken, http.StatusForbidden},
+		{"admin token on export", ScopeExport, "Bearer " + adminToken, http.StatusOK},
+		{"read token on any scope", "", "Bearer " + readToken, http.StatusOK},
+		{"basic auth", ScopeAdmin, "Basic YWRtaW46c2VjcmV0", http.StatusOK},
+		{"wrong password", ScopeRead, "Basic YWRtaW46d3Jvbmc=", http.StatusUnauthorized},
+		{"no credentials", ScopeRead, "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/syslogs", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		rec := httptest.NewRecorder()
+		handler(tt.scope).ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestGetAPIToken(t *testing.T) {
+	am := newTestManager(t)
+
+	first, err := am.GetAPIToken("admin")
+	if err != nil {
+		t.Fatalf("GetAPIToken: %v", err)
+	}
+	if _, ok := am.VerifyAPIToken(first); !ok {
+		t.Errorf("VerifyAPIToken() of the default token = invalid, want valid")
+	}
+
+	// A revoked default token is never handed out again
+	id, _, _ := strings.Cut(first, ".")
+	if err := am.RevokeToken(id); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	second, err := am.GetAPIToken("admin")
+	if err != nil {
+		t.Fatalf("GetAPIToken: %v", err)
+	}
+	if second == first {
+		t.Errorf("GetAPIToken() after revocation returned the revoked token")
+	}
+	if _, ok := am.VerifyAPIToken(second); !ok {
+		t.Errorf("VerifyAPIToken() of the new default token = invalid, want valid")
+	}
+
+	// Minting replaces the previous default token
+	third, err := am.GetAPIToken("admin")
+	if err != nil {
+		t.Fatalf("GetAPIToken: %v", err)
+	}
+	if _, ok := am.VerifyAPIToken(second); ok {
+		t.Errorf("VerifyAPIToken() of a replaced default token = valid, want invalid")
+	}
+	if tokens := am.ListTokens("admin"); len(tokens) != 1 || !strings.HasPrefix(third, tokens[0].ID+".") {
+		t.Errorf("ListTokens() = %+v, want only the last default token", tokens)
+	}
+
+	if _, err := am.GetAPIToken("nobody"); err == nil {
+		t.Errorf("GetAPIToken() of an unknown user error = nil, want an error")
+	}
+}
+
+func TestTokenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+
+	am := newTestManager(t)
+	if err := am.SetTokenFile(path); err != nil {
+		t.Fatalf("SetTokenFile: %v", err)
+	}
+	if _, err := am.GetAPIToken("admin"); err != nil {
+		t.Fatalf("GetAPIToken: %v", err)
+	}
+	plaintext, _, err := am.CreateToken("admin", "ci", []Scope{ScopeIngest}, 0)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	// Only named tokens survive a restart
+	restarted := newTestManager(t)
+	if err := restarted.SetTokenFile(path); err != nil {
+		t.Fatalf("SetTokenFile: %v", err)
+	}
+	if tokens := restarted.ListTokens(""); len(tokens) != 1 || tokens[0].Name != "ci" {
+		t.Errorf("ListTokens() after restart = %+v, want the ci token", tokens)
+	}
+	if _, ok := restarted.VerifyAPIToken(plaintext); !ok {
+		t.Errorf("VerifyAPIToken() after restart = invalid, want valid")
+	}
+}
+
+func TestSetUsers(t *testing.T) {
+	am := newTestManager(t)
+	hash := func(password string) string {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatalf("GenerateFromPassword: %v", err)
+		}
+		return string(h)
+	}
+
+	bob := UserConfig{Username: "bob", PasswordHash: hash("first")}
+	carol := UserConfig{Username: "carol", PasswordHash: hash("secret")}
+	if err := am.SetUsers([]UserConfig{bob, carol}); err != nil {
+		t.Fatalf("SetUsers: %v", err)
+	}
+	if !am.VerifyPassword("bob", "first") || !am.VerifyPassword("carol", "secret") {
+		t.Errorf("VerifyPassword() of configured users = false, want true")
+	}
+	bobSession, _ := am.CreateSession("bob")
+	carolSession, _ := am.CreateSession("carol")
+	carolToken, _, err := am.CreateToken("carol", "ci", AllScopes, 0)
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+
+	// Bob changes password and carol is removed; admin, from the command line, stays
+	bob.PasswordHash = hash("second")
+	if err := am.SetUsers([]UserConfig{bob}); err != nil {
+		t.Fatalf("SetUsers: %v", err)
+	}
+	if am.VerifyPassword("bob", "first") || !am.VerifyPassword("bob", "second") {
+		t.Errorf("VerifyPassword() after a password change accepts the old password or refuses the new one")
+	}
+	if _, ok := am.ValidateSession(bobSession); ok {
+		t.Errorf("ValidateSession() after a password change = valid, want invalid")
+	}
+	if _, ok := am.ValidateSession(carolSession); ok {
+		t.Errorf("ValidateSession() of a removed user = valid, want invalid")
+	}
+	if _, ok := am.VerifyAPIToken(carolToken); ok {
+		t.Errorf("VerifyAPIToken() of a removed user = valid, want invalid")
+	}
+	if !am.VerifyPassword("admin", "secret") {
+		t.Errorf("VerifyPassword() of a command-line user = false, want true")
+	}
+
+	admin := UserConfig{Username: "admin", PasswordHash: hash("other")}
+	if err := am.SetUsers([]UserConfig{bob, admin}); err == nil {
+		t.Errorf("SetUsers() with a command-line user error = nil, want an error")
+	}
+	if !am.VerifyPassword("admin", "secret") || !am.VerifyPassword("bob", "second") {
+		t.Errorf("rejected SetUsers() changed the users")
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+
+	tests := []struct {
+		name    string
+		users   []UserConfig
+		wantErr bool
+	}{
+		{"valid", []UserConfig{{"bob", string(hash)}}, false},
+		{"no username", []UserConfig{{"", string(hash)}}, true},
+		{"duplicate", []UserConfig{{"bob", string(hash)}, {"bob", string(hash)}}, true},
+		{"plaintext password", []UserConfig{{"bob", "secret"}}, true},
+	}
+	for _, tt := range tests {
+		if err := (Config{Users: tt.users}).Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// errSourceDenied is recorded on messages refused by the allow and deny lists
+var errSourceDenied = errors.New("source address not allowed on this listener")
+
+// ExpectedHost is the hostname that senders at the given addresses must use
+type ExpectedHost struct {
+	Hostname  string   `yaml:"hostname"`
+	Addresses []string `yaml:"addresses"` // IP addresses or CIDR ranges
+}
+
+// expectedHost is a compiled ExpectedHost
+type expectedHost struct {
+	hostname string
+	networks []*net.IPNet
+}
+
+// sourceFilter checks source addresses against the allow and deny lists of a listener
+// and knows the hostname expected from each address
+type sourceFilter struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+	hosts []expectedHost
+}
+
+// newSourceFilter compiles the lists; it returns nil when there is nothing to check
+func newSourceFilter(allow, deny []string, hosts []ExpectedHost) (*sourceFilter, error) {
+	if len(allow) == 0 && len(deny) == 0 && len(hosts) == 0 {
+		return nil, nil
+	}
+
+	f := &sourceFilter{}
+	var err error
+	if f.allow, err = parseNetworks(allow); err != nil {
+		return nil, fmt.Errorf("invalid allow list: %w", err)
+	}
+	if f.deny, err = parseNetworks(deny); err != nil {
+		return nil, fmt.Errorf("invalid deny list: %w", err)
+	}
+	for _, host := range hosts {
+		if host.Hostname == "" {
+			return nil, fmt.Errorf("expected host without hostname")
+		}
+		networks, err := parseNetworks(host.Addresses)
+		if err != nil {
+			return nil, fmt.Errorf("expected host %s: %w", host.Hostname, err)
+		}
+		f.hosts = append(f.hosts, expectedHost{hostname: host.Hostname, networks: networks})
+	}
+	return f, nil
+}
+
+// parseNetworks parses IP addresses and CIDR ranges
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		if _, network, err := net.ParseCIDR(value); err == nil {
+			networks = append(networks, network)
+			continue
+		}
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return nil, fmt.Errorf("not an IP address or CIDR range: %q", value)
+		}
+		bits := 8 * net.IPv6len
+		if ip4 := ip.To4(); ip4 != nil {
+			ip, bits = ip4, 8*net.IPv4len
+		}
+		networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+	}
+	return networks, nil
+}
+
+// allowed reports whether a source host may send to the listener
+// The deny list wins; with an allow list, only the addresses it contains are allowed
+func (f *sourceFilter) allowed(host string) bool {
+	if len(f.allow) == 0 && len(f.deny) == 0 {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if containsIP(f.deny, ip) {
+		return false
+	}
+	return len(f.allow) == 0 || containsIP(f.allow, ip)
+}
+
+// expectedHostname returns the hostname expected from a source host, if any
+func (f *sourceFilter) expectedHostname(host string) (string, bool) {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", false
+	}
+	for _, h := range f.hosts {
+		if containsIP(h.networks, ip) {
+			return h.hostname, true
+		}
+	}
+	return "", false
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostnameMatches compares hostnames case-insensitively, accepting the short form of
+// a fully qualified name ("fw1" for "fw1.example.com") on either side
+func hostnameMatches(hostname, expected string) bool {
+	if strings.EqualFold(hostname, expected) {
+		return true
+	}
+	short := func(name string) string {
+		if i := strings.IndexByte(name, '.'); i >= 0 && net.ParseIP(name) == nil {
+			return name[:i]
+		}
+		return name
+	}
+	return strings.EqualFold(short(hostname), short(expected)) &&
+		(!strings.Contains(hostname, ".") || !strings.Contains(expected, "."))
+}
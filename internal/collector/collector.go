@@ -1,26 +1,105 @@
 package collector
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syslog-visualizer/internal/framing"
 	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/internal/proxyproto"
+	"time"
 )
 
 // MessageHandler is called for each received syslog message
 type MessageHandler func(*parser.SyslogMessage) error
 
+// Reject describes a message that could not be decoded
+type Reject struct {
+	Listener   string
+	Protocol   string
+	Source     string
+	Raw        []byte
+	Error      string
+	ReceivedAt time.Time
+}
+
+// RejectHandler is called for each message that could not be decoded
+type RejectHandler func(*Reject)
+
+// Stats holds the message counters of a collector
+type Stats struct {
+	Name      string `json:"name"`
+	Protocol  string `json:"protocol"`
+	Address   string `json:"address"`
+	Received  int64  `json:"received"`
+	Rejected  int64  `json:"rejected"`
+	Truncated int64  `json:"truncated"`
+
+	Connections         int              `json:"connections"`                // Open stream connections
+	ConnectionsRejected int64            `json:"connectionsRejected"`        // Connections refused by a connection limit
+	TimedOut            int64            `json:"timedOut"`                   // Connections closed by the idle or read timeout
+	Throttled           int64            `json:"throttled"`                  // Messages dropped by the rate limit
+	ThrottledSources    map[string]int64 `json:"throttledSources,omitempty"` // Messages dropped by the rate limit per source
+	Denied              int64            `json:"denied"`                     // Messages (or stream connections) refused by the source lists
+	HostnameMismatches  int64            `json:"hostnameMismatches"`         // Messages whose hostname differs from the expected one
+	ProxyErrors         int64            `json:"proxyErrors"`                // Proxied connections closed for a missing or invalid PROXY header
+	KernelDrops         int64            `json:"kernelDrops"`                // Datagrams dropped by the kernel, e.g. with a full receive buffer (Linux)
+}
+
+// proxyHeaderTimeout bounds the wait for the PROXY protocol header of a connection
+const proxyHeaderTimeout = 10 * time.Second
+
 // Collector represents a syslog collector that listens for incoming messages
 type Collector struct {
+	name           string
 	address        string
 	protocol       string
 	framingMethod  framing.FramingMethod
 	handler        MessageHandler
-	udpConn        *net.UDPConn
+	rejectHandler  RejectHandler
+	received       atomic.Int64
+	rejected       atomic.Int64
+	truncated      atomic.Int64
+	connRejected   atomic.Int64
+	timedOut       atomic.Int64
+	denied         atomic.Int64
+	mismatches     atomic.Int64
+	sources        *sourceFilter
+	proxyProtocol  bool
+	trustedProxies []*net.IPNet
+	proxyErrors    atomic.Int64
+	recordDenied   bool
+	conns          connLimiter
+	limiter        *rateLimiter
+	idleTimeout    time.Duration
+	readTimeout    time.Duration
+	wg             sync.WaitGroup // Start, readers, workers and connections, waited for by Stop
+	stopMu         sync.Mutex
+	stopping       bool
+	halt           chan struct{} // Closed when Stop gives up waiting
+	haltOnce       sync.Once
+	pending        atomic.Int64 // Messages received and not yet handled
+	active         map[net.Conn]struct{}
+	udpMu          sync.Mutex
+	udpConns       []*net.UDPConn
+	udpInodes      []uint64
+	readers        int
+	workers        int
+	receiveBuffer  int
 	tcpListener    net.Listener
+	unixConn       *net.UnixConn
+	unixListener   net.Listener
+	socketMode     os.FileMode
+	parseOptions   atomic.Pointer[parser.Options]
 	ctx            context.Context
 	cancel         context.CancelFunc
 	maxMessageSize int
@@ -28,11 +107,45 @@ type Collector struct {
 
 // Config holds the collector configuration
 type Config struct {
-	Address        string                // Listen address (e.g., "0.0.0.0:514" or ":514")
-	Protocol       string                // "udp", "tcp", or "both"
-	FramingMethod  framing.FramingMethod // For TCP: OctetCounting or NonTransparent
+	Name           string                // Listener name used in logs
+	Address        string                // Listen address (e.g., "0.0.0.0:514" or ":514"), or socket path for unix protocols
+	Protocol       string                // "udp", "tcp", "both", "relp", "gelf-udp", "gelf-tcp", "unixgram" (e.g. /dev/log) or "unix" (stream)
+	FramingMethod  framing.FramingMethod // For TCP: OctetCounting, NonTransparent or Auto (detected per connection)
 	Handler        MessageHandler        // Callback for each message
+	RejectHandler  RejectHandler         // Callback for each message that could not be decoded
 	MaxMessageSize int                   // Maximum message size in bytes (default 8192)
+	SocketMode     os.FileMode           // Permissions of unix sockets (default 0666)
+	Lenient        bool                  // Accept non-compliant RFC 3164 messages (see parser.Options)
+
+	Timezones        *parser.TimezoneMap // Timezone of senders whose timestamps carry none (default time.Local)
+	TrustReceiveTime bool                // Timestamp messages with their reception time
+	MaxClockSkew     time.Duration       // Flag messages whose timestamp is further than this from reception (0 disables)
+
+	MaxConnections          int           // Concurrent stream connections (0 for no limit)
+	MaxConnectionsPerSource int           // Concurrent stream connections per source address (0 for no limit)
+	IdleTimeout             time.Duration // Close connections with no message for this long (0 disables)
+	ReadTimeout             time.Duration // Close connections that take this long to finish a message (0 disables)
+	RateLimit               RateLimit     // Per-source message rate limit
+
+	Allow        []string       // Source IPs or CIDR ranges allowed to send (empty for any)
+	Deny         []string       // Source IPs or CIDR ranges refused, even when allowed
+	RecordDenied bool           // Pass refused messages to the RejectHandler
+	Hosts        []ExpectedHost // Hostnames expected from source addresses; others are flagged
+
+	ProxyProtocol  bool     // Stream connections from TrustedProxies start with a PROXY protocol v1/v2 header
+	TrustedProxies []string // Proxy IPs or CIDR ranges; required with ProxyProtocol
+
+	Readers       int // UDP sockets sharing the port with SO_REUSEPORT, each with a reader (default 1)
+	Workers       int // UDP parse workers (0 parses on the readers)
+	ReceiveBuffer int // Socket receive buffer size in bytes (0 for the system default)
+}
+
+// source describes where a raw message came from
+type source struct {
+	addr      string
+	peer      *peerCredentials // Sender credentials of local sockets, nil if unknown
+	truncated bool             // The message was cut at the maximum size
+	ack       bool             // The sender is acknowledged once the message is committed
 }
 
 // New creates a new Collector instance
@@ -46,22 +159,166 @@ func New(cfg Config) (*Collector, error) {
 	if cfg.MaxMessageSize == 0 {
 		cfg.MaxMessageSize = 8192
 	}
+	if cfg.SocketMode == 0 {
+		cfg.SocketMode = 0666
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.Protocol + ":" + cfg.Address
+	}
+	if cfg.Readers == 0 {
+		cfg.Readers = 1
+	}
+	if cfg.Readers < 0 || cfg.Workers < 0 || cfg.ReceiveBuffer < 0 {
+		return nil, fmt.Errorf("readers, workers and receive buffer must not be negative")
+	}
+
+	protocol := strings.ToLower(cfg.Protocol)
+
+	if err := cfg.RateLimit.Validate(); err != nil {
+		return nil, err
+	}
+	sources, err := newSourceFilter(cfg.Allow, cfg.Deny, cfg.Hosts)
+	if err != nil {
+		return nil, err
+	}
+	trustedProxies, err := parseNetworks(cfg.TrustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	if cfg.ProxyProtocol && len(trustedProxies) == 0 {
+		return nil, fmt.Errorf("PROXY protocol needs trusted proxies")
+	}
+
+	parseOptions, err := parseOptionsFor(protocol)
+	if err != nil {
+		return nil, err
+	}
+	parseOptions.Lenient = cfg.Lenient
+	parseOptions.Timezones = cfg.Timezones
+	parseOptions.TrustReceiveTime = cfg.TrustReceiveTime
+	parseOptions.MaxClockSkew = cfg.MaxClockSkew
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Collector{
+	c := &Collector{
+		name:           cfg.Name,
 		address:        cfg.Address,
-		protocol:       strings.ToLower(cfg.Protocol),
+		protocol:       protocol,
 		framingMethod:  cfg.FramingMethod,
 		handler:        cfg.Handler,
+		rejectHandler:  cfg.RejectHandler,
+		socketMode:     cfg.SocketMode,
 		ctx:            ctx,
 		cancel:         cancel,
+		halt:           make(chan struct{}),
+		active:         make(map[net.Conn]struct{}),
 		maxMessageSize: cfg.MaxMessageSize,
-	}, nil
+		conns:          connLimiter{max: cfg.MaxConnections, perSource: cfg.MaxConnectionsPerSource},
+		limiter:        newRateLimiter(cfg.RateLimit),
+		idleTimeout:    cfg.IdleTimeout,
+		readTimeout:    cfg.ReadTimeout,
+		sources:        sources,
+		recordDenied:   cfg.RecordDenied,
+		proxyProtocol:  cfg.ProxyProtocol,
+		trustedProxies: trustedProxies,
+		readers:        cfg.Readers,
+		workers:        cfg.Workers,
+		receiveBuffer:  cfg.ReceiveBuffer,
+	}
+	c.parseOptions.Store(&parseOptions)
+	return c, nil
+}
+
+// Name returns the listener name
+func (c *Collector) Name() string {
+	return c.name
+}
+
+// SetTimeOptions changes how the timestamps of the messages received from now on
+// are interpreted
+func (c *Collector) SetTimeOptions(timezones *parser.TimezoneMap, trustReceiveTime bool, maxClockSkew time.Duration) {
+	opts := *c.parseOptions.Load()
+	opts.Timezones = timezones
+	opts.TrustReceiveTime = trustReceiveTime
+	opts.MaxClockSkew = maxClockSkew
+	c.parseOptions.Store(&opts)
+}
+
+// Stats returns the message counters
+func (c *Collector) Stats() Stats {
+	stats := Stats{
+		Name:      c.name,
+		Protocol:  c.protocol,
+		Address:   c.address,
+		Received:  c.received.Load(),
+		Rejected:  c.rejected.Load(),
+		Truncated: c.truncated.Load(),
+
+		Connections:         c.conns.connections(),
+		ConnectionsRejected: c.connRejected.Load(),
+		TimedOut:            c.timedOut.Load(),
+		Denied:              c.denied.Load(),
+		HostnameMismatches:  c.mismatches.Load(),
+		ProxyErrors:         c.proxyErrors.Load(),
+		KernelDrops:         c.kernelDrops(),
+	}
+	if c.limiter != nil {
+		stats.Throttled = c.limiter.total.Load()
+		stats.ThrottledSources = c.limiter.counts()
+	}
+	return stats
+}
+
+// parseOptionsFor returns the parser options used for a protocol
+func parseOptionsFor(protocol string) (parser.Options, error) {
+	var parseOptions parser.Options
+	if protocol == "unixgram" || protocol == "unix" {
+		// Local senders omit the hostname, so use ours
+		hostname, err := os.Hostname()
+		if err != nil {
+			return parseOptions, fmt.Errorf("failed to get local hostname: %w", err)
+		}
+		parseOptions.DefaultHostname = hostname
+	}
+	return parseOptions, nil
+}
+
+// Reparse decodes a rejected message again with this listener's current options
+func (c *Collector) Reparse(raw []byte, sourceHost string) (*parser.SyslogMessage, error) {
+	if c.protocol == "gelf-udp" || c.protocol == "gelf-tcp" {
+		return Reparse(c.protocol, raw)
+	}
+
+	opts := *c.parseOptions.Load()
+	opts.SourceHost = sourceHost
+	return parser.ParseWithOptions(string(raw), opts)
+}
+
+// Reparse decodes a rejected message again, the way a listener of the given protocol would
+func Reparse(protocol string, raw []byte) (*parser.SyslogMessage, error) {
+	if protocol == "gelf-udp" || protocol == "gelf-tcp" {
+		payload, err := decompressGELF(raw)
+		if err != nil {
+			return nil, err
+		}
+		return parser.ParseGELF(payload)
+	}
+
+	parseOptions, err := parseOptionsFor(protocol)
+	if err != nil {
+		return nil, err
+	}
+	return parser.ParseWithOptions(string(raw), parseOptions)
 }
 
 // Start begins listening for syslog messages
+// It returns once the listener has stopped
 func (c *Collector) Start() error {
+	if !c.track() {
+		return nil
+	}
+	defer c.wg.Done()
+
 	switch c.protocol {
 	case "udp":
 		return c.startUDP()
@@ -74,63 +331,41 @@ func (c *Collector) Start() error {
 		go func() {
 			if err := c.startUDP(); err != nil {
 				errChan <- fmt.Errorf("UDP error: %w", err)
+				return
 			}
+			errChan <- nil
 		}()
 
 		go func() {
 			if err := c.startTCP(); err != nil {
 				errChan <- fmt.Errorf("TCP error: %w", err)
+				return
 			}
+			errChan <- nil
 		}()
 
-		// Wait for either goroutine to fail or context cancellation
-		select {
-		case err := <-errChan:
-			return err
-		case <-c.ctx.Done():
-			return nil
+		// When one side fails, stop the other one too, and wait for both
+		err := <-errChan
+		if err != nil {
+			c.cancel()
+			c.closeListeners()
 		}
-	default:
-		return fmt.Errorf("unsupported protocol: %s (use 'udp', 'tcp', or 'both')", c.protocol)
-	}
-}
-
-// startUDP starts the UDP listener
-func (c *Collector) startUDP() error {
-	addr, err := net.ResolveUDPAddr("udp", c.address)
-	if err != nil {
-		return fmt.Errorf("failed to resolve UDP address: %w", err)
-	}
-
-	conn, err := net.ListenUDP("udp", addr)
-	if err != nil {
-		return fmt.Errorf("failed to start UDP listener: %w", err)
-	}
-	c.udpConn = conn
-
-	log.Printf("UDP syslog collector listening on %s", c.address)
-
-	// Read messages in a loop
-	buffer := make([]byte, c.maxMessageSize)
-	for {
-		select {
-		case <-c.ctx.Done():
-			return nil
-		default:
-			n, remoteAddr, err := conn.ReadFromUDP(buffer)
-			if err != nil {
-				if c.ctx.Err() != nil {
-					// Collector is stopping
-					return nil
-				}
-				log.Printf("UDP read error: %v", err)
-				continue
-			}
-
-			// Process the message
-			raw := string(buffer[:n])
-			c.processMessage(raw, remoteAddr.String())
+		if otherErr := <-errChan; err == nil {
+			err = otherErr
 		}
+		return err
+	case "unixgram":
+		return c.startUnixgram()
+	case "unix":
+		return c.startUnixStream()
+	case "relp":
+		return c.startRELP()
+	case "gelf-udp":
+		return c.startGELFUDP()
+	case "gelf-tcp":
+		return c.startGELFTCP()
+	default:
+		return fmt.Errorf("unsupported protocol: %s (use 'udp', 'tcp', 'both', 'unixgram', 'unix', 'relp', 'gelf-udp' or 'gelf-tcp')", c.protocol)
 	}
 }
 
@@ -140,7 +375,11 @@ func (c *Collector) startTCP() error {
 	if err != nil {
 		return fmt.Errorf("failed to start TCP listener: %w", err)
 	}
-	c.tcpListener = listener
+	if !c.register(func() { c.tcpListener = listener }) {
+		// Stopped while starting
+		listener.Close()
+		return nil
+	}
 
 	log.Printf("TCP syslog collector listening on %s (framing: %v)", c.address, c.framingMethod)
 
@@ -161,7 +400,7 @@ func (c *Collector) startTCP() error {
 			}
 
 			// Handle connection in a goroutine
-			go c.handleTCPConnection(conn)
+			c.accept(conn, c.handleTCPConnection)
 		}
 	}
 }
@@ -173,67 +412,262 @@ func (c *Collector) handleTCPConnection(conn net.Conn) {
 	remoteAddr := conn.RemoteAddr().String()
 	log.Printf("New TCP connection from %s", remoteAddr)
 
-	reader := framing.NewReader(conn, c.framingMethod)
+	deadlines := c.newDeadlineReader(conn)
+	reader := framing.NewReader(deadlines, c.framingMethod)
 	reader.SetMaxSize(c.maxMessageSize)
 
+	// When the collector stops, messages already received are still processed and the
+	// next read ends the connection (see deadlineReader)
 	for {
-		select {
-		case <-c.ctx.Done():
-			return
-		default:
-			raw, err := reader.ReadMessage()
-			if err != nil {
-				if c.ctx.Err() != nil {
-					// Collector is stopping
-					return
-				}
-				log.Printf("TCP read error from %s: %v", remoteAddr, err)
+		raw, err := reader.ReadMessage()
+		if err != nil {
+			if c.ctx.Err() != nil {
+				// Collector is stopping
 				return
 			}
+			if !errors.Is(err, io.EOF) {
+				log.Printf("TCP read error from %s: %v", remoteAddr, err)
+			}
+			return
+		}
 
-			c.processMessage(raw, remoteAddr)
+		deadlines.next()
+
+		if reader.Truncated() {
+			log.Printf("Truncated message from %s to %d bytes", remoteAddr, c.maxMessageSize)
 		}
+		c.processMessage(raw, source{addr: remoteAddr, truncated: reader.Truncated()})
 	}
 }
 
 // processMessage parses and handles a raw syslog message
-func (c *Collector) processMessage(raw string, remoteAddr string) {
+// Returns the handler error, if any, so callers that acknowledge delivery (RELP)
+// can report it; unparseable messages are logged and dropped
+func (c *Collector) processMessage(raw string, src source) error {
+	c.received.Add(1)
+	c.pending.Add(1)
+	defer c.pending.Add(-1)
+
+	if !c.allowed(src) {
+		c.deny([]byte(raw), src)
+		return nil
+	}
+	admitted, sampled := c.admit(src)
+	if !admitted {
+		return nil
+	}
+
+	opts := *c.parseOptions.Load()
+	opts.SourceHost = sourceHost(src.addr)
+	opts.ReceivedAt = time.Now()
+
 	// Parse the message
-	msg, err := parser.Parse(raw)
+	msg, err := parser.ParseWithOptions(raw, opts)
 	if err != nil {
-		log.Printf("Failed to parse message from %s: %v (raw: %q)", remoteAddr, err, raw)
-		return
+		c.reject([]byte(raw), src, err)
+		return nil
 	}
 
-	// Call the handler if one is configured
-	if c.handler != nil {
-		if err := c.handler(msg); err != nil {
-			log.Printf("Handler error for message from %s: %v", remoteAddr, err)
+	msg.Acknowledged = src.ack
+	if src.truncated {
+		c.truncated.Add(1)
+		msg.ParseWarnings = append(msg.ParseWarnings, parser.WarningTruncated)
+	}
+	if sampled {
+		msg.ParseWarnings = append(msg.ParseWarnings, parser.WarningSampled)
+	}
+	c.checkHostname(msg, src)
+
+	if src.peer != nil {
+		uid := src.peer.uid
+		msg.PeerPID = src.peer.pid
+		msg.PeerUID = &uid
+		if msg.PID == "" {
+			msg.PID = strconv.Itoa(src.peer.pid)
 		}
 	}
+
+	return c.dispatch(msg, src)
 }
 
-// Stop gracefully stops the collector
-func (c *Collector) Stop() error {
-	log.Println("Stopping syslog collector...")
+// accept hands a connection to handle in its own goroutine, unless its source is
+// refused or the listener or the source already has the maximum number of connections
+// open. Connections from trusted proxies first announce their client (PROXY protocol)
+func (c *Collector) accept(conn net.Conn, handle func(net.Conn)) {
+	if !c.track() {
+		conn.Close()
+		return
+	}
+	c.addConn(conn)
+	done := func() {
+		c.removeConn(conn)
+		c.wg.Done()
+	}
 
-	// Cancel context to stop all goroutines
-	c.cancel()
+	if c.proxyProtocol && containsIP(c.trustedProxies, net.ParseIP(connHost(conn))) {
+		go func() {
+			defer done()
+			proxied, err := c.readProxyHeader(conn)
+			if err != nil {
+				c.proxyErrors.Add(1)
+				log.Printf("Closing connection from proxy %s on %s: %v", conn.RemoteAddr(), c.name, err)
+				conn.Close()
+				return
+			}
+			if host, ok := c.admitConn(proxied); ok {
+				defer c.conns.release(host)
+				handle(proxied)
+			}
+		}()
+		return
+	}
 
-	// Close UDP connection
-	if c.udpConn != nil {
-		if err := c.udpConn.Close(); err != nil {
-			return fmt.Errorf("failed to close UDP connection: %w", err)
-		}
+	host, ok := c.admitConn(conn)
+	if !ok {
+		done()
+		return
+	}
+	go func() {
+		defer done()
+		defer c.conns.release(host)
+		handle(conn)
+	}()
+}
+
+// admitConn applies the source lists and connection limits to a new connection and
+// closes it when refused; otherwise its slot must be released with c.conns.release(host)
+func (c *Collector) admitConn(conn net.Conn) (string, bool) {
+	host := connHost(conn)
+	if c.sources != nil && !c.sources.allowed(host) {
+		c.denied.Add(1)
+		conn.Close()
+		return host, false
+	}
+	if !c.conns.acquire(host) {
+		c.connRejected.Add(1)
+		log.Printf("Rejected connection from %s on %s: connection limit reached", host, c.name)
+		conn.Close()
+		return host, false
+	}
+	return host, true
+}
+
+// readProxyHeader reads the PROXY protocol header sent by a trusted proxy
+func (c *Collector) readProxyHeader(conn net.Conn) (net.Conn, error) {
+	conn.SetReadDeadline(time.Now().Add(proxyHeaderTimeout))
+	reader := bufio.NewReader(conn)
+	header, err := proxyproto.Read(reader)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+	return proxyproto.NewConn(conn, reader, header.Source), nil
+}
+
+// newDeadlineReader applies the listener's idle and read timeouts to conn
+func (c *Collector) newDeadlineReader(conn net.Conn) *deadlineReader {
+	return &deadlineReader{
+		ctx:      c.ctx,
+		conn:     conn,
+		idle:     c.idleTimeout,
+		read:     c.readTimeout,
+		timedOut: &c.timedOut,
+	}
+}
+
+// admit applies the rate limit of the message's source
+// Messages over the limit are dropped, or kept and flagged when sampled
+func (c *Collector) admit(src source) (admitted, sampled bool) {
+	if c.limiter == nil {
+		return true, false
+	}
+	return c.limiter.allow(sourceHost(src.addr), time.Now())
+}
+
+// allowed checks the source of a message against the allow and deny lists
+func (c *Collector) allowed(src source) bool {
+	return c.sources == nil || c.sources.allowed(sourceHost(src.addr))
+}
+
+// deny counts a message refused by the source lists and records it when configured
+// Refusals are not logged, as a flood of them would flood the log too
+func (c *Collector) deny(raw []byte, src source) {
+	c.denied.Add(1)
+
+	if c.recordDenied && c.rejectHandler != nil {
+		c.rejectHandler(&Reject{
+			Listener:   c.name,
+			Protocol:   c.protocol,
+			Source:     sourceHost(src.addr),
+			Raw:        raw,
+			Error:      errSourceDenied.Error(),
+			ReceivedAt: time.Now(),
+		})
+	}
+}
+
+// checkHostname flags messages whose hostname is not the one expected from their source
+func (c *Collector) checkHostname(msg *parser.SyslogMessage, src source) {
+	if c.sources == nil {
+		return
+	}
+	expected, ok := c.sources.expectedHostname(sourceHost(src.addr))
+	if !ok || hostnameMatches(msg.Hostname, expected) {
+		return
+	}
+	c.mismatches.Add(1)
+	msg.ParseWarnings = append(msg.ParseWarnings, parser.WarningHostnameMismatch)
+	msg.SetField("expected_hostname", expected)
+}
+
+// connHost returns the source host of a connection ("" for unnamed unix peers)
+func connHost(conn net.Conn) string {
+	if addr := conn.RemoteAddr(); addr != nil {
+		return sourceHost(addr.String())
+	}
+	return ""
+}
+
+// reject counts an undecodable message and hands it to the reject handler
+func (c *Collector) reject(raw []byte, src source, err error) {
+	c.rejected.Add(1)
+	// The raw message is left out: it is only kept, redacted, by the reject handler
+	log.Printf("Failed to parse message from %s: %v (%d bytes)", src.addr, err, len(raw))
+
+	if c.rejectHandler != nil {
+		// Keep the host only so that rejects from a device group together across source ports
+		c.rejectHandler(&Reject{
+			Listener:   c.name,
+			Protocol:   c.protocol,
+			Source:     sourceHost(src.addr),
+			Raw:        raw,
+			Error:      err.Error(),
+			ReceivedAt: time.Now(),
+		})
 	}
+}
+
+// sourceHost strips the port from a remote address
+func sourceHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+// dispatch passes an already decoded message to the handler
+func (c *Collector) dispatch(msg *parser.SyslogMessage, src source) error {
+	remoteAddr := src.addr
+	msg.Listener = c.name
+	msg.SourceHost = sourceHost(remoteAddr)
 
-	// Close TCP listener
-	if c.tcpListener != nil {
-		if err := c.tcpListener.Close(); err != nil {
-			return fmt.Errorf("failed to close TCP listener: %w", err)
+	// Call the handler if one is configured
+	if c.handler != nil {
+		if err := c.handler(msg); err != nil {
+			log.Printf("Handler error for message from %s: %v", remoteAddr, err)
+			return err
 		}
 	}
 
-	log.Println("Syslog collector stopped")
 	return nil
 }
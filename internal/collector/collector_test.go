@@ -0,0 +1,444 @@
+package collector
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/framing"
+	"syslog-visualizer/internal/parser"
+)
+
+func TestRejectAndReparse(t *testing.T) {
+	var rejects []*Reject
+	c, err := New(Config{
+		Name:          "test",
+		Protocol:      "udp",
+		RejectHandler: func(r *Reject) { rejects = append(rejects, r) },
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	c.processMessage("no priority here", source{addr: "192.0.2.10:51514"})
+	c.processMessage("<13>Oct 11 22:14:15 host app: ok", source{addr: "192.0.2.10:51515"})
+
+	if len(rejects) != 1 {
+		t.Fatalf("got %d rejects, want 1", len(rejects))
+	}
+	reject := rejects[0]
+	if reject.Listener != "test" || reject.Source != "192.0.2.10" || string(reject.Raw) != "no priority here" || reject.Error == "" {
+		t.Errorf("reject = %+v", reject)
+	}
+	if stats := c.Stats(); stats.Received != 2 || stats.Rejected != 1 {
+		t.Errorf("Stats() = %+v, want 2 received, 1 rejected", stats)
+	}
+
+	if _, err := Reparse(reject.Protocol, reject.Raw); err == nil {
+		t.Errorf("Reparse() of an invalid message should fail")
+	}
+	msg, err := Reparse("udp", []byte("<13>Oct 11 22:14:15 host app: fixed"))
+	if err != nil || msg.Message != "fixed" {
+		t.Errorf("Reparse() = %+v, %v", msg, err)
+	}
+}
+
+func TestTCPFramingPerConnection(t *testing.T) {
+	messages := make(chan *parser.SyslogMessage, 10)
+	c, err := New(Config{
+		Protocol:       "tcp",
+		FramingMethod:  framing.Auto,
+		MaxMessageSize: 64,
+		Handler: func(msg *parser.SyslogMessage) error {
+			messages <- msg
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	long := "<13>Oct 11 22:14:15 host app: " + strings.Repeat("x", 100)
+	inputs := []string{
+		"31 <13>Oct 11 22:14:15 host app: a",                  // octet counting
+		"<13>Oct 11 22:14:15 host app: b\n",                   // LF
+		"<13>Oct 11 22:14:15 host app: c\x00" + long + "\x00", // NUL, then oversized
+	}
+	for _, input := range inputs {
+		client, server := net.Pipe()
+		go c.handleTCPConnection(server)
+		client.Write([]byte(input))
+		client.Close()
+	}
+
+	var got []*parser.SyslogMessage
+	for len(got) < 4 {
+		select {
+		case msg := <-messages:
+			got = append(got, msg)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d messages, want 4", len(got))
+		}
+	}
+
+	texts := make(map[string]bool)
+	for _, msg := range got {
+		texts[msg.Message[:1]] = true
+		if msg.Message[0] == 'x' {
+			if !strings.HasSuffix(msg.Message, framing.TruncatedMarker) {
+				t.Errorf("oversized message = %q, want the truncation marker", msg.Message)
+			}
+			if len(msg.ParseWarnings) != 1 || msg.ParseWarnings[0] != parser.WarningTruncated {
+				t.Errorf("ParseWarnings = %v, want [%s]", msg.ParseWarnings, parser.WarningTruncated)
+			}
+		}
+	}
+	for _, want := range []string{"a", "b", "c", "x"} {
+		if !texts[want] {
+			t.Errorf("message %q not received (got %v)", want, texts)
+		}
+	}
+	if stats := c.Stats(); stats.Truncated != 1 {
+		t.Errorf("Stats().Truncated = %d, want 1", stats.Truncated)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  RateLimit
+		sends  int
+		passed int
+		sample int
+	}{
+		{"burst then drop", RateLimit{Rate: 1, Burst: 3}, 10, 3, 0},
+		{"burst defaults to rate", RateLimit{Rate: 5}, 10, 5, 0},
+		{"sample one in four", RateLimit{Rate: 1, Burst: 2, Action: RateLimitSample, SampleRate: 4}, 10, 4, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newRateLimiter(tt.limit)
+			now := time.Date(2024, 10, 11, 22, 14, 15, 0, time.UTC)
+
+			passed, sampled := 0, 0
+			for i := 0; i < tt.sends; i++ {
+				ok, s := l.allow("192.0.2.10", now)
+				if ok {
+					passed++
+				}
+				if s {
+					sampled++
+				}
+			}
+			if passed != tt.passed || sampled != tt.sample {
+				t.Errorf("passed %d (%d sampled), want %d (%d sampled)", passed, sampled, tt.passed, tt.sample)
+			}
+			throttled := int64(tt.sends - tt.passed)
+			if got := l.total.Load(); got != throttled {
+				t.Errorf("total = %d, want %d", got, throttled)
+			}
+			if got := l.counts()["192.0.2.10"]; got != throttled {
+				t.Errorf("counts() = %d, want %d", got, throttled)
+			}
+
+			// Other sources have their own bucket, and tokens refill over time
+			if ok, _ := l.allow("192.0.2.11", now); !ok {
+				t.Errorf("allow() for another source = false, want true")
+			}
+			if ok, _ := l.allow("192.0.2.10", now.Add(time.Second)); !ok {
+				t.Errorf("allow() after refill = false, want true")
+			}
+		})
+	}
+}
+
+func TestRateLimitedListener(t *testing.T) {
+	var messages []*parser.SyslogMessage
+	c, err := New(Config{
+		Protocol:  "udp",
+		RateLimit: RateLimit{Rate: 1, Burst: 2},
+		Handler: func(msg *parser.SyslogMessage) error {
+			messages = append(messages, msg)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for i := 0; i < 5; i++ {
+		c.processMessage("<13>Oct 11 22:14:15 host app: noisy", source{addr: "192.0.2.10:51514"})
+	}
+	c.processMessage("<13>Oct 11 22:14:15 host app: quiet", source{addr: "192.0.2.20:51514"})
+
+	if len(messages) != 3 {
+		t.Errorf("handled %d messages, want 3", len(messages))
+	}
+	stats := c.Stats()
+	if stats.Received != 6 || stats.Throttled != 3 || stats.ThrottledSources["192.0.2.10"] != 3 {
+		t.Errorf("Stats() = %+v, want 6 received, 3 throttled from 192.0.2.10", stats)
+	}
+
+	if _, err := New(Config{RateLimit: RateLimit{Rate: 1, Action: "block"}}); err == nil {
+		t.Errorf("New() with an unknown rate limit action should fail")
+	}
+}
+
+func TestConnectionLimits(t *testing.T) {
+	l := connLimiter{max: 3, perSource: 2}
+
+	if !l.acquire("a") || !l.acquire("a") {
+		t.Fatalf("acquire() within the limits = false")
+	}
+	if l.acquire("a") {
+		t.Errorf("acquire() over the per-source limit = true")
+	}
+	if !l.acquire("b") {
+		t.Errorf("acquire() for another source = false")
+	}
+	if l.acquire("c") {
+		t.Errorf("acquire() over the listener limit = true")
+	}
+	if got := l.connections(); got != 3 {
+		t.Errorf("connections() = %d, want 3", got)
+	}
+
+	l.release("a")
+	if !l.acquire("c") {
+		t.Errorf("acquire() after release = false")
+	}
+}
+
+func TestIdleTimeout(t *testing.T) {
+	messages := make(chan *parser.SyslogMessage, 10)
+	c, err := New(Config{
+		Protocol:       "tcp",
+		FramingMethod:  framing.NonTransparent,
+		MaxConnections: 1,
+		IdleTimeout:    50 * time.Millisecond,
+		Handler: func(msg *parser.SyslogMessage) error {
+			messages <- msg
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	c.accept(server, c.handleTCPConnection)
+
+	// The only slot is taken, so a second connection is refused and closed
+	other, otherServer := net.Pipe()
+	c.accept(otherServer, c.handleTCPConnection)
+	if _, err := other.Write([]byte("x")); err == nil {
+		t.Errorf("write to a connection over the limit succeeded")
+	}
+
+	go client.Write([]byte("<13>Oct 11 22:14:15 host app: before\n"))
+	select {
+	case msg := <-messages:
+		if msg.Message != "before" {
+			t.Errorf("Message = %q, want %q", msg.Message, "before")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("message not received")
+	}
+
+	// The server closes the idle connection
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); err == nil {
+		t.Errorf("Read() = nil error, want the connection closed")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Connections != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := c.Stats()
+	if stats.TimedOut != 1 || stats.ConnectionsRejected != 1 || stats.Connections != 0 {
+		t.Errorf("Stats() = %+v, want 1 timed out, 1 rejected, 0 open", stats)
+	}
+}
+
+func TestReadTimeoutSlowWriter(t *testing.T) {
+	c, err := New(Config{
+		Protocol:      "tcp",
+		FramingMethod: framing.NonTransparent,
+		ReadTimeout:   100 * time.Millisecond,
+		Handler:       func(msg *parser.SyslogMessage) error { return nil },
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	c.accept(server, c.handleTCPConnection)
+
+	// A byte every 20ms never lets the connection go quiet for the read timeout, but the
+	// message is not finished within it either
+	start := time.Now()
+	var closedAfter time.Duration
+	for time.Since(start) < time.Second {
+		client.SetWriteDeadline(time.Now().Add(time.Second))
+		if _, err := client.Write([]byte("x")); err != nil {
+			closedAfter = time.Since(start)
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if closedAfter == 0 {
+		t.Fatalf("connection still open after 1s of trickling bytes, want it closed after the read timeout")
+	}
+	if closedAfter > 500*time.Millisecond {
+		t.Errorf("connection closed after %v, want about the read timeout", closedAfter)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for c.Stats().Connections != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := c.Stats(); stats.TimedOut != 1 {
+		t.Errorf("Stats().TimedOut = %d, want 1", stats.TimedOut)
+	}
+}
+
+func TestSourceLists(t *testing.T) {
+	var messages []*parser.SyslogMessage
+	var rejects []*Reject
+	c, err := New(Config{
+		Name:         "edge",
+		Protocol:     "udp",
+		Allow:        []string{"192.0.2.0/24", "2001:db8::1"},
+		Deny:         []string{"192.0.2.66"},
+		RecordDenied: true,
+		Hosts: []ExpectedHost{
+			{Hostname: "fw1.example.com", Addresses: []string{"192.0.2.1"}},
+		},
+		Handler: func(msg *parser.SyslogMessage) error {
+			messages = append(messages, msg)
+			return nil
+		},
+		RejectHandler: func(r *Reject) { rejects = append(rejects, r) },
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		addr     string
+		hostname string
+		allowed  bool
+		mismatch bool
+	}{
+		{"192.0.2.1:514", "fw1", true, false},
+		{"192.0.2.1:514", "FW1.example.com", true, false},
+		{"192.0.2.1:514", "fw2", true, true},
+		{"192.0.2.1:514", "fw1.example.org", true, true},
+		{"192.0.2.10:514", "anything", true, false},
+		{"[2001:db8::1]:514", "v6host", true, false},
+		{"192.0.2.66:514", "denied", false, false},
+		{"198.51.100.1:514", "outside", false, false},
+	}
+
+	for _, tt := range tests {
+		messages, rejects = nil, nil
+		c.processMessage("<13>Oct 11 22:14:15 "+tt.hostname+" app: hello", source{addr: tt.addr})
+
+		if got := len(messages) == 1; got != tt.allowed {
+			t.Errorf("%s (%s): handled = %v, want %v", tt.addr, tt.hostname, got, tt.allowed)
+			continue
+		}
+		if !tt.allowed {
+			if len(rejects) != 1 || rejects[0].Error != errSourceDenied.Error() || rejects[0].Listener != "edge" {
+				t.Errorf("%s: rejects = %+v, want the denied message recorded", tt.addr, rejects)
+			}
+			continue
+		}
+		msg := messages[0]
+		mismatch := len(msg.ParseWarnings) == 1 && msg.ParseWarnings[0] == parser.WarningHostnameMismatch
+		if mismatch != tt.mismatch {
+			t.Errorf("%s (%s): ParseWarnings = %v, want mismatch %v", tt.addr, tt.hostname, msg.ParseWarnings, tt.mismatch)
+		}
+		if tt.mismatch && msg.Fields["expected_hostname"] != "fw1.example.com" {
+			t.Errorf("expected_hostname = %v, want fw1.example.com", msg.Fields["expected_hostname"])
+		}
+	}
+
+	if stats := c.Stats(); stats.Denied != 2 || stats.HostnameMismatches != 2 || stats.Rejected != 0 {
+		t.Errorf("Stats() = %+v, want 2 denied, 2 hostname mismatches, 0 rejected", stats)
+	}
+
+	if _, err := New(Config{Allow: []string{"not-an-ip"}}); err == nil {
+		t.Errorf("New() with an invalid allow entry should fail")
+	}
+}
+
+func TestProxyProtocol(t *testing.T) {
+	rejects := make(chan *Reject, 10)
+	c, err := New(Config{
+		Name:           "proxied",
+		Protocol:       "tcp",
+		FramingMethod:  framing.NonTransparent,
+		Deny:           []string{"198.51.100.7"},
+		ProxyProtocol:  true,
+		TrustedProxies: []string{"127.0.0.0/8"},
+		RejectHandler:  func(r *Reject) { rejects <- r },
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer listener.Close()
+
+	send := func(data string) {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Fatalf("Accept() error = %v", err)
+		}
+		c.accept(conn, c.handleTCPConnection)
+		client.Write([]byte(data))
+		client.Close()
+	}
+
+	// Unparseable messages are rejected with their source, which is the proxied client
+	send("PROXY TCP4 192.0.2.10 127.0.0.1 51514 514\r\nnot syslog\n")
+	select {
+	case r := <-rejects:
+		if r.Source != "192.0.2.10" {
+			t.Errorf("reject Source = %q, want the proxied client 192.0.2.10", r.Source)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("message not received")
+	}
+
+	send("PROXY TCP4 198.51.100.7 127.0.0.1 51514 514\r\nnot syslog\n")
+	send("not syslog\n")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if stats := c.Stats(); stats.Denied == 1 && stats.ProxyErrors == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := c.Stats(); stats.Denied != 1 || stats.ProxyErrors != 1 || stats.Received != 1 {
+		t.Errorf("Stats() = %+v, want 1 received, 1 denied, 1 proxy error", stats)
+	}
+
+	if _, err := New(Config{Protocol: "tcp", ProxyProtocol: true}); err == nil {
+		t.Errorf("New() with PROXY protocol and no trusted proxies should fail")
+	}
+}
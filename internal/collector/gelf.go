@@ -0,0 +1,287 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"syslog-visualizer/internal/parser"
+)
+
+const (
+	gelfMaxDatagram   = 65536   // Largest UDP datagram read
+	gelfMaxMessage    = 1 << 20 // Largest reassembled or decompressed message
+	gelfMaxChunks     = 128     // Maximum chunks per message (GELF spec)
+	gelfChunkTimeout  = 5 * time.Second
+	gelfChunkHeader   = 12 // Magic (2) + message ID (8) + sequence number (1) + count (1)
+	gelfMaxChunkSets  = 1024
+	gelfChunkMagicOne = 0x1e
+	gelfChunkMagicTwo = 0x0f
+)
+
+// gelfChunkSet collects the chunks of one message
+type gelfChunkSet struct {
+	chunks   [][]byte
+	received int
+	size     int
+	started  time.Time
+}
+
+// gelfAssembler reassembles chunked GELF UDP messages
+type gelfAssembler struct {
+	mu   sync.Mutex
+	sets map[string]*gelfChunkSet
+}
+
+func newGELFAssembler() *gelfAssembler {
+	return &gelfAssembler{sets: make(map[string]*gelfChunkSet)}
+}
+
+// add stores a chunk and returns the complete payload once every chunk has arrived
+func (a *gelfAssembler) add(datagram []byte) ([]byte, error) {
+	if len(datagram) <= gelfChunkHeader {
+		return nil, fmt.Errorf("GELF chunk too short")
+	}
+
+	id := string(datagram[2:10])
+	seq := int(datagram[10])
+	count := int(datagram[11])
+	if count == 0 || count > gelfMaxChunks || seq >= count {
+		return nil, fmt.Errorf("invalid GELF chunk %d/%d", seq, count)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := time.Now()
+	a.expire(now)
+
+	set, exists := a.sets[id]
+	if !exists {
+		if len(a.sets) >= gelfMaxChunkSets {
+			return nil, fmt.Errorf("too many incomplete GELF messages")
+		}
+		set = &gelfChunkSet{chunks: make([][]byte, count), started: now}
+		a.sets[id] = set
+	}
+	if len(set.chunks) != count {
+		delete(a.sets, id)
+		return nil, fmt.Errorf("GELF chunk count mismatch")
+	}
+
+	if set.chunks[seq] == nil {
+		set.chunks[seq] = append([]byte(nil), datagram[gelfChunkHeader:]...)
+		set.received++
+		set.size += len(set.chunks[seq])
+	}
+	if set.size > gelfMaxMessage {
+		delete(a.sets, id)
+		return nil, fmt.Errorf("GELF message exceeds %d bytes", gelfMaxMessage)
+	}
+
+	if set.received < count {
+		return nil, nil
+	}
+
+	delete(a.sets, id)
+	return bytes.Join(set.chunks, nil), nil
+}
+
+// expire drops incomplete messages older than the chunk timeout (caller must hold the lock)
+func (a *gelfAssembler) expire(now time.Time) {
+	for id, set := range a.sets {
+		if now.Sub(set.started) > gelfChunkTimeout {
+			delete(a.sets, id)
+		}
+	}
+}
+
+// startGELFUDP starts a GELF UDP listener (chunked, zlib/gzip compressed or plain)
+func (c *Collector) startGELFUDP() error {
+	// Chunks of a message must reach the same assembler, so GELF has a single reader
+	conns, err := c.listenUDP(1)
+	if err == errStopped {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start GELF UDP listener: %w", err)
+	}
+	conn := conns[0]
+
+	log.Printf("GELF UDP collector listening on %s", c.address)
+
+	assembler := newGELFAssembler()
+	buffer := make([]byte, gelfMaxDatagram)
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		default:
+			n, remoteAddr, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				if c.ctx.Err() != nil {
+					// Collector is stopping
+					return nil
+				}
+				log.Printf("GELF UDP read error: %v", err)
+				continue
+			}
+
+			payload := buffer[:n]
+			if n >= 2 && payload[0] == gelfChunkMagicOne && payload[1] == gelfChunkMagicTwo {
+				payload, err = assembler.add(payload)
+				if err != nil {
+					log.Printf("GELF chunk from %s dropped: %v", remoteAddr, err)
+					continue
+				}
+				if payload == nil {
+					// Waiting for more chunks
+					continue
+				}
+			}
+
+			c.processGELF(payload, source{addr: remoteAddr.String()})
+		}
+	}
+}
+
+// startGELFTCP starts a GELF TCP listener (NUL-delimited, uncompressed)
+func (c *Collector) startGELFTCP() error {
+	listener, err := net.Listen("tcp", c.address)
+	if err != nil {
+		return fmt.Errorf("failed to start GELF TCP listener: %w", err)
+	}
+	if !c.register(func() { c.tcpListener = listener }) {
+		// Stopped while starting
+		listener.Close()
+		return nil
+	}
+
+	log.Printf("GELF TCP collector listening on %s", c.address)
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		default:
+			conn, err := listener.Accept()
+			if err != nil {
+				if c.ctx.Err() != nil {
+					// Collector is stopping
+					return nil
+				}
+				log.Printf("GELF TCP accept error: %v", err)
+				continue
+			}
+
+			c.accept(conn, c.handleGELFConnection)
+		}
+	}
+}
+
+// handleGELFConnection reads NUL-delimited GELF messages from a TCP connection
+func (c *Collector) handleGELFConnection(conn net.Conn) {
+	defer conn.Close()
+
+	remoteAddr := conn.RemoteAddr().String()
+	log.Printf("New GELF TCP connection from %s", remoteAddr)
+
+	deadlines := c.newDeadlineReader(conn)
+	scanner := bufio.NewScanner(deadlines)
+	scanner.Buffer(make([]byte, 0, 4096), gelfMaxMessage)
+	scanner.Split(scanNULRecords)
+
+	for scanner.Scan() {
+		deadlines.next()
+		if payload := bytes.TrimSpace(scanner.Bytes()); len(payload) > 0 {
+			c.processGELF(payload, source{addr: remoteAddr})
+		}
+	}
+
+	if err := scanner.Err(); err != nil && c.ctx.Err() == nil {
+		log.Printf("GELF TCP read error from %s: %v", remoteAddr, err)
+	}
+}
+
+// processGELF decompresses and decodes a GELF payload and hands it to the handler
+func (c *Collector) processGELF(payload []byte, src source) {
+	c.received.Add(1)
+	c.pending.Add(1)
+	defer c.pending.Add(-1)
+
+	if !c.allowed(src) {
+		c.deny(payload, src)
+		return
+	}
+	admitted, sampled := c.admit(src)
+	if !admitted {
+		return
+	}
+
+	decompressed, err := decompressGELF(payload)
+	if err != nil {
+		c.reject(payload, src, fmt.Errorf("failed to decompress GELF message: %w", err))
+		return
+	}
+
+	msg, err := parser.ParseGELF(decompressed)
+	if err != nil {
+		c.reject(decompressed, src, err)
+		return
+	}
+	if sampled {
+		msg.ParseWarnings = append(msg.ParseWarnings, parser.WarningSampled)
+	}
+	c.checkHostname(msg, src)
+
+	c.dispatch(msg, src)
+}
+
+// decompressGELF detects zlib or gzip compression from the magic bytes
+func decompressGELF(payload []byte) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+
+	switch {
+	case len(payload) >= 2 && payload[0] == 0x1f && payload[1] == 0x8b:
+		reader, err = gzip.NewReader(bytes.NewReader(payload))
+	case len(payload) >= 2 && payload[0] == 0x78 && (uint16(payload[0])<<8|uint16(payload[1]))%31 == 0:
+		reader, err = zlib.NewReader(bytes.NewReader(payload))
+	default:
+		return payload, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, gelfMaxMessage+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > gelfMaxMessage {
+		return nil, fmt.Errorf("decompressed message exceeds %d bytes", gelfMaxMessage)
+	}
+	return data, nil
+}
+
+// scanNULRecords is a bufio.SplitFunc splitting on NUL only
+func scanNULRecords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"bytes"
+	"compress/zlib"
+	"testing"
+
+	"syslog-visualizer/internal/parser"
+)
+
+func TestGELFChunkedCompressed(t *testing.T) {
+	payload := `{"version":"1.1","host":"docker-01","short_message":"container started","level":3,` +
+		`"timestamp":1728684855.25,"_container_name":"web","_request_ms":42,"_id":"ignored"}`
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write([]byte(payload))
+	zw.Close()
+
+	// Split into three chunks, delivered out of order
+	data := compressed.Bytes()
+	third := len(data)/3 + 1
+	id := []byte("abcdefgh")
+	chunk := func(seq int) []byte {
+		end := (seq + 1) * third
+		if end > len(data) {
+			end = len(data)
+		}
+		header := append([]byte{gelfChunkMagicOne, gelfChunkMagicTwo}, id...)
+		header = append(header, byte(seq), 3)
+		return append(header, data[seq*third:end]...)
+	}
+
+	assembler := newGELFAssembler()
+	for _, seq := range []int{2, 0} {
+		got, err := assembler.add(chunk(seq))
+		if err != nil || got != nil {
+			t.Fatalf("add(chunk %d) = %v, %v; want incomplete", seq, got, err)
+		}
+	}
+	assembled, err := assembler.add(chunk(1))
+	if err != nil || assembled == nil {
+		t.Fatalf("add(last chunk) = %v, %v; want complete payload", assembled, err)
+	}
+
+	decompressed, err := decompressGELF(assembled)
+	if err != nil {
+		t.Fatalf("decompressGELF() error = %v", err)
+	}
+
+	msg, err := parser.ParseGELF(decompressed)
+	if err != nil {
+		t.Fatalf("ParseGELF() error = %v", err)
+	}
+
+	if msg.Hostname != "docker-01" || msg.Message != "container started" || msg.Severity != 3 {
+		t.Errorf("ParseGELF() = %+v", msg)
+	}
+	if msg.Tag != "web" {
+		t.Errorf("Tag = %q, want %q", msg.Tag, "web")
+	}
+	if msg.Timestamp.Unix() != 1728684855 || msg.Timestamp.Nanosecond() != 250000000 {
+		t.Errorf("Timestamp = %v", msg.Timestamp)
+	}
+	if msg.Fields["request_ms"] != int64(42) {
+		t.Errorf("Fields[request_ms] = %#v, want int64(42)", msg.Fields["request_ms"])
+	}
+	if _, exists := msg.Fields["id"]; exists {
+		t.Errorf("reserved _id field should be ignored")
+	}
+}
+
+func TestParseGELFRequiredFields(t *testing.T) {
+	for _, payload := range []string{
+		`{"host":"a"}`,
+		`{"short_message":"x"}`,
+		`not json`,
+	} {
+		if _, err := parser.ParseGELF([]byte(payload)); err == nil {
+			t.Errorf("ParseGELF(%s) expected error", payload)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Rate limit actions
+const (
+	RateLimitDrop   = "drop"   // Drop messages over the limit
+	RateLimitSample = "sample" // Keep one in SampleRate messages over the limit
+)
+
+// maxThrottledSources bounds the per-source throttle counters; further sources are
+// only counted in the total
+const maxThrottledSources = 1000
+
+// bucketSweepInterval is how often buckets that have refilled are forgotten
+const bucketSweepInterval = time.Minute
+
+// RateLimit configures token-bucket rate limiting per source address
+type RateLimit struct {
+	Rate       float64 `yaml:"rate"`        // Messages per second per source (0 disables)
+	Burst      int     `yaml:"burst"`       // Messages a source may send at once (default: Rate, at least 1)
+	Action     string  `yaml:"action"`      // "drop" (default) or "sample"
+	SampleRate int     `yaml:"sample_rate"` // With "sample", keep one in this many messages over the limit (default 10)
+}
+
+// Validate checks the action and values
+func (r RateLimit) Validate() error {
+	switch r.Action {
+	case "", RateLimitDrop, RateLimitSample:
+	default:
+		return fmt.Errorf("unsupported rate limit action: %s (use 'drop' or 'sample')", r.Action)
+	}
+	if r.Rate < 0 || r.Burst < 0 || r.SampleRate < 0 {
+		return fmt.Errorf("rate limit values must not be negative")
+	}
+	return nil
+}
+
+// tokenBucket holds the tokens of one source
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+	over    int64 // Messages over the limit, for sampling
+}
+
+// rateLimiter applies a token bucket per source and counts what it throttles
+type rateLimiter struct {
+	rate       float64
+	burst      float64
+	sampleRate int64 // 0 drops every message over the limit
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	throttled map[string]int64
+	total     atomic.Int64
+	swept     time.Time
+}
+
+// newRateLimiter returns nil when the rate limit is disabled
+func newRateLimiter(cfg RateLimit) *rateLimiter {
+	if cfg.Rate <= 0 {
+		return nil
+	}
+	burst := float64(cfg.Burst)
+	if burst == 0 {
+		burst = cfg.Rate
+	}
+	if burst < 1 {
+		burst = 1
+	}
+
+	l := &rateLimiter{
+		rate:      cfg.Rate,
+		burst:     burst,
+		buckets:   make(map[string]*tokenBucket),
+		throttled: make(map[string]int64),
+	}
+	if cfg.Action == RateLimitSample {
+		l.sampleRate = int64(cfg.SampleRate)
+		if l.sampleRate == 0 {
+			l.sampleRate = 10
+		}
+	}
+	return l
+}
+
+// allow takes a token from the source's bucket
+// Messages over the limit are throttled unless sampling keeps them, in which case
+// sampled is set
+func (l *rateLimiter) allow(host string, now time.Time) (ok, sampled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	b, exists := l.buckets[host]
+	if !exists {
+		b = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[host] = b
+	} else if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * l.rate
+		if b.tokens > l.burst {
+			b.tokens = l.burst
+		}
+		b.updated = now
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, false
+	}
+
+	b.over++
+	if l.sampleRate > 0 && b.over%l.sampleRate == 1%l.sampleRate {
+		return true, true
+	}
+
+	l.total.Add(1)
+	if _, counted := l.throttled[host]; counted || len(l.throttled) < maxThrottledSources {
+		l.throttled[host]++
+	}
+	return false, false
+}
+
+// sweep forgets buckets that are full again, which behave like new ones
+// (caller must hold the lock)
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < bucketSweepInterval {
+		return
+	}
+	l.swept = now
+
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for host, b := range l.buckets {
+		if now.Sub(b.updated) > refill {
+			delete(l.buckets, host)
+		}
+	}
+}
+
+// counts returns the number of throttled messages per source
+func (l *rateLimiter) counts() map[string]int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	counts := make(map[string]int64, len(l.throttled))
+	for host, n := range l.throttled {
+		counts[host] = n
+	}
+	return counts
+}
+
+// connLimiter bounds the concurrent connections of a listener, in total and per source
+type connLimiter struct {
+	max       int // 0 for no limit
+	perSource int // 0 for no limit
+
+	mu       sync.Mutex
+	open     int
+	bySource map[string]int
+}
+
+// acquire reserves a connection slot for the source
+func (l *connLimiter) acquire(host string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.max > 0 && l.open >= l.max {
+		return false
+	}
+	if l.perSource > 0 && l.bySource[host] >= l.perSource {
+		return false
+	}
+	if l.bySource == nil {
+		l.bySource = make(map[string]int)
+	}
+	l.open++
+	l.bySource[host]++
+	return true
+}
+
+// release frees a slot reserved by acquire
+func (l *connLimiter) release(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.open--
+	if l.bySource[host]--; l.bySource[host] <= 0 {
+		delete(l.bySource, host)
+	}
+}
+
+// connections returns the number of open connections
+func (l *connLimiter) connections() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.open
+}
+
+// Timeout errors returned by deadlineReader
+var (
+	errIdleTimeout = errors.New("idle timeout")
+	errReadTimeout = errors.New("read timeout")
+)
+
+// deadlineReader reads from a connection with the idle timeout while waiting for a
+// message and the read timeout once part of one has arrived
+// The idle deadline is renewed on every read, while the read deadline is set once, when
+// a message starts, so that trickling bytes cannot keep a connection open
+// next must be called after each message
+// Once the collector stops, reads end with io.EOF
+type deadlineReader struct {
+	ctx      context.Context
+	conn     net.Conn
+	idle     time.Duration
+	read     time.Duration
+	started  bool
+	deadline bool // A deadline is set on the connection
+	timedOut *atomic.Int64
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, io.EOF
+	}
+
+	timeoutErr := errReadTimeout
+	if !r.started {
+		timeoutErr = errIdleTimeout
+		r.setDeadline(r.idle)
+	}
+	if r.ctx.Err() != nil {
+		// Stop may have set its deadline before ours replaced it
+		return 0, io.EOF
+	}
+
+	n, err := r.conn.Read(p)
+	if n > 0 && !r.started {
+		// The message has until the read timeout to arrive in full
+		r.started = true
+		r.setDeadline(r.read)
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		if r.ctx.Err() != nil {
+			// Interrupted by Stop
+			return n, io.EOF
+		}
+		r.timedOut.Add(1)
+		return n, timeoutErr
+	}
+	return n, err
+}
+
+// setDeadline sets the read deadline of the connection, or clears it for a zero timeout
+func (r *deadlineReader) setDeadline(timeout time.Duration) {
+	if timeout > 0 {
+		r.conn.SetReadDeadline(time.Now().Add(timeout))
+		r.deadline = true
+	} else if r.deadline {
+		r.conn.SetReadDeadline(time.Time{})
+		r.deadline = false
+	}
+}
+
+// next marks the end of a message, so the idle timeout applies again
+func (r *deadlineReader) next() {
+	r.started = false
+}
@@ -0,0 +1,227 @@
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// RELP (Reliable Event Logging Protocol) as spoken by rsyslog's omrelp
+// Frame format: TXNR SP COMMAND SP DATALEN [SP DATA] LF
+const (
+	relpVersion      = "0"
+	relpSoftware     = "syslog-visualizer"
+	relpMaxTxnr      = 999999999
+	relpMaxCommand   = 32
+	relpMaxDataLenSz = 9
+)
+
+// relpFrame is a single RELP frame
+type relpFrame struct {
+	txnr    int
+	command string
+	data    []byte
+}
+
+// startRELP starts the RELP listener
+func (c *Collector) startRELP() error {
+	listener, err := net.Listen("tcp", c.address)
+	if err != nil {
+		return fmt.Errorf("failed to start RELP listener: %w", err)
+	}
+	if !c.register(func() { c.tcpListener = listener }) {
+		// Stopped while starting
+		listener.Close()
+		return nil
+	}
+
+	log.Printf("RELP syslog collector listening on %s", c.address)
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		default:
+			conn, err := listener.Accept()
+			if err != nil {
+				if c.ctx.Err() != nil {
+					// Collector is stopping
+					return nil
+				}
+				log.Printf("RELP accept error: %v", err)
+				continue
+			}
+
+			c.accept(conn, c.handleRELPConnection)
+		}
+	}
+}
+
+// handleRELPConnection runs a RELP session
+// A syslog frame is only acknowledged once the handler has stored the message,
+// so the sender keeps and retransmits anything that was not committed
+func (c *Collector) handleRELPConnection(conn net.Conn) {
+	defer conn.Close()
+
+	remoteAddr := conn.RemoteAddr().String()
+	log.Printf("New RELP connection from %s", remoteAddr)
+
+	deadlines := c.newDeadlineReader(conn)
+	reader := bufio.NewReader(deadlines)
+	writer := bufio.NewWriter(conn)
+	opened := false
+
+	for {
+		frame, err := readRELPFrame(reader, c.maxMessageSize)
+		if err != nil {
+			if err != io.EOF && c.ctx.Err() == nil {
+				log.Printf("RELP read error from %s: %v", remoteAddr, err)
+			}
+			return
+		}
+		deadlines.next()
+
+		switch frame.command {
+		case "open":
+			opened = true
+			offers := fmt.Sprintf("200 OK\nrelp_version=%s\nrelp_software=%s\ncommands=syslog", relpVersion, relpSoftware)
+			err = writeRELPResponse(writer, frame.txnr, offers)
+
+		case "syslog":
+			if !opened {
+				err = writeRELPResponse(writer, frame.txnr, "500 session not opened")
+				break
+			}
+			raw := strings.TrimRight(string(frame.data), "\n\x00")
+			if handlerErr := c.processMessage(raw, source{addr: remoteAddr, ack: true}); handlerErr != nil {
+				// Not committed: negative ack so the sender retries
+				err = writeRELPResponse(writer, frame.txnr, "500 "+handlerErr.Error())
+			} else {
+				err = writeRELPResponse(writer, frame.txnr, "200 OK")
+			}
+
+		case "close":
+			writeRELPResponse(writer, frame.txnr, "")
+			writeRELPFrame(writer, 0, "serverclose", "")
+			return
+
+		default:
+			err = writeRELPResponse(writer, frame.txnr, "500 unsupported command "+frame.command)
+		}
+
+		if err != nil {
+			log.Printf("RELP write error to %s: %v", remoteAddr, err)
+			return
+		}
+	}
+}
+
+// readRELPFrame reads and validates one RELP frame
+func readRELPFrame(r *bufio.Reader, maxSize int) (*relpFrame, error) {
+	txnrStr, err := readRELPToken(r, relpMaxDataLenSz)
+	if err != nil {
+		return nil, err
+	}
+	txnr, err := strconv.Atoi(txnrStr)
+	if err != nil || txnr < 0 || txnr > relpMaxTxnr {
+		return nil, fmt.Errorf("invalid transaction number %q", txnrStr)
+	}
+
+	command, err := readRELPToken(r, relpMaxCommand)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read command: %w", err)
+	}
+
+	// DATALEN is followed by SP and DATA, or directly by the LF trailer when zero
+	var lenBuf []byte
+	var delim byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read data length: %w", err)
+		}
+		if b == ' ' || b == '\n' {
+			delim = b
+			break
+		}
+		if b < '0' || b > '9' || len(lenBuf) >= relpMaxDataLenSz {
+			return nil, fmt.Errorf("invalid data length")
+		}
+		lenBuf = append(lenBuf, b)
+	}
+	dataLen, err := strconv.Atoi(string(lenBuf))
+	if err != nil {
+		return nil, fmt.Errorf("invalid data length %q", lenBuf)
+	}
+	if dataLen > maxSize {
+		return nil, fmt.Errorf("frame data length %d exceeds maximum %d", dataLen, maxSize)
+	}
+
+	frame := &relpFrame{txnr: txnr, command: command}
+	if delim == '\n' {
+		if dataLen != 0 {
+			return nil, fmt.Errorf("missing data for length %d", dataLen)
+		}
+		return frame, nil
+	}
+
+	frame.data = make([]byte, dataLen)
+	if _, err := io.ReadFull(r, frame.data); err != nil {
+		return nil, fmt.Errorf("failed to read frame data: %w", err)
+	}
+
+	trailer, err := r.ReadByte()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trailer: %w", err)
+	}
+	if trailer != '\n' {
+		return nil, fmt.Errorf("invalid frame trailer %q", trailer)
+	}
+
+	return frame, nil
+}
+
+// readRELPToken reads a header token terminated by SP
+func readRELPToken(r *bufio.Reader, maxLen int) (string, error) {
+	var token []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && len(token) > 0 {
+				return "", io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+		if b == ' ' {
+			break
+		}
+		// Tolerate stray LF between frames
+		if b == '\n' && len(token) == 0 {
+			continue
+		}
+		if len(token) >= maxLen {
+			return "", fmt.Errorf("header token too long")
+		}
+		token = append(token, b)
+	}
+	return string(token), nil
+}
+
+// writeRELPResponse sends a "rsp" frame for the given transaction
+func writeRELPResponse(w *bufio.Writer, txnr int, data string) error {
+	return writeRELPFrame(w, txnr, "rsp", data)
+}
+
+// writeRELPFrame writes and flushes a RELP frame
+func writeRELPFrame(w *bufio.Writer, txnr int, command, data string) error {
+	if data == "" {
+		fmt.Fprintf(w, "%d %s 0\n", txnr, command)
+	} else {
+		fmt.Fprintf(w, "%d %s %d %s\n", txnr, command, len(data), data)
+	}
+	return w.Flush()
+}
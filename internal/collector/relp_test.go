@@ -0,0 +1,200 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/diskqueue"
+	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/internal/storage"
+)
+
+func TestReadRELPFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		txnr    int
+		command string
+		data    string
+		wantErr bool
+	}{
+		{
+			name:    "Open with offers",
+			input:   "1 open 18 relp_version=0\ncmd\n",
+			txnr:    1,
+			command: "open",
+			data:    "relp_version=0\ncmd",
+		},
+		{
+			name:    "Zero length frame",
+			input:   "3 close 0\n",
+			txnr:    3,
+			command: "close",
+		},
+		{
+			name:    "Missing trailer",
+			input:   "2 syslog 5 helloX",
+			wantErr: true,
+		},
+		{
+			name:    "Invalid transaction number",
+			input:   "x syslog 5 hello\n",
+			wantErr: true,
+		},
+		{
+			name:    "Data exceeds maximum",
+			input:   "2 syslog 99999 hello\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := readRELPFrame(bufio.NewReader(strings.NewReader(tt.input)), 1024)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readRELPFrame() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if frame.txnr != tt.txnr || frame.command != tt.command || string(frame.data) != tt.data {
+				t.Errorf("readRELPFrame() = %d %q %q, want %d %q %q",
+					frame.txnr, frame.command, frame.data, tt.txnr, tt.command, tt.data)
+			}
+		})
+	}
+}
+
+func TestRELPSession(t *testing.T) {
+	stored := 0
+	failNext := true
+	c, err := New(Config{
+		Protocol: "relp",
+		Handler: func(msg *parser.SyslogMessage) error {
+			// Fail the first delivery to check the negative acknowledgement
+			if failNext {
+				failNext = false
+				return fmt.Errorf("database is locked")
+			}
+			stored++
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	client, server := net.Pipe()
+	go c.handleRELPConnection(server)
+	defer client.Close()
+
+	reader := bufio.NewReader(client)
+	send := func(txnr int, command, data string) *relpFrame {
+		t.Helper()
+		if data == "" {
+			fmt.Fprintf(client, "%d %s 0\n", txnr, command)
+		} else {
+			fmt.Fprintf(client, "%d %s %d %s\n", txnr, command, len(data), data)
+		}
+		frame, err := readRELPFrame(reader, 1024)
+		if err != nil {
+			t.Fatalf("failed to read response to %s: %v", command, err)
+		}
+		if frame.txnr != txnr || frame.command != "rsp" {
+			t.Fatalf("response = %d %s, want %d rsp", frame.txnr, frame.command, txnr)
+		}
+		return frame
+	}
+
+	msg := "<34>Oct 11 22:14:15 mymachine su: 'su root' failed"
+
+	if rsp := send(1, "open", "relp_version=0\ncommands=syslog"); !strings.HasPrefix(string(rsp.data), "200 OK") {
+		t.Errorf("open response = %q", rsp.data)
+	}
+	if rsp := send(2, "syslog", msg); !strings.HasPrefix(string(rsp.data), "500") {
+		t.Errorf("failed delivery response = %q, want 500", rsp.data)
+	}
+	if rsp := send(3, "syslog", msg); string(rsp.data) != "200 OK" {
+		t.Errorf("syslog response = %q, want 200 OK", rsp.data)
+	}
+	send(4, "close", "")
+
+	if stored != 1 {
+		t.Errorf("stored = %d, want 1", stored)
+	}
+}
+
+// downStorage is a storage whose every Store fails
+type downStorage struct {
+	*storage.MemoryStorage
+}
+
+func (downStorage) Store(msg *parser.SyslogMessage) error {
+	return errors.New("database is locked")
+}
+
+func TestRELPStorageFailure(t *testing.T) {
+	// The database is down and the spool cannot take the message either
+	spool, err := storage.NewSpoolStorage(downStorage{storage.NewMemoryStorage()}, diskqueue.Options{Dir: t.TempDir(), MaxSize: 1})
+	if err != nil {
+		t.Fatalf("NewSpoolStorage() error = %v", err)
+	}
+	defer spool.Close()
+
+	acknowledged := make(chan bool, 1)
+	c, err := New(Config{
+		Protocol: "relp",
+		Address:  "127.0.0.1:0",
+		Handler: func(msg *parser.SyslogMessage) error {
+			acknowledged <- msg.Acknowledged
+			return spool.Store(msg)
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	go c.Start()
+	defer c.Stop(context.Background())
+
+	var addr string
+	deadline := time.Now().Add(2 * time.Second)
+	for addr == "" && time.Now().Before(deadline) {
+		c.stopMu.Lock()
+		if c.tcpListener != nil {
+			addr = c.tcpListener.Addr().String()
+		}
+		c.stopMu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	if addr == "" {
+		t.Fatalf("RELP listener did not start")
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	msg := "<34>Oct 11 22:14:15 mymachine su: 'su root' failed"
+	fmt.Fprintf(conn, "1 open 0\n2 syslog %d %s\n", len(msg), msg)
+	for _, want := range []string{"200 OK", "500 "} {
+		frame, err := readRELPFrame(reader, 1024)
+		if err != nil {
+			t.Fatalf("failed to read response: %v", err)
+		}
+		if !strings.HasPrefix(string(frame.data), want) {
+			t.Errorf("response %d = %q, want %q", frame.txnr, frame.data, want)
+		}
+	}
+	if !<-acknowledged {
+		t.Errorf("Acknowledged = false for a RELP message")
+	}
+}
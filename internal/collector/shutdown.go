@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"time"
+)
+
+// Stop stops accepting messages, lets those already received be parsed and handled,
+// and waits for them until ctx is done
+// It returns the number of messages abandoned when ctx ends first
+func (c *Collector) Stop(ctx context.Context) (int64, error) {
+	log.Println("Stopping syslog collector...")
+
+	c.stopMu.Lock()
+	c.stopping = true
+	c.stopMu.Unlock()
+
+	// Cancel context to stop all goroutines, then stop accepting
+	c.cancel()
+	closeErr := c.closeListeners()
+
+	// Wake up connections blocked in a read; they finish the messages they have
+	c.forEachConn(func(conn net.Conn) {
+		conn.SetReadDeadline(time.Now())
+	})
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("Syslog collector stopped")
+		return 0, closeErr
+	case <-ctx.Done():
+	}
+
+	c.haltOnce.Do(func() { close(c.halt) })
+	abandoned := c.pending.Load()
+	c.forEachConn(func(conn net.Conn) {
+		conn.Close()
+	})
+	log.Printf("Syslog collector stopped with %d messages abandoned", abandoned)
+	return abandoned, fmt.Errorf("timed out waiting for in-flight messages: %w", ctx.Err())
+}
+
+// errStopped is returned when a socket is opened after the collector was stopped
+var errStopped = errors.New("collector stopped")
+
+// register records a new socket with set so that closeListeners closes it
+// It returns false when the collector is stopping, and the caller must close the socket
+func (c *Collector) register(set func()) bool {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+
+	if c.ctx.Err() != nil {
+		return false
+	}
+	set()
+	return true
+}
+
+// closeListeners closes the sockets of the listener
+// The context must be cancelled first, so that no socket is registered afterwards
+func (c *Collector) closeListeners() error {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+
+	var firstErr error
+	keep := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	// Close UDP sockets
+	c.udpMu.Lock()
+	conns := c.udpConns
+	c.udpMu.Unlock()
+	for _, conn := range conns {
+		if err := conn.Close(); err != nil {
+			keep(fmt.Errorf("failed to close UDP connection: %w", err))
+		}
+	}
+
+	// Close TCP listener
+	if c.tcpListener != nil {
+		if err := c.tcpListener.Close(); err != nil {
+			keep(fmt.Errorf("failed to close TCP listener: %w", err))
+		}
+	}
+
+	// Close unix sockets and remove the socket file
+	if c.unixConn != nil {
+		if err := c.unixConn.Close(); err != nil {
+			keep(fmt.Errorf("failed to close unix datagram socket: %w", err))
+		}
+		os.Remove(c.address)
+	}
+	if c.unixListener != nil {
+		// Closing a unix listener removes its socket file
+		if err := c.unixListener.Close(); err != nil {
+			keep(fmt.Errorf("failed to close unix stream socket: %w", err))
+		}
+	}
+
+	return firstErr
+}
+
+// track registers a goroutine that Stop waits for; it returns false once the
+// collector is stopping, and the caller must then not start it
+func (c *Collector) track() bool {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+
+	if c.stopping {
+		return false
+	}
+	c.wg.Add(1)
+	return true
+}
+
+// addConn registers an open stream connection
+func (c *Collector) addConn(conn net.Conn) {
+	c.stopMu.Lock()
+	c.active[conn] = struct{}{}
+	c.stopMu.Unlock()
+}
+
+// removeConn unregisters a closed stream connection
+func (c *Collector) removeConn(conn net.Conn) {
+	c.stopMu.Lock()
+	delete(c.active, conn)
+	c.stopMu.Unlock()
+}
+
+// forEachConn calls fn on every open stream connection
+func (c *Collector) forEachConn(fn func(net.Conn)) {
+	c.stopMu.Lock()
+	defer c.stopMu.Unlock()
+
+	for conn := range c.active {
+		fn(conn)
+	}
+}
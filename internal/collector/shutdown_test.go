@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/framing"
+	"syslog-visualizer/internal/parser"
+)
+
+func TestStopDrainsConnections(t *testing.T) {
+	var handled atomic.Int64
+	started := make(chan struct{}, 10)
+	c, err := New(Config{
+		Protocol:      "tcp",
+		FramingMethod: framing.NonTransparent,
+		Handler: func(msg *parser.SyslogMessage) error {
+			started <- struct{}{}
+			time.Sleep(20 * time.Millisecond)
+			handled.Add(1)
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	client, server := net.Pipe()
+	defer client.Close()
+	c.accept(server, c.handleTCPConnection)
+	go client.Write([]byte("<13>Oct 11 22:14:15 host app: 1\n<13>Oct 11 22:14:15 host app: 2\n<13>Oct 11 22:14:15 host app: 3\n"))
+	<-started
+
+	// The connection stays open: Stop must end it once the received messages are handled
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	abandoned, err := c.Stop(ctx)
+	if err != nil || abandoned != 0 {
+		t.Errorf("Stop() = %d, %v, want 0 abandoned", abandoned, err)
+	}
+	if got := handled.Load(); got != 3 {
+		t.Errorf("handled %d messages, want 3", got)
+	}
+
+	// A stopped collector refuses new connections
+	other, otherServer := net.Pipe()
+	c.accept(otherServer, c.handleTCPConnection)
+	if _, err := other.Write([]byte("x")); err == nil {
+		t.Errorf("write to a stopped collector succeeded")
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	c, err := New(Config{
+		Protocol:      "tcp",
+		FramingMethod: framing.NonTransparent,
+		Handler: func(msg *parser.SyslogMessage) error {
+			close(started)
+			<-release
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer close(release)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	c.accept(server, c.handleTCPConnection)
+	go client.Write([]byte("<13>Oct 11 22:14:15 host app: stuck\n"))
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	abandoned, err := c.Stop(ctx)
+	if err == nil || abandoned != 1 {
+		t.Errorf("Stop() = %d, %v, want 1 abandoned and an error", abandoned, err)
+	}
+}
+
+func TestStartBothStopsOnError(t *testing.T) {
+	// Hold the TCP port so that the TCP side fails
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer busy.Close()
+
+	c, err := New(Config{Protocol: "both", Address: busy.Addr().String()})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- c.Start() }()
+	select {
+	case err := <-errChan:
+		if err == nil {
+			t.Errorf("Start() = nil, want the TCP error")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Start() did not return after the TCP side failed")
+	}
+
+	// The UDP side was stopped and released its port
+	conn, err := net.ListenPacket("udp", busy.Addr().String())
+	if err != nil {
+		t.Errorf("UDP port still in use after Start() returned: %v", err)
+	} else {
+		conn.Close()
+	}
+}
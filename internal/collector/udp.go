@@ -0,0 +1,199 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// udpQueuePerWorker is the number of packets queued per parse worker before the
+// readers wait, leaving the backlog to the socket receive buffer
+const udpQueuePerWorker = 1024
+
+// udpPacket is a datagram waiting for a parse worker
+type udpPacket struct {
+	raw string
+	src source
+}
+
+// listenUDP opens the UDP sockets of the listener: one per reader, sharing the port
+// with SO_REUSEPORT when there are several, with the configured receive buffer size
+func (c *Collector) listenUDP(readers int) ([]*net.UDPConn, error) {
+	address := c.address
+	conns := make([]*net.UDPConn, 0, readers)
+	for i := 0; i < readers; i++ {
+		config := net.ListenConfig{}
+		if readers > 1 {
+			config.Control = reusePortControl
+		}
+		packetConn, err := config.ListenPacket(context.Background(), "udp", address)
+		if err != nil {
+			closeUDP(conns)
+			return nil, err
+		}
+		conn := packetConn.(*net.UDPConn)
+		conns = append(conns, conn)
+
+		if c.receiveBuffer > 0 {
+			if err := conn.SetReadBuffer(c.receiveBuffer); err != nil {
+				closeUDP(conns)
+				return nil, fmt.Errorf("failed to set receive buffer: %w", err)
+			}
+		}
+
+		// With port 0 the first socket picks the port the others must share
+		address = conn.LocalAddr().String()
+	}
+
+	registered := c.register(func() {
+		c.udpMu.Lock()
+		c.udpConns = append(c.udpConns, conns...)
+		for _, conn := range conns {
+			if inode, err := socketInode(conn); err == nil {
+				c.udpInodes = append(c.udpInodes, inode)
+			}
+		}
+		c.udpMu.Unlock()
+	})
+	if !registered {
+		closeUDP(conns)
+		return nil, errStopped
+	}
+	return conns, nil
+}
+
+func closeUDP(conns []*net.UDPConn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// startUDP starts the UDP listener
+// Each reader reads its own socket; with workers, packets are parsed on a worker pool
+func (c *Collector) startUDP() error {
+	conns, err := c.listenUDP(c.readers)
+	if err == errStopped {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to start UDP listener: %w", err)
+	}
+
+	log.Printf("UDP syslog collector listening on %s (%d readers, %d workers)", c.address, len(conns), c.workers)
+
+	process := func(raw string, src source) {
+		c.processMessage(raw, src)
+	}
+	if c.workers > 0 {
+		packets := make(chan udpPacket, c.workers*udpQueuePerWorker)
+		defer close(packets)
+		for i := 0; i < c.workers; i++ {
+			// Start is tracked, so the wait group cannot be at zero here
+			c.wg.Add(1)
+			go func() {
+				defer c.wg.Done()
+				for packet := range packets {
+					select {
+					case <-c.halt:
+						// Stop gave up: drain the queue without handling it
+					default:
+						c.processMessage(packet.raw, packet.src)
+					}
+					c.pending.Add(-1)
+				}
+			}()
+		}
+		process = func(raw string, src source) {
+			c.pending.Add(1)
+			packets <- udpPacket{raw: raw, src: src}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer wg.Done()
+			c.readUDP(conn, process)
+		}(conn)
+	}
+	wg.Wait()
+	return nil
+}
+
+// readUDP reads datagrams from a socket until the collector stops
+func (c *Collector) readUDP(conn *net.UDPConn, process func(raw string, src source)) {
+	buffer := make([]byte, c.maxMessageSize)
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		default:
+			n, remoteAddr, err := conn.ReadFromUDP(buffer)
+			if err != nil {
+				if c.ctx.Err() != nil {
+					// Collector is stopping
+					return
+				}
+				log.Printf("UDP read error: %v", err)
+				continue
+			}
+
+			process(string(buffer[:n]), source{addr: remoteAddr.String()})
+		}
+	}
+}
+
+// kernelDrops returns the datagrams the kernel dropped on the listener's sockets,
+// usually because the receive buffer was full
+func (c *Collector) kernelDrops() int64 {
+	c.udpMu.Lock()
+	inodes := make(map[uint64]bool, len(c.udpInodes))
+	for _, inode := range c.udpInodes {
+		inodes[inode] = true
+	}
+	c.udpMu.Unlock()
+
+	if len(inodes) == 0 {
+		return 0
+	}
+
+	var drops int64
+	for _, path := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		drops += parseUDPDrops(f, inodes)
+		f.Close()
+	}
+	return drops
+}
+
+// parseUDPDrops sums the drops column of /proc/net/udp for the given socket inodes
+func parseUDPDrops(r io.Reader, inodes map[uint64]bool) int64 {
+	var drops int64
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ref pointer drops
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 13 {
+			continue
+		}
+		inode, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil || !inodes[inode] {
+			continue
+		}
+		if n, err := strconv.ParseInt(fields[12], 10, 64); err == nil {
+			drops += n
+		}
+	}
+	return drops
+}
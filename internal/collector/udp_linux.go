@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"net"
+	"syscall"
+)
+
+// reusePortControl sets SO_REUSEPORT so that several sockets can bind the same port;
+// the kernel spreads datagrams across them by sender
+func reusePortControl(network, address string, raw syscall.RawConn) error {
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// socketInode returns the inode of a socket, which identifies it in /proc/net/udp
+func socketInode(conn *net.UDPConn) (uint64, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+
+	var stat syscall.Stat_t
+	var statErr error
+	if err := raw.Control(func(fd uintptr) {
+		statErr = syscall.Fstat(int(fd), &stat)
+	}); err != nil {
+		return 0, err
+	}
+	return stat.Ino, statErr
+}
@@ -0,0 +1,19 @@
+//go:build !linux
+
+package collector
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// reusePortControl is not supported outside Linux, so listeners have a single reader
+func reusePortControl(network, address string, raw syscall.RawConn) error {
+	return fmt.Errorf("multiple UDP readers need SO_REUSEPORT (Linux only)")
+}
+
+// socketInode is not supported outside Linux, where there is no /proc/net/udp
+func socketInode(conn *net.UDPConn) (uint64, error) {
+	return 0, fmt.Errorf("socket inodes are only available on Linux")
+}
@@ -0,0 +1,7 @@
+//go:build linux && (mips || mipsle || mips64 || mips64le || sparc64)
+
+package collector
+
+// soReusePort is SO_REUSEPORT, which MIPS and SPARC number differently from the
+// other architectures
+const soReusePort = 0x200
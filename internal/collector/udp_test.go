@@ -0,0 +1,179 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/parser"
+)
+
+// startTestUDP starts a UDP collector on a free local port and returns its address
+func startTestUDP(tb testing.TB, cfg Config) (*Collector, string) {
+	tb.Helper()
+	cfg.Protocol = "udp"
+	cfg.Address = "127.0.0.1:0"
+	c, err := New(cfg)
+	if err != nil {
+		tb.Fatalf("New() error = %v", err)
+	}
+
+	go c.Start()
+	tb.Cleanup(func() { c.Stop(context.Background()) })
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		c.udpMu.Lock()
+		conns := c.udpConns
+		c.udpMu.Unlock()
+		if len(conns) > 0 && len(conns) == c.readers {
+			return c, conns[0].LocalAddr().String()
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	tb.Fatalf("UDP listener did not start")
+	return nil, ""
+}
+
+func TestUDPReadersAndWorkers(t *testing.T) {
+	var mu sync.Mutex
+	got := make(map[string]bool)
+	c, addr := startTestUDP(t, Config{
+		Readers:       4,
+		Workers:       2,
+		ReceiveBuffer: 1 << 20,
+		Handler: func(msg *parser.SyslogMessage) error {
+			mu.Lock()
+			got[msg.Message] = true
+			mu.Unlock()
+			return nil
+		},
+	})
+
+	c.udpMu.Lock()
+	for _, conn := range c.udpConns[1:] {
+		if conn.LocalAddr().String() != addr {
+			t.Errorf("reader bound to %s, want the shared address %s", conn.LocalAddr(), addr)
+		}
+	}
+	c.udpMu.Unlock()
+
+	// Several senders, so that the kernel spreads them across the sockets
+	const senders, perSender = 8, 10
+	for i := 0; i < senders; i++ {
+		conn, err := net.Dial("udp", addr)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		for j := 0; j < perSender; j++ {
+			fmt.Fprintf(conn, "<13>Oct 11 22:14:15 host app: %d-%d", i, j)
+		}
+		conn.Close()
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		mu.Lock()
+		n := len(got)
+		mu.Unlock()
+		if n == senders*perSender {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != senders*perSender {
+		t.Errorf("received %d distinct messages, want %d", len(got), senders*perSender)
+	}
+	if stats := c.Stats(); stats.Received != senders*perSender {
+		t.Errorf("Stats().Received = %d, want %d", stats.Received, senders*perSender)
+	}
+}
+
+func TestParseUDPDrops(t *testing.T) {
+	procNetUDP := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  123: 00000000:0202 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 40001 2 0000000000000000 17
+  124: 00000000:0202 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 40002 2 0000000000000000 5
+  125: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 40003 2 0000000000000000 99
+`
+	inodes := map[uint64]bool{40001: true, 40002: true}
+	if got := parseUDPDrops(strings.NewReader(procNetUDP), inodes); got != 22 {
+		t.Errorf("parseUDPDrops() = %d, want 22", got)
+	}
+}
+
+// BenchmarkUDPIngest measures sustained messages/s through real sockets with a no-op
+// handler; compare the single reader (the original listener) with readers and workers,
+// on a machine with several CPUs:
+//
+//	go test ./internal/collector -run '^$' -bench UDPIngest
+func BenchmarkUDPIngest(b *testing.B) {
+	configs := []struct {
+		name string
+		cfg  Config
+	}{
+		{"readers=1/workers=0", Config{}},
+		{"readers=1/workers=4", Config{Workers: 4}},
+		{"readers=4/workers=0", Config{Readers: 4}},
+		{"readers=4/workers=4", Config{Readers: 4, Workers: 4}},
+	}
+
+	for _, bc := range configs {
+		b.Run(bc.name, func(b *testing.B) {
+			var handled atomic.Int64
+			cfg := bc.cfg
+			cfg.ReceiveBuffer = 8 << 20
+			cfg.Handler = func(msg *parser.SyslogMessage) error {
+				handled.Add(1)
+				return nil
+			}
+			_, addr := startTestUDP(b, cfg)
+
+			const senders = 8
+			payload := []byte("<134>1 2024-10-11T22:14:15.003Z web-01 nginx 4242 ID47 [meta user=\"alice\"] GET /index.html 200 512")
+
+			b.ResetTimer()
+			start := time.Now()
+			var wg sync.WaitGroup
+			for i := 0; i < senders; i++ {
+				n := b.N / senders
+				if i == 0 {
+					n += b.N % senders
+				}
+				wg.Add(1)
+				go func(n int) {
+					defer wg.Done()
+					conn, err := net.Dial("udp", addr)
+					if err != nil {
+						return
+					}
+					defer conn.Close()
+					for j := 0; j < n; j++ {
+						conn.Write(payload)
+					}
+				}(n)
+			}
+			wg.Wait()
+
+			// Wait until the listener has caught up, or stopped making progress
+			last := int64(-1)
+			for handled.Load() < int64(b.N) && handled.Load() != last {
+				last = handled.Load()
+				time.Sleep(50 * time.Millisecond)
+			}
+			elapsed := time.Since(start)
+			b.StopTimer()
+
+			got := handled.Load()
+			b.ReportMetric(float64(got)/elapsed.Seconds(), "msgs/s")
+			b.ReportMetric(100*float64(int64(b.N)-got)/float64(b.N), "%lost")
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+)
+
+// startUnixgram starts a unix datagram listener compatible with /dev/log
+func (c *Collector) startUnixgram() error {
+	if err := removeStaleSocket(c.address); err != nil {
+		return err
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: c.address, Net: "unixgram"})
+	if err != nil {
+		return fmt.Errorf("failed to start unix datagram listener: %w", err)
+	}
+	// Before the socket is opened to senders by chmod, so that every datagram has them
+	if err := enablePeerCredentials(conn); err != nil {
+		log.Printf("Unix socket %s: peer credentials unavailable: %v", c.address, err)
+	}
+	if !c.register(func() { c.unixConn = conn }) {
+		// Stopped while starting
+		conn.Close()
+		return nil
+	}
+
+	if err := os.Chmod(c.address, c.socketMode); err != nil {
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	log.Printf("Unix datagram syslog collector listening on %s (mode %04o)", c.address, c.socketMode)
+
+	buffer := make([]byte, c.maxMessageSize)
+	oob := make([]byte, credentialsOOBSize)
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		default:
+			n, oobn, _, _, err := conn.ReadMsgUnix(buffer, oob)
+			if err != nil {
+				if c.ctx.Err() != nil {
+					// Collector is stopping
+					return nil
+				}
+				log.Printf("Unix datagram read error: %v", err)
+				continue
+			}
+
+			raw := strings.TrimRight(string(buffer[:n]), "\n\x00")
+			c.processMessage(raw, source{
+				addr: "unix:" + c.address,
+				peer: peerCredentialsFromOOB(oob[:oobn]),
+			})
+		}
+	}
+}
+
+// startUnixStream starts a unix stream listener
+func (c *Collector) startUnixStream() error {
+	if err := removeStaleSocket(c.address); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("unix", c.address)
+	if err != nil {
+		return fmt.Errorf("failed to start unix stream listener: %w", err)
+	}
+	if !c.register(func() { c.unixListener = listener }) {
+		// Stopped while starting
+		listener.Close()
+		return nil
+	}
+
+	if err := os.Chmod(c.address, c.socketMode); err != nil {
+		return fmt.Errorf("failed to set socket permissions: %w", err)
+	}
+
+	log.Printf("Unix stream syslog collector listening on %s (mode %04o)", c.address, c.socketMode)
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return nil
+		default:
+			conn, err := listener.Accept()
+			if err != nil {
+				if c.ctx.Err() != nil {
+					// Collector is stopping
+					return nil
+				}
+				log.Printf("Unix accept error: %v", err)
+				continue
+			}
+
+			c.accept(conn, c.handleUnixConnection)
+		}
+	}
+}
+
+// handleUnixConnection reads messages from a unix stream connection
+// syslog(3) terminates each record with NUL, logger with LF
+func (c *Collector) handleUnixConnection(conn net.Conn) {
+	defer conn.Close()
+
+	src := source{
+		addr: "unix:" + c.address,
+		peer: peerCredentialsFromConn(conn),
+	}
+
+	deadlines := c.newDeadlineReader(conn)
+	scanner := bufio.NewScanner(deadlines)
+	scanner.Buffer(make([]byte, 0, 4096), c.maxMessageSize)
+	scanner.Split(scanLocalRecords)
+
+	for scanner.Scan() {
+		deadlines.next()
+		if raw := scanner.Text(); raw != "" {
+			c.processMessage(raw, src)
+		}
+	}
+
+	if err := scanner.Err(); err != nil && c.ctx.Err() == nil {
+		log.Printf("Unix stream read error from %s: %v", src.peer, err)
+	}
+}
+
+// scanLocalRecords is a bufio.SplitFunc splitting on LF or NUL
+func scanLocalRecords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\n\x00"); i >= 0 {
+		return i + 1, bytes.TrimRight(data[:i], "\r"), nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// peerCredentials identifies the process that sent a message to a local socket
+type peerCredentials struct {
+	pid int
+	uid int
+}
+
+func (p *peerCredentials) String() string {
+	if p == nil {
+		return "unknown peer"
+	}
+	return fmt.Sprintf("pid %d (uid %d)", p.pid, p.uid)
+}
+
+// removeStaleSocket removes a leftover socket file from a previous run
+// Refuses to remove anything that is not a socket
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat socket path: %w", err)
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove stale socket: %w", err)
+	}
+	return nil
+}
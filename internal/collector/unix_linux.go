@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"net"
+	"syscall"
+)
+
+// credentialsOOBSize is the ancillary buffer size for one SCM_CREDENTIALS message
+var credentialsOOBSize = syscall.CmsgSpace(syscall.SizeofUcred)
+
+// enablePeerCredentials asks the kernel to attach sender credentials (SO_PASSCRED) to each datagram
+func enablePeerCredentials(conn *net.UnixConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_PASSCRED, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// peerCredentialsFromOOB extracts the sender credentials from SCM_CREDENTIALS ancillary data
+func peerCredentialsFromOOB(oob []byte) *peerCredentials {
+	if len(oob) == 0 {
+		return nil
+	}
+
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+
+	for i := range msgs {
+		if cred, err := syscall.ParseUnixCredentials(&msgs[i]); err == nil {
+			return &peerCredentials{pid: int(cred.Pid), uid: int(cred.Uid)}
+		}
+	}
+	return nil
+}
+
+// peerCredentialsFromConn returns the credentials of the process connected to a unix
+// stream socket (SO_PEERCRED)
+func peerCredentialsFromConn(conn net.Conn) *peerCredentials {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+
+	raw, err := unixConn.SyscallConn()
+	if err != nil {
+		return nil
+	}
+
+	var cred *syscall.Ucred
+	raw.Control(func(fd uintptr) {
+		cred, _ = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+
+	if cred == nil {
+		return nil
+	}
+	return &peerCredentials{pid: int(cred.Pid), uid: int(cred.Uid)}
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package collector
+
+import "net"
+
+// credentialsOOBSize is zero where peer credentials are not supported
+var credentialsOOBSize = 0
+
+// enablePeerCredentials is a no-op outside Linux
+func enablePeerCredentials(conn *net.UnixConn) error {
+	return nil
+}
+
+// peerCredentialsFromOOB is not supported outside Linux
+func peerCredentialsFromOOB(oob []byte) *peerCredentials {
+	return nil
+}
+
+// peerCredentialsFromConn is not supported outside Linux
+func peerCredentialsFromConn(conn net.Conn) *peerCredentials {
+	return nil
+}
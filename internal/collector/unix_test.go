@@ -0,0 +1,172 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/parser"
+)
+
+// startTestUnix starts a unix socket collector in a temporary directory and returns
+// its socket path once senders may connect
+func startTestUnix(t *testing.T, protocol string, messages chan<- *parser.SyslogMessage) (*Collector, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "log.sock")
+	c, err := New(Config{
+		Protocol:   protocol,
+		Address:    path,
+		SocketMode: 0660,
+		Handler: func(msg *parser.SyslogMessage) error {
+			messages <- msg
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	go c.Start()
+	t.Cleanup(func() { c.Stop(context.Background()) })
+
+	// The socket is opened to senders by its final permissions
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() == 0660 {
+			return c, path
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("%s listener did not start", protocol)
+	return nil, ""
+}
+
+// receive waits for n messages
+func receive(t *testing.T, messages <-chan *parser.SyslogMessage, n int) []*parser.SyslogMessage {
+	t.Helper()
+	var got []*parser.SyslogMessage
+	for len(got) < n {
+		select {
+		case msg := <-messages:
+			got = append(got, msg)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("received %d messages, want %d", len(got), n)
+		}
+	}
+	return got
+}
+
+// checkLocalMessage checks a message sent by this process to a local socket
+func checkLocalMessage(t *testing.T, msg *parser.SyslogMessage, tag, pid, message string) {
+	t.Helper()
+	hostname, _ := os.Hostname()
+	if msg.Hostname != hostname || msg.Tag != tag || msg.PID != pid || msg.Message != message {
+		t.Errorf("Hostname, Tag, PID, Message = %q, %q, %q, %q, want %q, %q, %q, %q",
+			msg.Hostname, msg.Tag, msg.PID, msg.Message, hostname, tag, pid, message)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+	if msg.PeerPID != os.Getpid() {
+		t.Errorf("PeerPID = %d, want %d", msg.PeerPID, os.Getpid())
+	}
+	if msg.PeerUID == nil || *msg.PeerUID != os.Getuid() {
+		t.Errorf("PeerUID = %v, want %d", msg.PeerUID, os.Getuid())
+	}
+}
+
+func TestUnixgram(t *testing.T) {
+	messages := make(chan *parser.SyslogMessage, 10)
+	c, path := startTestUnix(t, "unixgram", messages)
+
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	// logger omits the hostname; syslog(3) ends datagrams with NUL
+	conn.Write([]byte("<13>Oct 11 22:14:15 backup[42]: started\n"))
+	conn.Write([]byte("<13>Oct 11 22:14:16 cron: job done\x00"))
+
+	got := receive(t, messages, 2)
+	checkLocalMessage(t, got[0], "backup", "42", "started")
+	// Without a PID in the message, the sender's is used
+	pid := strconv.Itoa(os.Getpid())
+	if runtime.GOOS != "linux" {
+		pid = ""
+	}
+	checkLocalMessage(t, got[1], "cron", pid, "job done")
+
+	if stats := c.Stats(); stats.Received != 2 {
+		t.Errorf("Stats().Received = %d, want 2", stats.Received)
+	}
+}
+
+func TestUnixStream(t *testing.T) {
+	messages := make(chan *parser.SyslogMessage, 10)
+	_, path := startTestUnix(t, "unix", messages)
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	// Records end with LF (logger) or NUL (syslog(3)), several per connection
+	conn.Write([]byte("<13>Oct 11 22:14:15 backup[42]: started\n<13>Oct 11 22:14:16 backup[42]: done\x00\n"))
+	conn.Close()
+
+	got := receive(t, messages, 2)
+	checkLocalMessage(t, got[0], "backup", "42", "started")
+	checkLocalMessage(t, got[1], "backup", "42", "done")
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := removeStaleSocket(file); err == nil {
+		t.Errorf("removeStaleSocket() of a regular file error = nil, want an error")
+	}
+
+	// A socket left by a previous run is replaced
+	messages := make(chan *parser.SyslogMessage, 1)
+	stale := filepath.Join(dir, "stale.sock")
+	listener, err := net.Listen("unix", stale)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listener.Close()
+
+	c, err := New(Config{Protocol: "unixgram", Address: stale, Handler: func(msg *parser.SyslogMessage) error {
+		messages <- msg
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	go c.Start()
+	defer c.Stop(context.Background())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if conn, err := net.Dial("unixgram", stale); err == nil {
+			conn.Write([]byte("<13>Oct 11 22:14:15 app: hello"))
+			conn.Close()
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := receive(t, messages, 1); got[0].Message != "hello" {
+		t.Errorf("Message = %q, want hello", got[0].Message)
+	}
+}
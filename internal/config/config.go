@@ -0,0 +1,311 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"syslog-visualizer/internal/auth"
+	"syslog-visualizer/internal/collector"
+	"syslog-visualizer/internal/dedup"
+	"syslog-visualizer/internal/enrich"
+	"syslog-visualizer/internal/forward"
+	"syslog-visualizer/internal/framing"
+	"syslog-visualizer/internal/grok"
+	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/internal/redact"
+	"syslog-visualizer/internal/routing"
+)
+
+// Config is the server configuration loaded from a YAML file
+type Config struct {
+	Collector  CollectorConfig  `yaml:"collector"`
+	Outputs    []forward.Config `yaml:"outputs"`
+	Grok       grok.Config      `yaml:"grok"`
+	Time       TimeConfig       `yaml:"time"`
+	Retention  RetentionConfig  `yaml:"retention"`
+	Dedup      dedup.Config     `yaml:"dedup"`
+	Redaction  redact.Config    `yaml:"redaction"`
+	Routing    routing.Config   `yaml:"routing"`
+	Enrichment enrich.Config    `yaml:"enrichment"`
+	Auth       auth.Config      `yaml:"auth"`
+}
+
+// RetentionConfig controls how long messages are kept; empty values keep the
+// command-line settings
+type RetentionConfig struct {
+	Period          string `yaml:"period"`           // e.g. 24h, 7d
+	CleanupInterval string `yaml:"cleanup_interval"` // e.g. 30m, 1h
+	Enabled         *bool  `yaml:"enabled"`
+}
+
+// TimeConfig controls how message timestamps are interpreted
+type TimeConfig struct {
+	DefaultTimezone  string                `yaml:"default_timezone"`   // Timezone of unmapped senders (default: the server's)
+	Timezones        []parser.TimezoneRule `yaml:"timezones"`          // Host / CIDR to timezone mapping
+	TrustReceiveTime bool                  `yaml:"trust_receive_time"` // Use the reception time instead of the sender's timestamp
+	MaxClockSkew     time.Duration         `yaml:"max_clock_skew"`     // Flag messages further than this from reception (0 disables)
+}
+
+// CollectorConfig holds the syslog listeners
+type CollectorConfig struct {
+	// Address and Protocol describe a single listener (kept for older config files)
+	Address  string `yaml:"address"`
+	Protocol string `yaml:"protocol"`
+
+	Listeners []ListenerConfig `yaml:"listeners"`
+}
+
+// ListenerConfig describes a single collector listener
+type ListenerConfig struct {
+	Name           string `yaml:"name"`
+	Protocol       string `yaml:"protocol"`         // "udp", "tcp", "both", "relp", "gelf-udp", "gelf-tcp", "unixgram" or "unix"
+	Address        string `yaml:"address"`          // host:port, or socket path for unix listeners
+	Framing        string `yaml:"framing"`          // TCP framing: "auto" (default), "octet-counting" or "non-transparent"
+	SocketMode     string `yaml:"socket_mode"`      // Permissions of unix sockets (e.g. "0666")
+	MaxMessageSize int    `yaml:"max_message_size"` // Maximum message size in bytes
+	Lenient        bool   `yaml:"lenient"`          // Accept messages without PRI, timestamp or hostname
+
+	MaxConnections          int                 `yaml:"max_connections"`            // Concurrent stream connections (0 for no limit)
+	MaxConnectionsPerSource int                 `yaml:"max_connections_per_source"` // Concurrent stream connections per source address
+	IdleTimeout             time.Duration       `yaml:"idle_timeout"`               // Close connections with no message for this long
+	ReadTimeout             time.Duration       `yaml:"read_timeout"`               // Close connections that take this long to finish a message
+	RateLimit               collector.RateLimit `yaml:"rate_limit"`                 // Per-source message rate limit
+
+	Allow        []string                 `yaml:"allow"`         // Source IPs or CIDR ranges allowed to send (empty for any)
+	Deny         []string                 `yaml:"deny"`          // Source IPs or CIDR ranges refused, even when allowed
+	RecordDenied bool                     `yaml:"record_denied"` // Keep refused messages in the dead-letter table
+	Hosts        []collector.ExpectedHost `yaml:"hosts"`         // Hostnames expected from source addresses
+
+	ProxyProtocol  bool     `yaml:"proxy_protocol"`  // Connections from trusted proxies start with a PROXY v1/v2 header
+	TrustedProxies []string `yaml:"trusted_proxies"` // Proxy IPs or CIDR ranges
+
+	Readers       int `yaml:"readers"`        // UDP sockets sharing the port with SO_REUSEPORT (default 1)
+	Workers       int `yaml:"workers"`        // UDP parse workers (0 parses on the readers)
+	ReceiveBuffer int `yaml:"receive_buffer"` // UDP socket receive buffer size in bytes
+}
+
+// Default returns the configuration used when no file is given
+func Default() *Config {
+	return &Config{
+		Collector: CollectorConfig{
+			Listeners: []ListenerConfig{
+				{Name: "udp", Protocol: "udp", Address: ":514"},
+			},
+		},
+	}
+}
+
+// Load reads and validates a configuration file
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	if len(cfg.Collector.Listeners) == 0 {
+		if cfg.Collector.Address != "" || cfg.Collector.Protocol != "" {
+			cfg.Collector.Listeners = []ListenerConfig{{
+				Address:  cfg.Collector.Address,
+				Protocol: cfg.Collector.Protocol,
+			}}
+		} else {
+			cfg.Collector.Listeners = Default().Collector.Listeners
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the configuration and fills in listener defaults
+func (c *Config) Validate() error {
+	names := make(map[string]bool)
+
+	for i := range c.Collector.Listeners {
+		l := &c.Collector.Listeners[i]
+
+		l.Protocol = strings.ToLower(l.Protocol)
+		if l.Protocol == "" {
+			l.Protocol = "udp"
+		}
+
+		switch l.Protocol {
+		case "udp", "tcp", "both":
+			if l.Address == "" {
+				l.Address = ":514"
+			}
+		case "relp":
+			if l.Address == "" {
+				l.Address = ":2514"
+			}
+		case "gelf-udp", "gelf-tcp":
+			if l.Address == "" {
+				l.Address = ":12201"
+			}
+		case "unixgram", "unix":
+			if l.Address == "" {
+				return fmt.Errorf("listener %d: socket path is required for protocol %s", i, l.Protocol)
+			}
+		default:
+			return fmt.Errorf("listener %d: unsupported protocol: %s", i, l.Protocol)
+		}
+
+		if l.Name == "" {
+			l.Name = l.Protocol + ":" + l.Address
+		}
+		if names[l.Name] {
+			return fmt.Errorf("duplicate listener name: %s", l.Name)
+		}
+		names[l.Name] = true
+
+		if _, err := l.FileMode(); err != nil {
+			return fmt.Errorf("listener %s: %w", l.Name, err)
+		}
+
+		switch strings.ToLower(l.Framing) {
+		case "", "auto", "octet-counting", "non-transparent":
+		default:
+			return fmt.Errorf("listener %s: unsupported framing: %s", l.Name, l.Framing)
+		}
+
+		if l.MaxConnections < 0 || l.MaxConnectionsPerSource < 0 || l.IdleTimeout < 0 || l.ReadTimeout < 0 {
+			return fmt.Errorf("listener %s: connection limits and timeouts must not be negative", l.Name)
+		}
+		if err := l.RateLimit.Validate(); err != nil {
+			return fmt.Errorf("listener %s: %w", l.Name, err)
+		}
+
+		if (l.Protocol == "unixgram" || l.Protocol == "unix") && (len(l.Allow) > 0 || len(l.Deny) > 0 || len(l.Hosts) > 0) {
+			return fmt.Errorf("listener %s: allow, deny and hosts need source addresses, which unix sockets lack", l.Name)
+		}
+
+		if l.Readers < 0 || l.Workers < 0 || l.ReceiveBuffer < 0 {
+			return fmt.Errorf("listener %s: readers, workers and receive_buffer must not be negative", l.Name)
+		}
+		if (l.Readers > 1 || l.Workers > 0) && l.Protocol != "udp" && l.Protocol != "both" {
+			return fmt.Errorf("listener %s: readers and workers are only supported on UDP listeners", l.Name)
+		}
+
+		if l.ProxyProtocol {
+			switch l.Protocol {
+			case "tcp", "both", "relp", "gelf-tcp":
+			default:
+				return fmt.Errorf("listener %s: proxy_protocol is only supported on TCP listeners", l.Name)
+			}
+			if len(l.TrustedProxies) == 0 {
+				return fmt.Errorf("listener %s: proxy_protocol needs trusted_proxies", l.Name)
+			}
+		}
+	}
+
+	if c.Dedup.Window < 0 || c.Dedup.MaxEntries < 0 {
+		return fmt.Errorf("dedup: window and max_entries must not be negative")
+	}
+
+	if err := c.Redaction.Validate(); err != nil {
+		return fmt.Errorf("redaction: %w", err)
+	}
+
+	outputs := make(map[string]bool)
+	for i, o := range c.Outputs {
+		if o.Name == "" {
+			return fmt.Errorf("output %d: name is required", i)
+		}
+		if outputs[o.Name] {
+			return fmt.Errorf("duplicate output name: %s", o.Name)
+		}
+		if o.Name == routing.StorageTarget {
+			return fmt.Errorf("output name %s is reserved for the database", o.Name)
+		}
+		outputs[o.Name] = true
+
+		if len(o.Targets) == 0 {
+			return fmt.Errorf("output %s: at least one target is required", o.Name)
+		}
+	}
+
+	if err := c.Routing.Validate(); err != nil {
+		return fmt.Errorf("routing: %w", err)
+	}
+	if err := c.Auth.Validate(); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
+	for _, target := range c.Routing.Targets() {
+		if target != routing.StorageTarget && !outputs[target] {
+			return fmt.Errorf("routing: unknown output: %s", target)
+		}
+	}
+
+	return nil
+}
+
+// FileMode parses SocketMode as an octal permission value (default 0666)
+func (l *ListenerConfig) FileMode() (os.FileMode, error) {
+	if l.SocketMode == "" {
+		return 0666, nil
+	}
+	mode, err := strconv.ParseUint(l.SocketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid socket_mode: %s", l.SocketMode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// FramingMethod returns the TCP framing method (default auto, detected per connection)
+func (l *ListenerConfig) FramingMethod() framing.FramingMethod {
+	switch strings.ToLower(l.Framing) {
+	case "octet-counting":
+		return framing.OctetCounting
+	case "non-transparent":
+		return framing.NonTransparent
+	default:
+		return framing.Auto
+	}
+}
+
+// ListenerChanges lists the listeners added, removed and changed between two configurations
+type ListenerChanges struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// DiffListeners compares the listeners of two validated configurations by name
+func DiffListeners(old, new []ListenerConfig) ListenerChanges {
+	changes := ListenerChanges{Added: []string{}, Removed: []string{}, Changed: []string{}}
+
+	previous := make(map[string]ListenerConfig, len(old))
+	for _, l := range old {
+		previous[l.Name] = l
+	}
+	current := make(map[string]bool, len(new))
+	for _, l := range new {
+		current[l.Name] = true
+		p, ok := previous[l.Name]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, l.Name)
+		case !reflect.DeepEqual(p, l):
+			changes.Changed = append(changes.Changed, l.Name)
+		}
+	}
+	for _, l := range old {
+		if !current[l.Name] {
+			changes.Removed = append(changes.Removed, l.Name)
+		}
+	}
+	return changes
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiffListeners(t *testing.T) {
+	old := []ListenerConfig{
+		{Name: "udp", Protocol: "udp", Address: ":514"},
+		{Name: "tcp", Protocol: "tcp", Address: ":514"},
+		{Name: "relp", Protocol: "relp", Address: ":2514"},
+	}
+	new := []ListenerConfig{
+		{Name: "udp", Protocol: "udp", Address: ":514"},
+		{Name: "tcp", Protocol: "tcp", Address: ":514", Allow: []string{"10.0.0.0/8"}},
+		{Name: "gelf", Protocol: "gelf-udp", Address: ":12201"},
+	}
+
+	got := DiffListeners(old, new)
+	want := ListenerChanges{Added: []string{"gelf"}, Removed: []string{"relp"}, Changed: []string{"tcp"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffListeners() = %+v, want %+v", got, want)
+	}
+
+	if got := DiffListeners(old, old); len(got.Added)+len(got.Removed)+len(got.Changed) != 0 {
+		t.Errorf("DiffListeners() of identical listeners = %+v, want no changes", got)
+	}
+}
@@ -0,0 +1,206 @@
+package dedup
+
+import (
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"syslog-visualizer/internal/parser"
+)
+
+const (
+	// DefaultMaxEntries is the number of distinct messages tracked at once by default
+	DefaultMaxEntries = 10000
+
+	// expireInterval is how often entries whose window is over are forgotten
+	expireInterval = time.Second
+)
+
+// digits matches the number runs masked by IgnoreNumbers
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// Config controls deduplication
+type Config struct {
+	Window        time.Duration `yaml:"window"`         // Identical messages within this window are stored once (0 disables)
+	IgnoreNumbers bool          `yaml:"ignore_numbers"` // Compare messages with their numbers masked
+	MaxEntries    int           `yaml:"max_entries"`    // Distinct messages tracked at once (default 10000)
+}
+
+// StoreFunc stores a message, setting its ID when the storage assigns one
+type StoreFunc func(msg *parser.SyslogMessage) error
+
+// AddRepeatsFunc adds repeats to a stored message
+type AddRepeatsFunc func(id uint, count int, lastSeen time.Time) error
+
+// Stats reports the deduplicator counters
+type Stats struct {
+	Collapsed int64 `json:"collapsed"` // Messages counted as repeats instead of stored
+	Tracked   int   `json:"tracked"`   // Distinct messages within their window
+}
+
+// key identifies identical messages
+type key struct {
+	hostname string
+	tag      string
+	severity int
+	message  string
+}
+
+// entry is a stored message whose window is open
+type entry struct {
+	id       uint      // ID of the stored message
+	started  time.Time // When the window opened
+	lastSeen time.Time // Timestamp of the last repeat
+}
+
+// Deduplicator collapses identical messages received within a window into the first
+// of them, whose repeat count and last seen time grow with the repeats
+// Store returns once the message or its repeat is committed, so that callers that
+// acknowledge messages (RELP, HTTP ingestion) never acknowledge an uncommitted one
+type Deduplicator struct {
+	store      StoreFunc
+	addRepeats AddRepeatsFunc
+
+	mu      sync.Mutex
+	cfg     Config
+	entries map[key]*entry
+
+	collapsed atomic.Int64
+	now       func() time.Time
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// New creates a deduplicator storing messages with store and counting repeats with
+// addRepeats; repeats that cannot be added are stored as a message of their own
+func New(cfg Config, store StoreFunc, addRepeats AddRepeatsFunc) *Deduplicator {
+	d := &Deduplicator{
+		store:      store,
+		addRepeats: addRepeats,
+		entries:    make(map[key]*entry),
+		now:        time.Now,
+		done:       make(chan struct{}),
+	}
+	d.SetConfig(cfg)
+
+	d.wg.Add(1)
+	go d.expireLoop()
+	return d
+}
+
+// SetConfig changes the window and comparison of the messages received from now on
+func (d *Deduplicator) SetConfig(cfg Config) {
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = DefaultMaxEntries
+	}
+	d.mu.Lock()
+	d.cfg = cfg
+	d.mu.Unlock()
+}
+
+// Store stores the message, or adds it as a repeat to an identical message stored
+// within the window
+func (d *Deduplicator) Store(msg *parser.SyslogMessage) error {
+	d.mu.Lock()
+	if d.cfg.Window <= 0 {
+		d.mu.Unlock()
+		return d.store(msg)
+	}
+
+	k := d.keyOf(msg)
+	now := d.now()
+	if e, ok := d.entries[k]; ok && now.Sub(e.started) < d.cfg.Window {
+		if msg.Timestamp.After(e.lastSeen) {
+			e.lastSeen = msg.Timestamp
+		}
+		id, lastSeen := e.id, e.lastSeen
+		d.mu.Unlock()
+
+		err := d.addRepeats(id, 1, lastSeen)
+		if err == nil {
+			d.collapsed.Add(1)
+			return nil
+		}
+		// E.g. the stored message was deleted: this one opens a new window
+		log.Printf("Dedup: failed to add a repeat to message %d, storing it: %v", id, err)
+		d.mu.Lock()
+		if d.entries[k] == e {
+			delete(d.entries, k)
+		}
+		d.mu.Unlock()
+	} else {
+		d.mu.Unlock()
+	}
+
+	msg.RepeatCount = 1
+	msg.FirstSeen = msg.Timestamp
+	msg.LastSeen = msg.Timestamp
+	if err := d.store(msg); err != nil {
+		return err
+	}
+
+	// Only a stored message with an ID (not spooled) can take repeats
+	if msg.ID != 0 {
+		d.mu.Lock()
+		if _, expired := d.entries[k]; expired || len(d.entries) < d.cfg.MaxEntries {
+			d.entries[k] = &entry{id: msg.ID, started: now, lastSeen: msg.Timestamp}
+		}
+		d.mu.Unlock()
+	}
+	return nil
+}
+
+// keyOf returns the comparison key of a message (caller must hold the lock)
+func (d *Deduplicator) keyOf(msg *parser.SyslogMessage) key {
+	message := strings.Join(strings.Fields(msg.Message), " ")
+	if d.cfg.IgnoreNumbers {
+		message = digits.ReplaceAllString(message, "#")
+	}
+	return key{hostname: msg.Hostname, tag: msg.Tag, severity: msg.Severity, message: message}
+}
+
+// expireLoop forgets the entries whose window is over
+func (d *Deduplicator) expireLoop() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			d.expire()
+		case <-d.done:
+			return
+		}
+	}
+}
+
+// expire drops the entries whose window is over
+func (d *Deduplicator) expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	for k, e := range d.entries {
+		if now.Sub(e.started) >= d.cfg.Window {
+			delete(d.entries, k)
+		}
+	}
+}
+
+// Stats returns the deduplicator counters
+func (d *Deduplicator) Stats() Stats {
+	d.mu.Lock()
+	tracked := len(d.entries)
+	d.mu.Unlock()
+	return Stats{Collapsed: d.collapsed.Load(), Tracked: tracked}
+}
+
+// Close stops the expiry loop; every repeat is already committed
+func (d *Deduplicator) Close() {
+	close(d.done)
+	d.wg.Wait()
+}
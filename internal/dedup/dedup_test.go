@@ -0,0 +1,140 @@
+package dedup
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/parser"
+)
+
+// fakeStore records stored messages and the repeats added to them
+type fakeStore struct {
+	mu      sync.Mutex
+	stored  []parser.SyslogMessage
+	repeats map[uint]int
+	fail    bool // addRepeats fails
+	down    bool // store fails
+}
+
+func (s *fakeStore) store(msg *parser.SyslogMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.down {
+		return errTest
+	}
+	msg.ID = uint(len(s.stored) + 1)
+	s.stored = append(s.stored, *msg)
+	return nil
+}
+
+func (s *fakeStore) addRepeats(id uint, count int, lastSeen time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.fail {
+		return errTest
+	}
+	s.repeats[id] += count
+	s.stored[id-1].LastSeen = lastSeen
+	return nil
+}
+
+var errTest = errors.New("storage down")
+
+func TestDeduplicator(t *testing.T) {
+	fs := &fakeStore{repeats: make(map[uint]int)}
+	d := New(Config{Window: time.Minute, IgnoreNumbers: true}, fs.store, fs.addRepeats)
+	defer d.Close()
+
+	now := time.Date(2024, 10, 11, 22, 0, 0, 0, time.UTC)
+	d.now = func() time.Time { return now }
+
+	message := func(text string, severity int, at time.Time) *parser.SyslogMessage {
+		return &parser.SyslogMessage{Hostname: "sw1", Tag: "ifmgr", Severity: severity, Message: text, Timestamp: at}
+	}
+
+	d.Store(message("Interface ge-0/0/1 down", 3, now))
+	d.Store(message("Interface  ge-0/0/2 down", 3, now.Add(time.Second)))  // Numbers masked, spaces collapsed
+	d.Store(message("Interface ge-0/0/1 down", 3, now.Add(2*time.Second))) // Repeat
+	d.Store(message("Interface ge-0/0/1 down", 4, now.Add(3*time.Second))) // Other severity
+	d.Store(message("Interface ge-0/0/1 up", 3, now.Add(4*time.Second)))   // Other message
+	d.Store(message("Interface ge-0/0/1 down", 3, now.Add(5*time.Second))) // Repeat
+	if len(fs.stored) != 3 {
+		t.Fatalf("stored %d messages, want 3", len(fs.stored))
+	}
+	if got := d.Stats(); got.Collapsed != 3 || got.Tracked != 3 {
+		t.Errorf("Stats() = %+v, want 3 collapsed and 3 tracked", got)
+	}
+
+	// Repeats are added before Store returns
+	if fs.repeats[1] != 3 {
+		t.Errorf("repeats added to message 1 = %d, want 3", fs.repeats[1])
+	}
+	if want := now.Add(5 * time.Second); !fs.stored[0].LastSeen.Equal(want) {
+		t.Errorf("LastSeen = %v, want %v", fs.stored[0].LastSeen, want)
+	}
+	if fs.stored[0].RepeatCount != 1 || !fs.stored[0].FirstSeen.Equal(now) {
+		t.Errorf("stored message RepeatCount = %d, FirstSeen = %v, want 1 and %v", fs.stored[0].RepeatCount, fs.stored[0].FirstSeen, now)
+	}
+
+	// After the window a message is stored again
+	now = now.Add(2 * time.Minute)
+	d.Store(message("Interface ge-0/0/1 down", 3, now))
+	if len(fs.stored) != 4 {
+		t.Errorf("stored %d messages after the window, want 4", len(fs.stored))
+	}
+
+	// A repeat that cannot be added is stored as a message of its own
+	fs.fail = true
+	if err := d.Store(message("Interface ge-0/0/1 down", 3, now.Add(time.Second))); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	last := fs.stored[len(fs.stored)-1]
+	if len(fs.stored) != 5 || last.RepeatCount != 1 || !last.FirstSeen.Equal(now.Add(time.Second)) {
+		t.Errorf("last stored = %+v (of %d), want a message first seen at %v", last, len(fs.stored), now.Add(time.Second))
+	}
+	fs.fail = false
+}
+
+func TestDeduplicatorStoreFailure(t *testing.T) {
+	fs := &fakeStore{repeats: make(map[uint]int)}
+	d := New(Config{Window: time.Minute}, fs.store, fs.addRepeats)
+	defer d.Close()
+
+	msg := func() *parser.SyslogMessage {
+		return &parser.SyslogMessage{Hostname: "sw1", Message: "same", Timestamp: time.Now()}
+	}
+
+	// A message that failed to store is not a repeat of anything: its retransmission is stored
+	fs.down = true
+	if err := d.Store(msg()); !errors.Is(err, errTest) {
+		t.Fatalf("Store() error = %v, want %v", err, errTest)
+	}
+	fs.down = false
+	if err := d.Store(msg()); err != nil {
+		t.Fatalf("Store() error = %v", err)
+	}
+	if len(fs.stored) != 1 || d.Stats().Collapsed != 0 {
+		t.Errorf("stored %d messages, collapsed %d, want 1 and 0", len(fs.stored), d.Stats().Collapsed)
+	}
+
+	// A repeat that can be neither added nor stored is reported
+	fs.fail, fs.down = true, true
+	if err := d.Store(msg()); !errors.Is(err, errTest) {
+		t.Errorf("Store() error = %v, want %v", err, errTest)
+	}
+}
+
+func TestDeduplicatorDisabled(t *testing.T) {
+	fs := &fakeStore{repeats: make(map[uint]int)}
+	d := New(Config{}, fs.store, fs.addRepeats)
+	defer d.Close()
+
+	for i := 0; i < 3; i++ {
+		d.Store(&parser.SyslogMessage{Hostname: "sw1", Message: "same"})
+	}
+	if len(fs.stored) != 3 {
+		t.Errorf("stored %d messages, want 3", len(fs.stored))
+	}
+}
@@ -0,0 +1,567 @@
+package diskqueue
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrEmpty is returned by Peek when there is no pending record
+var ErrEmpty = errors.New("queue is empty")
+
+// ErrFull is returned by Append when the queue has reached its maximum size
+var ErrFull = errors.New("queue is full")
+
+// SyncPolicy controls when appended records are fsynced to disk
+type SyncPolicy string
+
+const (
+	SyncAlways   SyncPolicy = "always"   // fsync after every append
+	SyncInterval SyncPolicy = "interval" // fsync periodically (default)
+	SyncNever    SyncPolicy = "never"    // leave it to the operating system
+)
+
+const (
+	recordHeaderSize = 8 // length (4) + CRC32 (4)
+	segmentSuffix    = ".seg"
+	cursorFile       = "cursor"
+)
+
+// Options configures a queue
+type Options struct {
+	Dir          string        // Directory holding segment files
+	SegmentSize  int64         // Rotate segments after this many bytes (default 16 MB)
+	MaxSize      int64         // Maximum bytes pending on disk, 0 for unlimited
+	SyncPolicy   SyncPolicy    // When to fsync (default interval)
+	SyncInterval time.Duration // fsync period for SyncInterval (default 1s)
+}
+
+// Queue is a persistent FIFO of byte records stored in segment files
+// Records are appended to the newest segment and consumed with Peek/Ack;
+// fully consumed segments are deleted and the read position survives restarts
+type Queue struct {
+	opts Options
+	mu   sync.Mutex
+
+	writeSeg  uint64
+	writeFile *os.File
+	writeSize int64
+	dirty     bool
+
+	readSeg    uint64
+	readOffset int64
+	readFile   *os.File
+	reader     *bufio.Reader
+	peeked     []byte
+
+	cursor *os.File
+
+	count   int
+	size    int64
+	corrupt int64
+
+	notify chan struct{}
+	done   chan struct{}
+	closed bool
+}
+
+// Open opens (or creates) a queue in opts.Dir
+func Open(opts Options) (*Queue, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("queue directory is required")
+	}
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = 16 << 20
+	}
+	if opts.SyncPolicy == "" {
+		opts.SyncPolicy = SyncInterval
+	}
+	switch opts.SyncPolicy {
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("unknown sync policy: %s", opts.SyncPolicy)
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	q := &Queue{
+		opts:   opts,
+		notify: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+
+	if err := q.load(); err != nil {
+		q.closeFiles()
+		return nil, err
+	}
+
+	if opts.SyncPolicy == SyncInterval {
+		go q.syncLoop()
+	}
+
+	return q, nil
+}
+
+// load restores the read cursor and write position from disk
+func (q *Queue) load() error {
+	segments, err := q.listSegments()
+	if err != nil {
+		return err
+	}
+
+	cursor, err := os.OpenFile(filepath.Join(q.opts.Dir, cursorFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open cursor: %w", err)
+	}
+	q.cursor = cursor
+
+	readSeg, readOffset := q.readCursor()
+
+	// Drop segments that were fully consumed before a restart
+	for len(segments) > 0 && segments[0] < readSeg {
+		os.Remove(q.segmentPath(segments[0]))
+		segments = segments[1:]
+	}
+
+	if len(segments) == 0 {
+		segments = []uint64{readSeg}
+		if readSeg == 0 {
+			segments = []uint64{1}
+		}
+		readOffset = 0
+	}
+	if segments[0] != readSeg {
+		readSeg, readOffset = segments[0], 0
+	}
+
+	q.readSeg = readSeg
+	q.readOffset = readOffset
+	q.writeSeg = segments[len(segments)-1]
+
+	// Count pending records and truncate a torn write at the end of the last segment
+	for _, seg := range segments {
+		offset := int64(0)
+		if seg == q.readSeg {
+			offset = q.readOffset
+		}
+		count, valid, err := q.scanSegment(seg, offset)
+		if err != nil {
+			return err
+		}
+		q.count += count
+		q.size += valid - offset
+
+		if seg == q.writeSeg {
+			if err := os.Truncate(q.segmentPath(seg), valid); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to truncate segment: %w", err)
+			}
+			q.writeSize = valid
+		}
+	}
+
+	writeFile, err := os.OpenFile(q.segmentPath(q.writeSeg), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	q.writeFile = writeFile
+
+	return q.writeCursor()
+}
+
+// scanSegment counts valid records from offset and returns the end of the last valid one
+func (q *Queue) scanSegment(seg uint64, offset int64) (int, int64, error) {
+	f, err := os.Open(q.segmentPath(seg))
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, 0, err
+	}
+
+	reader := bufio.NewReader(f)
+	count := 0
+	valid := offset
+	for {
+		data, err := readRecord(reader)
+		if err != nil {
+			break
+		}
+		count++
+		valid += recordHeaderSize + int64(len(data))
+	}
+	return count, valid, nil
+}
+
+// Append adds a record to the end of the queue
+func (q *Queue) Append(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return fmt.Errorf("queue is closed")
+	}
+
+	recordSize := recordHeaderSize + int64(len(data))
+	if q.opts.MaxSize > 0 && q.size+recordSize > q.opts.MaxSize {
+		return ErrFull
+	}
+
+	if q.writeSize > 0 && q.writeSize+recordSize > q.opts.SegmentSize {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+	}
+
+	record := make([]byte, recordSize)
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.ChecksumIEEE(data))
+	copy(record[recordHeaderSize:], data)
+
+	if _, err := q.writeFile.Write(record); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	q.writeSize += recordSize
+	q.size += recordSize
+	q.count++
+
+	if q.opts.SyncPolicy == SyncAlways {
+		if err := q.writeFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync segment: %w", err)
+		}
+	} else {
+		q.dirty = true
+	}
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// rotate starts a new write segment (caller must hold the lock)
+func (q *Queue) rotate() error {
+	if err := q.writeFile.Sync(); err != nil {
+		return fmt.Errorf("failed to sync segment: %w", err)
+	}
+	if err := q.writeFile.Close(); err != nil {
+		return fmt.Errorf("failed to close segment: %w", err)
+	}
+
+	q.writeSeg++
+	f, err := os.OpenFile(q.segmentPath(q.writeSeg), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %w", err)
+	}
+	q.writeFile = f
+	q.writeSize = 0
+	return nil
+}
+
+// Peek returns the oldest pending record without removing it
+// Calling Peek again before Ack returns the same record
+func (q *Queue) Peek() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil, fmt.Errorf("queue is closed")
+	}
+	if q.peeked != nil {
+		return q.peeked, nil
+	}
+
+	for {
+		if q.readFile == nil {
+			f, err := os.Open(q.segmentPath(q.readSeg))
+			if err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to open segment: %w", err)
+			}
+			if os.IsNotExist(err) {
+				if q.readSeg >= q.writeSeg {
+					return nil, ErrEmpty
+				}
+				if err := q.advanceLocked(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if _, err := f.Seek(q.readOffset, io.SeekStart); err != nil {
+				f.Close()
+				return nil, err
+			}
+			q.readFile = f
+			q.reader = bufio.NewReader(f)
+		}
+
+		data, err := readRecord(q.reader)
+		if err == nil {
+			q.peeked = data
+			return data, nil
+		}
+
+		corrupted := err != io.EOF && err != io.ErrUnexpectedEOF
+		if !corrupted && q.readSeg == q.writeSeg {
+			// Caught up with the writer; rewind so later appends are seen
+			q.readFile.Seek(q.readOffset, io.SeekStart)
+			q.reader.Reset(q.readFile)
+			return nil, ErrEmpty
+		}
+
+		if corrupted {
+			// The rest of this segment is unreadable: skip it
+			q.corrupt++
+			if q.readSeg == q.writeSeg {
+				if err := q.rotate(); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if err := q.advanceLocked(); err != nil {
+			return nil, err
+		}
+		if corrupted {
+			q.recountLocked()
+		}
+	}
+}
+
+// advanceLocked deletes the current read segment and moves to the next one
+func (q *Queue) advanceLocked() error {
+	if q.readFile != nil {
+		q.readFile.Close()
+		q.readFile = nil
+	}
+	os.Remove(q.segmentPath(q.readSeg))
+	q.readSeg++
+	q.readOffset = 0
+	return q.writeCursor()
+}
+
+// recountLocked recomputes the pending count and size from disk after records were lost
+func (q *Queue) recountLocked() {
+	q.count = 0
+	q.size = 0
+	for seg := q.readSeg; seg <= q.writeSeg; seg++ {
+		offset := int64(0)
+		if seg == q.readSeg {
+			offset = q.readOffset
+		}
+		count, valid, err := q.scanSegment(seg, offset)
+		if err != nil {
+			continue
+		}
+		q.count += count
+		q.size += valid - offset
+	}
+}
+
+// Ack removes the record returned by the last Peek
+func (q *Queue) Ack() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.peeked == nil {
+		return fmt.Errorf("no record to acknowledge")
+	}
+
+	recordSize := recordHeaderSize + int64(len(q.peeked))
+	q.readOffset += recordSize
+	q.size -= recordSize
+	q.count--
+	q.peeked = nil
+
+	return q.writeCursor()
+}
+
+// Len returns the number of pending records
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
+
+// Size returns the number of pending bytes on disk
+func (q *Queue) Size() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.size
+}
+
+// Corrupt returns the number of corrupt records skipped since the queue was opened
+func (q *Queue) Corrupt() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.corrupt
+}
+
+// Notify returns a channel signalled whenever a record is appended
+func (q *Queue) Notify() <-chan struct{} {
+	return q.notify
+}
+
+// Sync flushes appended records to disk
+func (q *Queue) Sync() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.syncLocked()
+}
+
+func (q *Queue) syncLocked() error {
+	if !q.dirty || q.closed {
+		return nil
+	}
+	q.dirty = false
+	if err := q.writeFile.Sync(); err != nil {
+		return err
+	}
+	return q.cursor.Sync()
+}
+
+// syncLoop periodically fsyncs for the interval policy
+func (q *Queue) syncLoop() {
+	ticker := time.NewTicker(q.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			q.Sync()
+		case <-q.done:
+			return
+		}
+	}
+}
+
+// Close syncs and closes the queue files
+func (q *Queue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return nil
+	}
+
+	var err error
+	if q.opts.SyncPolicy != SyncNever {
+		q.dirty = true
+		err = q.syncLocked()
+	}
+	q.closed = true
+	close(q.done)
+	q.closeFiles()
+	return err
+}
+
+func (q *Queue) closeFiles() {
+	if q.writeFile != nil {
+		q.writeFile.Close()
+	}
+	if q.readFile != nil {
+		q.readFile.Close()
+	}
+	if q.cursor != nil {
+		q.cursor.Close()
+	}
+}
+
+// readCursor returns the persisted read position (segment 0 if none)
+func (q *Queue) readCursor() (uint64, int64) {
+	buf := make([]byte, 64)
+	n, _ := q.cursor.ReadAt(buf, 0)
+	fields := strings.Fields(string(buf[:n]))
+	if len(fields) != 2 {
+		return 0, 0
+	}
+	seg, err1 := strconv.ParseUint(fields[0], 10, 64)
+	offset, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0
+	}
+	return seg, offset
+}
+
+// writeCursor persists the read position as a fixed-width record
+func (q *Queue) writeCursor() error {
+	line := fmt.Sprintf("%020d %020d\n", q.readSeg, q.readOffset)
+	if _, err := q.cursor.WriteAt([]byte(line), 0); err != nil {
+		return fmt.Errorf("failed to write cursor: %w", err)
+	}
+	if q.opts.SyncPolicy == SyncAlways {
+		return q.cursor.Sync()
+	}
+	q.dirty = true
+	return nil
+}
+
+// listSegments returns the segment IDs present in the directory, oldest first
+func (q *Queue) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(q.opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue directory: %w", err)
+	}
+
+	var segments []uint64
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err == nil {
+			segments = append(segments, id)
+		}
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+func (q *Queue) segmentPath(seg uint64) string {
+	return filepath.Join(q.opts.Dir, fmt.Sprintf("%020d%s", seg, segmentSuffix))
+}
+
+// readRecord reads one length-prefixed, checksummed record
+func readRecord(r *bufio.Reader) ([]byte, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	checksum := binary.BigEndian.Uint32(header[4:8])
+	if length > 64<<20 {
+		return nil, fmt.Errorf("invalid record length %d", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if crc32.ChecksumIEEE(data) != checksum {
+		return nil, fmt.Errorf("record checksum mismatch")
+	}
+	return data, nil
+}
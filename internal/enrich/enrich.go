@@ -0,0 +1,197 @@
+package enrich
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sync/atomic"
+
+	"syslog-visualizer/internal/mmdb"
+	"syslog-visualizer/internal/parser"
+)
+
+// Prefixes of the enrichment fields
+const (
+	AssetPrefix = "asset." // Inventory attributes, e.g. asset.owner
+	GeoPrefix   = "geo."   // GeoIP attributes, e.g. geo.country
+)
+
+// FacetFields are the enrichment fields offered as filter options
+var FacetFields = []string{"asset.owner", "asset.environment", "asset.location", "asset.criticality", "geo.country"}
+
+// maxGeoLookups bounds the addresses of a message looked up in the GeoIP database
+const maxGeoLookups = 8
+
+// addressCandidates finds what may be IPv4 or IPv6 addresses in a message
+var addressCandidates = regexp.MustCompile(`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b|[0-9A-Fa-f]{0,4}:[0-9A-Fa-f:]*:[0-9A-Fa-f.]*`)
+
+// Config names the enrichment sources; empty paths disable them
+type Config struct {
+	Inventory string `yaml:"inventory"` // Asset inventory: CSV with a header row, or a JSON array
+	GeoIP     string `yaml:"geoip"`     // MaxMind DB file, e.g. GeoLite2-City.mmdb or GeoLite2-ASN.mmdb
+}
+
+// Enabled reports whether a source is configured
+func (cfg Config) Enabled() bool {
+	return cfg.Inventory != "" || cfg.GeoIP != ""
+}
+
+// Sources are the loaded inventory and GeoIP database
+type Sources struct {
+	inventory *inventory
+	geoip     *mmdb.Reader
+}
+
+// Load reads the inventory and the GeoIP database of the configuration
+func Load(cfg Config) (*Sources, error) {
+	s := &Sources{}
+	if cfg.Inventory != "" {
+		inv, err := loadInventory(cfg.Inventory)
+		if err != nil {
+			return nil, err
+		}
+		s.inventory = inv
+	}
+	if cfg.GeoIP != "" {
+		reader, err := mmdb.Open(cfg.GeoIP)
+		if err != nil {
+			return nil, fmt.Errorf("GeoIP database %s: %w", cfg.GeoIP, err)
+		}
+		s.geoip = reader
+	}
+	return s, nil
+}
+
+// Stats reports the enrichment sources and counters
+type Stats struct {
+	Assets   int    `json:"assets"`          // Inventory entries
+	GeoIP    string `json:"geoip,omitempty"` // Type of the GeoIP database
+	Enriched int64  `json:"enriched"`        // Messages matched to an asset
+	Located  int64  `json:"located"`         // Messages with a GeoIP location
+}
+
+// Enricher adds inventory and GeoIP attributes to messages as fields
+type Enricher struct {
+	sources  atomic.Pointer[Sources]
+	enriched atomic.Int64
+	located  atomic.Int64
+}
+
+// New loads the sources of the configuration
+func New(cfg Config) (*Enricher, error) {
+	sources, err := Load(cfg)
+	if err != nil {
+		return nil, err
+	}
+	e := &Enricher{}
+	e.Set(sources)
+	return e, nil
+}
+
+// Set replaces the sources used for the messages received from now on
+func (e *Enricher) Set(sources *Sources) {
+	e.sources.Store(sources)
+}
+
+// Apply adds the attributes of the message's asset, found by hostname or sender address,
+// and the GeoIP attributes of the first public address of the message text or sender
+func (e *Enricher) Apply(msg *parser.SyslogMessage) {
+	s := e.sources.Load()
+	if s.inventory != nil {
+		if a, ok := s.inventory.lookup(msg.Hostname, msg.SourceHost); ok {
+			for key, value := range a {
+				msg.SetField(AssetPrefix+key, value)
+			}
+			e.enriched.Add(1)
+		}
+	}
+	if s.geoip != nil && locate(s.geoip, msg) {
+		e.located.Add(1)
+	}
+}
+
+// locate sets the GeoIP fields of the first address found in the database
+func locate(reader *mmdb.Reader, msg *parser.SyslogMessage) bool {
+	candidates := addressCandidates.FindAllString(msg.Message, maxGeoLookups)
+	if msg.SourceHost != "" {
+		candidates = append(candidates, msg.SourceHost)
+	}
+
+	for _, candidate := range candidates {
+		ip := net.ParseIP(candidate)
+		if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() {
+			continue
+		}
+		record, ok, err := reader.Lookup(ip)
+		if err != nil || !ok {
+			continue
+		}
+		fields := geoFields(record)
+		if len(fields) == 0 {
+			continue
+		}
+		msg.SetField(GeoPrefix+"ip", ip.String())
+		for key, value := range fields {
+			msg.SetField(GeoPrefix+key, value)
+		}
+		return true
+	}
+	return false
+}
+
+// geoFields maps a City, Country or ASN database record to fields
+func geoFields(record interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	set := func(key string, value interface{}) {
+		switch v := value.(type) {
+		case nil:
+		case string:
+			if v != "" {
+				fields[key] = v
+			}
+		default:
+			fields[key] = v
+		}
+	}
+
+	country := lookupPath(record, "country")
+	if country == nil {
+		country = lookupPath(record, "registered_country")
+	}
+	set("country", lookupPath(country, "iso_code"))
+	set("country_name", lookupPath(country, "names", "en"))
+	if subdivisions, ok := lookupPath(record, "subdivisions").([]interface{}); ok && len(subdivisions) > 0 {
+		set("region", lookupPath(subdivisions[0], "names", "en"))
+	}
+	set("city", lookupPath(record, "city", "names", "en"))
+	set("latitude", lookupPath(record, "location", "latitude"))
+	set("longitude", lookupPath(record, "location", "longitude"))
+	set("asn", lookupPath(record, "autonomous_system_number"))
+	set("as_org", lookupPath(record, "autonomous_system_organization"))
+	return fields
+}
+
+// lookupPath returns the value at a path of map keys, or nil
+func lookupPath(value interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+	return value
+}
+
+// Stats returns the enrichment sources and counters
+func (e *Enricher) Stats() Stats {
+	s := e.sources.Load()
+	stats := Stats{Enriched: e.enriched.Load(), Located: e.located.Load()}
+	if s.inventory != nil {
+		stats.Assets = s.inventory.size
+	}
+	if s.geoip != nil {
+		stats.GeoIP = s.geoip.Metadata.DatabaseType
+	}
+	return stats
+}
@@ -0,0 +1,160 @@
+package enrich
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"syslog-visualizer/internal/parser"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestInventory(t *testing.T) {
+	csvPath := writeFile(t, "assets.csv", `hostname,ip,owner,environment,location,criticality
+# comment
+pay-db-1,10.0.0.5,payments,production,par1,high
+,10.1.0.0/16,network,production,ams1,medium
+,10.1.2.0/24,network-lab,staging,ams1,low
+`)
+	jsonPath := writeFile(t, "assets.json", `[
+  {"hostname": "pay-db-1", "ip": "10.0.0.5", "owner": "payments", "environment": "production", "location": "par1", "criticality": "high"},
+  {"ip": "10.1.0.0/16", "owner": "network", "environment": "production", "location": "ams1", "criticality": "medium"},
+  {"ip": "10.1.2.0/24", "owner": "network-lab", "environment": "staging", "location": "ams1", "criticality": "low", "rack": 12}
+]`)
+
+	tests := []struct {
+		name       string
+		hostname   string
+		sourceHost string
+		wantOwner  string
+	}{
+		{"hostname", "PAY-DB-1", "192.0.2.1", "payments"},
+		{"source address", "unknown", "10.0.0.5", "payments"},
+		{"hostname as address", "10.0.0.5", "", "payments"},
+		{"most specific network", "sw1", "10.1.2.3", "network-lab"},
+		{"network", "sw2", "10.1.9.9", "network"},
+		{"unknown", "web-1", "192.0.2.1", ""},
+	}
+
+	for _, path := range []string{csvPath, jsonPath} {
+		inv, err := loadInventory(path)
+		if err != nil {
+			t.Fatalf("loadInventory(%s) error = %v", filepath.Base(path), err)
+		}
+		if inv.size != 3 {
+			t.Errorf("%s: size = %d, want 3", filepath.Base(path), inv.size)
+		}
+		for _, tt := range tests {
+			a, _ := inv.lookup(tt.hostname, tt.sourceHost)
+			if a["owner"] != tt.wantOwner {
+				t.Errorf("%s: %s: owner = %q, want %q", filepath.Base(path), tt.name, a["owner"], tt.wantOwner)
+			}
+		}
+	}
+}
+
+func TestInventoryErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"no identity", "a.csv", "hostname,ip,owner\n,,payments\n"},
+		{"bad address", "a.csv", "hostname,ip,owner\nh,10.0.0.300,payments\n"},
+		{"bad network", "a.csv", "hostname,ip,owner\nh,10.0.0.0/33,payments\n"},
+		{"bad row", "a.csv", "hostname,ip,owner\nh,10.0.0.1\n"},
+		{"bad json", "a.json", `{"hostname": "h"}`},
+		{"nested json", "a.json", `[{"hostname": "h", "tags": ["a"]}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := loadInventory(writeFile(t, tt.file, tt.content)); err == nil {
+				t.Errorf("loadInventory() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	path := writeFile(t, "assets.csv", "hostname,owner,environment\npay-db-1,payments,production\n")
+	e, err := New(Config{Inventory: path})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	msg := &parser.SyslogMessage{Hostname: "pay-db-1", Fields: map[string]interface{}{"status": "500"}}
+	e.Apply(msg)
+	want := map[string]interface{}{"status": "500", "asset.owner": "payments", "asset.environment": "production"}
+	if !reflect.DeepEqual(msg.Fields, want) {
+		t.Errorf("Fields = %v, want %v", msg.Fields, want)
+	}
+
+	other := &parser.SyslogMessage{Hostname: "web-1"}
+	e.Apply(other)
+	if other.Fields != nil {
+		t.Errorf("Fields of an unknown host = %v, want none", other.Fields)
+	}
+
+	if stats := e.Stats(); stats.Assets != 1 || stats.Enriched != 1 || stats.Located != 0 {
+		t.Errorf("Stats() = %+v, want 1 asset, 1 enriched", stats)
+	}
+
+	if _, err := New(Config{GeoIP: filepath.Join(t.TempDir(), "missing.mmdb")}); err == nil {
+		t.Errorf("New() with a missing GeoIP database error = nil, want an error")
+	}
+}
+
+func TestGeoFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		record interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name: "city",
+			record: map[string]interface{}{
+				"city":         map[string]interface{}{"names": map[string]interface{}{"en": "Paris", "fr": "Paris"}},
+				"country":      map[string]interface{}{"iso_code": "FR", "names": map[string]interface{}{"en": "France"}},
+				"subdivisions": []interface{}{map[string]interface{}{"names": map[string]interface{}{"en": "Île-de-France"}}},
+				"location":     map[string]interface{}{"latitude": 48.8566, "longitude": 2.3522},
+			},
+			want: map[string]interface{}{
+				"city": "Paris", "country": "FR", "country_name": "France", "region": "Île-de-France",
+				"latitude": 48.8566, "longitude": 2.3522,
+			},
+		},
+		{
+			name:   "registered country only",
+			record: map[string]interface{}{"registered_country": map[string]interface{}{"iso_code": "DE"}},
+			want:   map[string]interface{}{"country": "DE"},
+		},
+		{
+			name:   "asn",
+			record: map[string]interface{}{"autonomous_system_number": uint64(64496), "autonomous_system_organization": "Example Net"},
+			want:   map[string]interface{}{"asn": uint64(64496), "as_org": "Example Net"},
+		},
+		{
+			name:   "not a map",
+			record: "x",
+			want:   map[string]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := geoFields(tt.record); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("geoFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package enrich
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Inventory columns that identify an asset rather than describe it
+const (
+	columnHostname = "hostname"
+	columnIP       = "ip"
+)
+
+// asset holds the attributes of an inventory entry
+type asset map[string]string
+
+// inventory finds assets by hostname, address or network
+type inventory struct {
+	hostnames map[string]asset // Lower-case hostnames
+	addresses map[string]asset // Canonical addresses
+	networks  []inventoryNetwork
+	size      int
+}
+
+// inventoryNetwork is an asset declared for a CIDR range
+type inventoryNetwork struct {
+	network *net.IPNet
+	asset   asset
+}
+
+// loadInventory reads a JSON file (an array of objects) or a CSV file with a header row
+// Entries are identified by their hostname and/or ip (an address or a CIDR range); every
+// other column is an attribute
+func loadInventory(path string) (*inventory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open inventory: %w", err)
+	}
+	defer f.Close()
+
+	var records []map[string]string
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		records, err = readJSONInventory(f)
+	} else {
+		records, err = readCSVInventory(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory %s: %w", path, err)
+	}
+
+	inv := &inventory{hostnames: make(map[string]asset), addresses: make(map[string]asset)}
+	for i, record := range records {
+		if err := inv.add(record); err != nil {
+			return nil, fmt.Errorf("inventory %s, entry %d: %w", path, i+1, err)
+		}
+	}
+
+	// The most specific network is tried first
+	sort.SliceStable(inv.networks, func(i, j int) bool {
+		a, _ := inv.networks[i].network.Mask.Size()
+		b, _ := inv.networks[j].network.Mask.Size()
+		return a > b
+	})
+	return inv, nil
+}
+
+func readCSVInventory(r io.Reader) ([]map[string]string, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+	}
+
+	var records []map[string]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		record := make(map[string]string, len(header))
+		for i, value := range row {
+			record[header[i]] = strings.TrimSpace(value)
+		}
+		records = append(records, record)
+	}
+}
+
+func readJSONInventory(r io.Reader) ([]map[string]string, error) {
+	var entries []map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	records := make([]map[string]string, 0, len(entries))
+	for _, entry := range entries {
+		record := make(map[string]string, len(entry))
+		for key, value := range entry {
+			switch v := value.(type) {
+			case nil:
+			case string:
+				record[strings.ToLower(key)] = strings.TrimSpace(v)
+			case map[string]interface{}, []interface{}:
+				return nil, fmt.Errorf("attribute %s: nested values are not supported", key)
+			default:
+				record[strings.ToLower(key)] = fmt.Sprint(v)
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// add indexes an entry by its hostname and ip
+func (inv *inventory) add(record map[string]string) error {
+	hostname, ip := record[columnHostname], record[columnIP]
+	if hostname == "" && ip == "" {
+		return fmt.Errorf("hostname or ip is required")
+	}
+
+	attributes := make(asset, len(record))
+	for key, value := range record {
+		if key != columnHostname && key != columnIP && key != "" && value != "" {
+			attributes[key] = value
+		}
+	}
+
+	if hostname != "" {
+		inv.hostnames[strings.ToLower(hostname)] = attributes
+	}
+	switch {
+	case ip == "":
+	case strings.Contains(ip, "/"):
+		_, network, err := net.ParseCIDR(ip)
+		if err != nil {
+			return fmt.Errorf("invalid ip %q: %w", ip, err)
+		}
+		inv.networks = append(inv.networks, inventoryNetwork{network: network, asset: attributes})
+	default:
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			return fmt.Errorf("invalid ip %q", ip)
+		}
+		inv.addresses[addr.String()] = attributes
+	}
+	inv.size++
+	return nil
+}
+
+// lookup finds the asset of a hostname, then of the sender address, then of the hostname
+// read as an address
+func (inv *inventory) lookup(hostname, sourceHost string) (asset, bool) {
+	if a, ok := inv.hostnames[strings.ToLower(hostname)]; ok && hostname != "" {
+		return a, true
+	}
+	for _, candidate := range []string{sourceHost, hostname} {
+		ip := net.ParseIP(candidate)
+		if ip == nil {
+			continue
+		}
+		if a, ok := inv.addresses[ip.String()]; ok {
+			return a, true
+		}
+		for _, n := range inv.networks {
+			if n.network.Contains(ip) {
+				return n.asset, true
+			}
+		}
+	}
+	return nil, false
+}
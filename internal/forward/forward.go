@@ -0,0 +1,643 @@
+package forward
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"syslog-visualizer/internal/diskqueue"
+	"syslog-visualizer/internal/framing"
+	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/pkg/syslog"
+)
+
+const (
+	dialTimeout  = 5 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// Config describes a forwarding output
+type Config struct {
+	Name         string        `yaml:"name"`
+	Protocol     string        `yaml:"protocol"`      // "udp", "tcp" or "tls"
+	Targets      []string      `yaml:"targets"`       // host:port, in order of preference (failover)
+	Framing      string        `yaml:"framing"`       // TCP/TLS: "octet-counting" (default) or "non-transparent"
+	Format       string        `yaml:"format"`        // "rfc5424" (default) or "rfc3164"
+	TLS          TLSConfig     `yaml:"tls"`           // TLS settings for protocol "tls"
+	Filter       Filter        `yaml:"filter"`        // Only forward matching messages
+	Queue        QueueConfig   `yaml:"queue"`         // Retry queue
+	RetryInitial time.Duration `yaml:"retry_initial"` // First reconnect delay (default 1s)
+	RetryMax     time.Duration `yaml:"retry_max"`     // Maximum reconnect delay (default 1m)
+}
+
+// TLSConfig holds client TLS settings
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// QueueConfig configures the retry queue of an output
+type QueueConfig struct {
+	Path       string `yaml:"path"`        // Directory of a persistent queue; empty for an in-memory queue
+	MaxSizeMB  int    `yaml:"max_size_mb"` // Maximum size of the persistent queue (default 256)
+	MemorySize int    `yaml:"memory_size"` // Maximum records of the in-memory queue (default 10000)
+}
+
+// Filter selects the messages an output forwards
+// Empty lists match everything
+type Filter struct {
+	MinSeverity string   `yaml:"min_severity"` // e.g. "warning" forwards warning and more severe
+	Facilities  []string `yaml:"facilities"`
+	Hostnames   []string `yaml:"hostnames"`
+	Tags        []string `yaml:"tags"`
+}
+
+// OutputStats reports the state of an output
+type OutputStats struct {
+	Name      string `json:"name"`
+	Target    string `json:"target,omitempty"`
+	Connected bool   `json:"connected"`
+	Queued    int    `json:"queued"`
+	Sent      int64  `json:"sent"`
+	Dropped   int64  `json:"dropped"`
+	Failures  int64  `json:"failures"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// queue is the retry queue of an output (persistent or in memory)
+type queue interface {
+	Append(data []byte) error
+	Peek() ([]byte, error)
+	Ack() error
+	Len() int
+	Notify() <-chan struct{}
+	Close() error
+}
+
+// Forwarder sends messages to every configured output
+type Forwarder struct {
+	outputs []*Output
+}
+
+// New creates and starts the outputs
+func New(configs []Config) (*Forwarder, error) {
+	f := &Forwarder{}
+	for _, cfg := range configs {
+		output, err := newOutput(cfg)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("output %s: %w", cfg.Name, err)
+		}
+		f.outputs = append(f.outputs, output)
+	}
+	return f, nil
+}
+
+// Forward queues the message on every output whose filter matches
+// It never blocks: when a queue is full the message is dropped for that output
+func (f *Forwarder) Forward(msg *parser.SyslogMessage) {
+	for _, output := range f.outputs {
+		output.enqueue(msg)
+	}
+}
+
+// ForwardTo queues the message on the named outputs whose filter matches
+// Unknown names are ignored
+func (f *Forwarder) ForwardTo(msg *parser.SyslogMessage, names []string) {
+	for _, output := range f.outputs {
+		for _, name := range names {
+			if output.cfg.Name == name {
+				output.enqueue(msg)
+				break
+			}
+		}
+	}
+}
+
+// Stats returns the state of every output
+func (f *Forwarder) Stats() []OutputStats {
+	stats := make([]OutputStats, 0, len(f.outputs))
+	for _, output := range f.outputs {
+		stats = append(stats, output.stats())
+	}
+	return stats
+}
+
+// Stop stops the outputs after sending their queued messages until ctx ends
+// Messages left in persistent queues are sent on the next start; it returns the number of
+// messages left in memory queues, which are dropped
+func (f *Forwarder) Stop(ctx context.Context) (int64, error) {
+	var wg sync.WaitGroup
+	dropped := make([]int64, len(f.outputs))
+	errs := make([]error, len(f.outputs))
+	for i, output := range f.outputs {
+		wg.Add(1)
+		go func(i int, output *Output) {
+			defer wg.Done()
+			dropped[i], errs[i] = output.stop(ctx)
+		}(i, output)
+	}
+	wg.Wait()
+
+	var total int64
+	var firstErr error
+	for i := range f.outputs {
+		total += dropped[i]
+		if errs[i] != nil && firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	return total, firstErr
+}
+
+// Close stops the outputs without sending their queued messages
+func (f *Forwarder) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.Stop(ctx)
+	return err
+}
+
+// Output forwards messages to one upstream, failing over between its targets
+type Output struct {
+	cfg         Config
+	framing     framing.FramingMethod
+	tlsConfig   *tls.Config
+	maxSeverity int
+	facilities  map[int]bool
+	hostnames   map[string]bool
+	tags        map[string]bool
+	queue       queue
+
+	mu        sync.Mutex
+	conn      net.Conn
+	writer    *framing.Writer
+	target    string
+	sent      int64
+	dropped   int64
+	failures  int64
+	lastError string
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+func newOutput(cfg Config) (*Output, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(cfg.Targets) == 0 {
+		return nil, fmt.Errorf("at least one target is required")
+	}
+
+	cfg.Protocol = strings.ToLower(cfg.Protocol)
+	if cfg.Protocol == "" {
+		cfg.Protocol = "udp"
+	}
+	if cfg.Protocol != "udp" && cfg.Protocol != "tcp" && cfg.Protocol != "tls" {
+		return nil, fmt.Errorf("unsupported protocol: %s", cfg.Protocol)
+	}
+
+	cfg.Format = strings.ToLower(cfg.Format)
+	if cfg.Format == "" {
+		cfg.Format = "rfc5424"
+	}
+	if cfg.Format != "rfc5424" && cfg.Format != "rfc3164" {
+		return nil, fmt.Errorf("unsupported format: %s", cfg.Format)
+	}
+
+	if cfg.RetryInitial <= 0 {
+		cfg.RetryInitial = time.Second
+	}
+	if cfg.RetryMax <= 0 {
+		cfg.RetryMax = time.Minute
+	}
+
+	o := &Output{
+		cfg:         cfg,
+		framing:     framing.OctetCounting,
+		maxSeverity: syslog.SeverityDebug,
+		done:        make(chan struct{}),
+	}
+
+	switch strings.ToLower(cfg.Framing) {
+	case "", "octet-counting":
+	case "non-transparent":
+		o.framing = framing.NonTransparent
+	default:
+		return nil, fmt.Errorf("unsupported framing: %s", cfg.Framing)
+	}
+
+	if err := o.compileFilter(cfg.Filter); err != nil {
+		return nil, err
+	}
+
+	if cfg.Protocol == "tls" {
+		tlsConfig, err := buildTLSConfig(cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		o.tlsConfig = tlsConfig
+	}
+
+	if cfg.Queue.Path != "" {
+		maxSize := cfg.Queue.MaxSizeMB
+		if maxSize <= 0 {
+			maxSize = 256
+		}
+		q, err := diskqueue.Open(diskqueue.Options{
+			Dir:     cfg.Queue.Path,
+			MaxSize: int64(maxSize) << 20,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open queue: %w", err)
+		}
+		o.queue = q
+	} else {
+		size := cfg.Queue.MemorySize
+		if size <= 0 {
+			size = 10000
+		}
+		o.queue = newMemoryQueue(size)
+	}
+
+	o.wg.Add(1)
+	go o.run()
+
+	log.Printf("Forwarding output %s: %s %s (%s)", cfg.Name, strings.ToUpper(cfg.Protocol), strings.Join(cfg.Targets, ", "), cfg.Format)
+	return o, nil
+}
+
+// compileFilter prepares the filter lookups
+func (o *Output) compileFilter(filter Filter) error {
+	if filter.MinSeverity != "" {
+		severity, ok := syslog.ParseSeverity(filter.MinSeverity)
+		if !ok {
+			return fmt.Errorf("invalid min_severity: %s", filter.MinSeverity)
+		}
+		o.maxSeverity = severity
+	}
+
+	if len(filter.Facilities) > 0 {
+		o.facilities = make(map[int]bool)
+		for _, name := range filter.Facilities {
+			facility, ok := syslog.ParseFacility(name)
+			if !ok {
+				return fmt.Errorf("invalid facility: %s", name)
+			}
+			o.facilities[facility] = true
+		}
+	}
+
+	o.hostnames = stringSet(filter.Hostnames)
+	o.tags = stringSet(filter.Tags)
+	return nil
+}
+
+// matches reports whether the output's filter accepts the message
+func (o *Output) matches(msg *parser.SyslogMessage) bool {
+	if msg.Severity > o.maxSeverity {
+		return false
+	}
+	if o.facilities != nil && !o.facilities[msg.Facility] {
+		return false
+	}
+	if o.hostnames != nil && !o.hostnames[msg.Hostname] {
+		return false
+	}
+	if o.tags != nil && !o.tags[msg.Tag] {
+		return false
+	}
+	return true
+}
+
+// enqueue formats the message and adds it to the retry queue
+func (o *Output) enqueue(msg *parser.SyslogMessage) {
+	if !o.matches(msg) {
+		return
+	}
+
+	var line string
+	if o.cfg.Format == "rfc3164" {
+		line = msg.FormatRFC3164()
+	} else {
+		line = msg.FormatRFC5424()
+	}
+
+	if err := o.queue.Append([]byte(line)); err != nil {
+		o.mu.Lock()
+		o.dropped++
+		o.mu.Unlock()
+		if err != diskqueue.ErrFull {
+			log.Printf("Output %s: failed to queue message: %v", o.cfg.Name, err)
+		}
+	}
+}
+
+// run sends queued messages, reconnecting with exponential backoff
+func (o *Output) run() {
+	defer o.wg.Done()
+
+	backoff := o.cfg.RetryInitial
+	for {
+		select {
+		case <-o.done:
+			return
+		default:
+		}
+
+		queued, err := o.sendNext()
+		if !queued {
+			select {
+			case <-o.queue.Notify():
+			case <-time.After(time.Second):
+			case <-o.done:
+				return
+			}
+			continue
+		}
+
+		if err != nil {
+			log.Printf("Output %s: %v (retrying in %v)", o.cfg.Name, err, backoff)
+			select {
+			case <-time.After(backoff):
+			case <-o.done:
+				return
+			}
+			backoff *= 2
+			if backoff > o.cfg.RetryMax {
+				backoff = o.cfg.RetryMax
+			}
+			continue
+		}
+		backoff = o.cfg.RetryInitial
+	}
+}
+
+// sendNext sends the oldest queued message and reports whether there was one
+func (o *Output) sendNext() (bool, error) {
+	data, err := o.queue.Peek()
+	if err == diskqueue.ErrEmpty {
+		return false, nil
+	}
+	if err == nil {
+		err = o.send(data)
+	}
+	if err != nil {
+		o.mu.Lock()
+		o.failures++
+		o.lastError = err.Error()
+		o.mu.Unlock()
+		o.disconnect()
+		return true, err
+	}
+
+	o.queue.Ack()
+	o.mu.Lock()
+	o.sent++
+	o.mu.Unlock()
+	return true, nil
+}
+
+// send writes one formatted message, connecting first if needed
+func (o *Output) send(data []byte) error {
+	o.mu.Lock()
+	conn, writer := o.conn, o.writer
+	o.mu.Unlock()
+
+	if conn == nil {
+		var err error
+		conn, writer, err = o.connect()
+		if err != nil {
+			return err
+		}
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if o.cfg.Protocol == "udp" {
+		_, err := conn.Write(data)
+		return err
+	}
+	return writer.WriteMessage(string(data))
+}
+
+// connect dials the targets in order of preference and keeps the first that answers
+func (o *Output) connect() (net.Conn, *framing.Writer, error) {
+	var lastErr error
+	for _, target := range o.cfg.Targets {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+
+		var conn net.Conn
+		var err error
+		switch o.cfg.Protocol {
+		case "tls":
+			conn, err = tls.DialWithDialer(dialer, "tcp", target, o.tlsConfig)
+		default:
+			conn, err = dialer.Dial(o.cfg.Protocol, target)
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		writer := framing.NewWriter(conn, o.framing)
+
+		o.mu.Lock()
+		o.conn = conn
+		o.writer = writer
+		o.target = target
+		o.mu.Unlock()
+
+		log.Printf("Output %s: connected to %s", o.cfg.Name, target)
+		return conn, writer, nil
+	}
+	return nil, nil, fmt.Errorf("all targets unreachable: %w", lastErr)
+}
+
+// disconnect drops the current connection so the next send reconnects
+func (o *Output) disconnect() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.conn != nil {
+		o.conn.Close()
+		o.conn = nil
+		o.writer = nil
+		o.target = ""
+	}
+}
+
+func (o *Output) stats() OutputStats {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return OutputStats{
+		Name:      o.cfg.Name,
+		Target:    o.target,
+		Connected: o.conn != nil,
+		Queued:    o.queue.Len(),
+		Sent:      o.sent,
+		Dropped:   o.dropped,
+		Failures:  o.failures,
+		LastError: o.lastError,
+	}
+}
+
+// stop stops the sender, then sends the queued messages until ctx ends
+// It returns the number of messages dropped with an in-memory queue
+func (o *Output) stop(ctx context.Context) (int64, error) {
+	close(o.done)
+	o.wg.Wait()
+
+	// A write in progress at the deadline fails at once
+	stopWrite := context.AfterFunc(ctx, o.disconnect)
+	backoff := o.cfg.RetryInitial
+	for ctx.Err() == nil {
+		queued, err := o.sendNext()
+		if !queued {
+			break
+		}
+		if err != nil {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+			}
+			backoff = min(backoff*2, o.cfg.RetryMax)
+		}
+	}
+	stopWrite()
+	o.disconnect()
+
+	var dropped int64
+	if left := o.queue.Len(); left > 0 {
+		if o.cfg.Queue.Path != "" {
+			log.Printf("Output %s: %d queued message(s) kept for the next start", o.cfg.Name, left)
+		} else {
+			dropped = int64(left)
+			o.mu.Lock()
+			o.dropped += dropped
+			o.mu.Unlock()
+			log.Printf("Output %s: %d queued message(s) dropped", o.cfg.Name, left)
+		}
+	}
+	return dropped, o.queue.Close()
+}
+
+// buildTLSConfig loads the CA and client certificate files
+func buildTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+		MinVersion:         tls.VersionTLS12,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+func stringSet(values []string) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, v := range values {
+		set[v] = true
+	}
+	return set
+}
+
+// memoryQueue is a bounded in-memory retry queue
+type memoryQueue struct {
+	mu      sync.Mutex
+	records [][]byte
+	max     int
+	notify  chan struct{}
+}
+
+func newMemoryQueue(max int) *memoryQueue {
+	return &memoryQueue{max: max, notify: make(chan struct{}, 1)}
+}
+
+func (q *memoryQueue) Append(data []byte) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.records) >= q.max {
+		return diskqueue.ErrFull
+	}
+	q.records = append(q.records, data)
+
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (q *memoryQueue) Peek() ([]byte, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.records) == 0 {
+		return nil, diskqueue.ErrEmpty
+	}
+	return q.records[0], nil
+}
+
+func (q *memoryQueue) Ack() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.records) > 0 {
+		q.records[0] = nil
+		q.records = q.records[1:]
+	}
+	return nil
+}
+
+func (q *memoryQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.records)
+}
+
+func (q *memoryQueue) Notify() <-chan struct{} {
+	return q.notify
+}
+
+// Close drops the records left in the queue
+func (q *memoryQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.records = nil
+	return nil
+}
@@ -0,0 +1,175 @@
+package forward
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/framing"
+	"syslog-visualizer/internal/parser"
+)
+
+func TestForwardFailoverAndFilter(t *testing.T) {
+	// A target that refuses connections
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.Addr().String()
+	dead.Close()
+
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upstream.Close()
+
+	received := make(chan string, 10)
+	go func() {
+		conn, err := upstream.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := framing.NewReader(bufio.NewReader(conn), framing.OctetCounting)
+		for {
+			msg, err := reader.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	f, err := New([]Config{{
+		Name:         "upstream",
+		Protocol:     "tcp",
+		Targets:      []string{deadAddr, upstream.Addr().String()},
+		Filter:       Filter{MinSeverity: "warning"},
+		RetryInitial: 10 * time.Millisecond,
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer f.Close()
+
+	timestamp := time.Date(2024, 10, 11, 22, 14, 15, 3000, time.UTC)
+	f.Forward(&parser.SyslogMessage{Facility: 4, Severity: 6, Hostname: "host", Message: "filtered out", Timestamp: timestamp})
+	f.Forward(&parser.SyslogMessage{Facility: 4, Severity: 2, Hostname: "host", AppName: "su", ProcID: "42", Message: "forwarded", Timestamp: timestamp})
+
+	want := "<34>1 2024-10-11T22:14:15.000003Z host su 42 - - forwarded"
+	select {
+	case got := <-received:
+		if got != want {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("message not forwarded")
+	}
+
+	// The counter is updated after the write completes
+	deadline := time.Now().Add(time.Second)
+	for f.Stats()[0].Sent == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	stats := f.Stats()[0]
+	if stats.Target != upstream.Addr().String() || stats.Sent != 1 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestStopDrainsQueue(t *testing.T) {
+	// The upstream is down while messages are queued
+	upstream, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := upstream.Addr().String()
+	upstream.Close()
+
+	f, err := New([]Config{{Name: "upstream", Protocol: "tcp", Targets: []string{addr}, RetryInitial: time.Minute}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for _, text := range []string{"one", "two"} {
+		f.Forward(&parser.SyslogMessage{Severity: 2, Hostname: "host", Message: text, Timestamp: time.Now()})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for f.Stats()[0].Failures == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// It is back by shutdown: the queue is sent instead of waiting for the retry
+	upstream, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("cannot listen on %s again: %v", addr, err)
+	}
+	defer upstream.Close()
+	received := make(chan string, 10)
+	go func() {
+		conn, err := upstream.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := framing.NewReader(bufio.NewReader(conn), framing.OctetCounting)
+		for {
+			msg, err := reader.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- msg
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dropped, err := f.Stop(ctx)
+	if err != nil || dropped != 0 {
+		t.Errorf("Stop() = %d, %v, want nothing dropped", dropped, err)
+	}
+	for _, want := range []string{"one", "two"} {
+		select {
+		case got := <-received:
+			if !strings.HasSuffix(got, want) {
+				t.Errorf("received %q, want message %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("message %q not sent on shutdown", want)
+		}
+	}
+}
+
+func TestStopDropsAtDeadline(t *testing.T) {
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddr := dead.Addr().String()
+	dead.Close()
+
+	f, err := New([]Config{{Name: "dead", Protocol: "tcp", Targets: []string{deadAddr}, RetryInitial: 10 * time.Millisecond}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		f.Forward(&parser.SyslogMessage{Severity: 2, Hostname: "host", Message: "lost", Timestamp: time.Now()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	dropped, err := f.Stop(ctx)
+	if err != nil || dropped != 3 {
+		t.Errorf("Stop() = %d, %v, want 3 dropped", dropped, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Stop() took %v, want about the deadline", elapsed)
+	}
+	if stats := f.Stats()[0]; stats.Dropped != 3 || stats.Queued != 0 {
+		t.Errorf("Stats() = %+v, want 3 dropped and nothing queued", stats)
+	}
+}
@@ -2,6 +2,7 @@ package framing
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
 	"strconv"
@@ -21,13 +22,34 @@ const (
 	// Format: <message><delimiter>
 	// Delimiter is typically LF (\n) or NUL (\0)
 	NonTransparent
+
+	// Auto detects the framing of a stream from its first bytes (see AutoDetectFraming)
+	Auto
 )
 
+// TruncatedMarker is appended to messages cut at the maximum size
+const TruncatedMarker = " [truncated]"
+
+// String returns the configuration name of the framing method
+func (m FramingMethod) String() string {
+	switch m {
+	case OctetCounting:
+		return "octet-counting"
+	case NonTransparent:
+		return "non-transparent"
+	case Auto:
+		return "auto"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(m))
+	}
+}
+
 // Reader reads syslog messages from a TCP stream with proper framing
 type Reader struct {
-	reader  *bufio.Reader
-	method  FramingMethod
-	maxSize int
+	reader    *bufio.Reader
+	method    FramingMethod
+	maxSize   int
+	truncated bool
 }
 
 // NewReader creates a new framing reader
@@ -44,8 +66,29 @@ func (r *Reader) SetMaxSize(size int) {
 	r.maxSize = size
 }
 
+// Method returns the framing method, once detected when the reader was created with Auto
+func (r *Reader) Method() FramingMethod {
+	return r.method
+}
+
+// Truncated reports whether the last message read was cut at the maximum size
+func (r *Reader) Truncated() bool {
+	return r.truncated
+}
+
 // ReadMessage reads the next syslog message from the stream
+// Messages longer than the maximum size are truncated and end with TruncatedMarker
 func (r *Reader) ReadMessage() (string, error) {
+	r.truncated = false
+
+	if r.method == Auto {
+		method, err := AutoDetectFraming(r.reader)
+		if err != nil {
+			return "", err
+		}
+		r.method = method
+	}
+
 	switch r.method {
 	case OctetCounting:
 		return r.readOctetCounting()
@@ -77,70 +120,119 @@ func (r *Reader) readOctetCounting() (string, error) {
 	if length <= 0 {
 		return "", fmt.Errorf("invalid message length: %d", length)
 	}
-	if length > r.maxSize {
-		return "", fmt.Errorf("message length %d exceeds maximum %d", length, r.maxSize)
+
+	// Oversized messages are truncated, the rest of the frame is skipped
+	size := length
+	if size > r.maxSize {
+		size = r.maxSize
 	}
 
-	// Read exactly 'length' bytes
-	message := make([]byte, length)
+	// Read exactly 'size' bytes
+	message := make([]byte, size)
 	n, err := io.ReadFull(r.reader, message)
 	if err != nil {
 		return "", fmt.Errorf("failed to read message (expected %d bytes, got %d): %w", length, n, err)
 	}
 
+	if length > size {
+		if _, err := r.reader.Discard(length - size); err != nil {
+			return "", fmt.Errorf("failed to skip oversized message (%d bytes): %w", length, err)
+		}
+		r.truncated = true
+		return string(message) + TruncatedMarker, nil
+	}
+
 	return string(message), nil
 }
 
 // readNonTransparent reads a message using non-transparent framing
-// Messages are delimited by LF (\n) or NUL (\0)
-// Tries LF first, which is more common
+// Messages are delimited by LF (\n) or NUL (\0), whichever comes first;
+// a trailing CR is removed and empty frames are skipped
 func (r *Reader) readNonTransparent() (string, error) {
-	// Read until newline (most common delimiter)
-	message, err := r.reader.ReadString('\n')
-	if err != nil && err != io.EOF {
-		return "", fmt.Errorf("failed to read message: %w", err)
-	}
+	var message []byte
+
+	for {
+		if r.reader.Buffered() == 0 {
+			if _, err := r.reader.Peek(1); err != nil {
+				if err != io.EOF {
+					return "", fmt.Errorf("failed to read message: %w", err)
+				}
+				// A last message without delimiter
+				if len(message) > 0 || r.truncated {
+					return r.finishMessage(message), nil
+				}
+				return "", io.EOF
+			}
+		}
+
+		data, _ := r.reader.Peek(r.reader.Buffered())
+		end := bytes.IndexAny(data, "\n\x00")
+		if end < 0 {
+			message = r.appendLimited(message, data)
+			r.reader.Discard(len(data))
+			continue
+		}
 
-	// Remove the trailing delimiter
-	message = strings.TrimRight(message, "\n\r\x00")
+		message = r.appendLimited(message, data[:end])
+		r.reader.Discard(end + 1)
 
-	// Validate size
-	if len(message) > r.maxSize {
-		return "", fmt.Errorf("message length %d exceeds maximum %d", len(message), r.maxSize)
+		message = bytes.TrimSuffix(message, []byte("\r"))
+		if len(message) > 0 || r.truncated {
+			return r.finishMessage(message), nil
+		}
 	}
+}
 
-	return message, nil
+// appendLimited appends chunk to message up to the maximum size, recording truncation
+func (r *Reader) appendLimited(message, chunk []byte) []byte {
+	room := r.maxSize - len(message)
+	if room < 0 {
+		room = 0
+	}
+	if len(chunk) > room {
+		r.truncated = true
+		chunk = chunk[:room]
+	}
+	return append(message, chunk...)
+}
+
+func (r *Reader) finishMessage(message []byte) string {
+	if r.truncated {
+		return string(message) + TruncatedMarker
+	}
+	return string(message)
 }
 
 // AutoDetectFraming attempts to detect the framing method by peeking at the stream
 // Octet counting starts with digits followed by a space
 // Non-transparent starts with '<' (the priority field)
+// Bytes are peeked one at a time so a short first message does not block detection
 func AutoDetectFraming(r *bufio.Reader) (FramingMethod, error) {
-	// Peek at the first few bytes
-	peek, err := r.Peek(10)
-	if err != nil && err != io.EOF {
-		return NonTransparent, fmt.Errorf("failed to peek at stream: %w", err)
-	}
-
-	if len(peek) == 0 {
-		return NonTransparent, fmt.Errorf("empty stream")
-	}
+	for n := 1; n <= 10; n++ {
+		peek, err := r.Peek(n)
+		if len(peek) < n {
+			if len(peek) == 0 && err == io.EOF {
+				return NonTransparent, io.EOF
+			}
+			if err != io.EOF {
+				return NonTransparent, fmt.Errorf("failed to peek at stream: %w", err)
+			}
+			// The stream ended within the digits: not a length prefix
+			return NonTransparent, nil
+		}
 
-	// Check if it starts with digits (octet counting)
-	// Format: <length> <message>
-	for i, b := range peek {
-		if b >= '0' && b <= '9' {
+		b := peek[n-1]
+		switch {
+		case b >= '0' && b <= '9':
 			continue
-		}
-		if b == ' ' && i > 0 {
+		case b == ' ' && n > 1:
 			// Found digit(s) followed by space - likely octet counting
 			return OctetCounting, nil
 		}
-		// Not a digit or space - break
 		break
 	}
 
-	// Default to non-transparent framing (LF-delimited)
+	// Default to non-transparent framing (LF or NUL delimited)
 	return NonTransparent, nil
 }
 
@@ -3,8 +3,11 @@ package framing
 import (
 	"bufio"
 	"bytes"
+	"io"
+	"net"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestOctetCountingReader(t *testing.T) {
@@ -229,27 +232,138 @@ func TestAutoDetectFraming(t *testing.T) {
 }
 
 func TestMaxSize(t *testing.T) {
-	t.Run("Octet counting - exceeds max size", func(t *testing.T) {
-		input := "100 " + strings.Repeat("x", 100)
-		reader := NewReader(strings.NewReader(input), OctetCounting)
-		reader.SetMaxSize(50)
+	tests := []struct {
+		name   string
+		input  string
+		method FramingMethod
+	}{
+		{
+			name:   "Octet counting - exceeds max size",
+			input:  "100 " + strings.Repeat("x", 100) + "4 next",
+			method: OctetCounting,
+		},
+		{
+			name:   "Non-transparent - exceeds max size",
+			input:  strings.Repeat("x", 100) + "\nnext\n",
+			method: NonTransparent,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(tt.input), tt.method)
+			reader.SetMaxSize(50)
+
+			// Oversized messages are truncated and the stream stays usable
+			got, err := reader.ReadMessage()
+			if err != nil {
+				t.Fatalf("ReadMessage() error = %v", err)
+			}
+			if want := strings.Repeat("x", 50) + TruncatedMarker; got != want {
+				t.Errorf("ReadMessage() = %q, want %q", got, want)
+			}
+			if !reader.Truncated() {
+				t.Error("Truncated() = false, want true")
+			}
 
-		_, err := reader.ReadMessage()
-		if err == nil {
-			t.Error("Expected error for message exceeding max size")
+			got, err = reader.ReadMessage()
+			if err != nil {
+				t.Fatalf("second ReadMessage() error = %v", err)
+			}
+			if got != "next" || reader.Truncated() {
+				t.Errorf("second ReadMessage() = %q (truncated %v), want \"next\"", got, reader.Truncated())
+			}
+		})
+	}
+}
+
+func TestNULDelimitedStream(t *testing.T) {
+	reader := NewReader(strings.NewReader("<34>one\x00<34>two\x00\n<34>three\r\n<34>four"), NonTransparent)
+
+	for _, want := range []string{"<34>one", "<34>two", "<34>three", "<34>four"} {
+		got, err := reader.ReadMessage()
+		if err != nil {
+			t.Fatalf("ReadMessage() error = %v", err)
 		}
-	})
+		if got != want {
+			t.Errorf("ReadMessage() = %q, want %q", got, want)
+		}
+	}
 
-	t.Run("Non-transparent - exceeds max size", func(t *testing.T) {
-		input := strings.Repeat("x", 100) + "\n"
-		reader := NewReader(strings.NewReader(input), NonTransparent)
-		reader.SetMaxSize(50)
+	if _, err := reader.ReadMessage(); err != io.EOF {
+		t.Errorf("ReadMessage() at end error = %v, want io.EOF", err)
+	}
+}
+
+func TestAutoReader(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		method FramingMethod
+		want   []string
+	}{
+		{
+			name:   "Octet counting",
+			input:  "9 <34>hello10 <34>world!",
+			method: OctetCounting,
+			want:   []string{"<34>hello", "<34>world!"},
+		},
+		{
+			name:   "LF delimited",
+			input:  "<34>hello\n<34>world\n",
+			method: NonTransparent,
+			want:   []string{"<34>hello", "<34>world"},
+		},
+		{
+			name:   "Short message",
+			input:  "<1>x",
+			method: NonTransparent,
+			want:   []string{"<1>x"},
+		},
+	}
 
-		_, err := reader.ReadMessage()
-		if err == nil {
-			t.Error("Expected error for message exceeding max size")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := NewReader(strings.NewReader(tt.input), Auto)
+			for _, want := range tt.want {
+				got, err := reader.ReadMessage()
+				if err != nil {
+					t.Fatalf("ReadMessage() error = %v", err)
+				}
+				if got != want {
+					t.Errorf("ReadMessage() = %q, want %q", got, want)
+				}
+			}
+			if reader.Method() != tt.method {
+				t.Errorf("Method() = %v, want %v", reader.Method(), tt.method)
+			}
+		})
+	}
+}
+
+func TestAutoDetectDoesNotBlock(t *testing.T) {
+	// A short first message followed by silence must not wait for more bytes
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	go client.Write([]byte("<1>x\n"))
+
+	done := make(chan string, 1)
+	go func() {
+		reader := NewReader(server, Auto)
+		msg, _ := reader.ReadMessage()
+		done <- msg
+	}()
+
+	select {
+	case msg := <-done:
+		if msg != "<1>x" {
+			t.Errorf("ReadMessage() = %q, want %q", msg, "<1>x")
 		}
-	})
+	case <-time.After(2 * time.Second):
+		t.Fatal("ReadMessage() blocked on a short first message")
+	}
 }
 
 func TestOctetCountingWriter(t *testing.T) {
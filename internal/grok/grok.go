@@ -0,0 +1,194 @@
+package grok
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxExpandDepth bounds pattern references, which also catches reference cycles
+const maxExpandDepth = 16
+
+// BuiltinPatterns is the library of named patterns available to every rule
+var BuiltinPatterns = map[string]string{
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"INT":               `[+-]?[0-9]+`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"POSINT":            `\b[1-9][0-9]*\b`,
+	"NONNEGINT":         `\b[0-9]+\b`,
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"QS":                `%{QUOTEDSTRING}`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"MAC":               `(?:[A-Fa-f0-9]{2}[:-]){5}[A-Fa-f0-9]{2}|(?:[A-Fa-f0-9]{4}\.){2}[A-Fa-f0-9]{4}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"PATH":              `(?:/[\w%!$@:.,+~-]*)+`,
+	"URIPROTO":          `[A-Za-z][A-Za-z0-9+.-]+`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\[\]<>-]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"URI":               `%{URIPROTO}://(?:%{USER}(?::[^@]*)?@)?%{IPORHOST}?(?::%{POSINT})?(?:%{URIPATHPARAM})?`,
+	"MONTH":             `\b(?:Jan(?:uary)?|Feb(?:ruary)?|Mar(?:ch)?|Apr(?:il)?|May|Jun(?:e)?|Jul(?:y)?|Aug(?:ust)?|Sep(?:tember)?|Oct(?:ober)?|Nov(?:ember)?|Dec(?:ember)?)\b`,
+	"MONTHDAY":          `(?:0[1-9]|[12][0-9]|3[01]|[1-9])`,
+	"YEAR":              `[0-9]{4}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `[0-5][0-9]`,
+	"SECOND":            `(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?`,
+	"TIME":              `%{HOUR}:%{MINUTE}:%{SECOND}`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}:?%{MINUTE})`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-[0-9]{2}-[0-9]{2}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"LOGLEVEL":          `(?i:alert|trace|debug|notice|info|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?)`,
+	"COMMONAPACHELOG":   `%{IPORHOST:clientip} %{USER:ident} %{USER:auth} \[%{HTTPDATE:timestamp}\] "(?:%{WORD:verb} %{NOTSPACE:request}(?: HTTP/%{NUMBER:httpversion})?|%{DATA:rawrequest})" %{NUMBER:response:int} (?:%{NUMBER:bytes:int}|-)`,
+	"COMBINEDAPACHELOG": `%{COMMONAPACHELOG} %{QS:referrer} %{QS:agent}`,
+}
+
+// referenceRe matches %{PATTERN}, %{PATTERN:field} and %{PATTERN:field:type}
+var referenceRe = regexp.MustCompile(`%\{(\w+)(?::([\w.@-]+))?(?::(int|float|string))?\}`)
+
+// Grok expands and compiles grok expressions against a pattern library
+type Grok struct {
+	patterns map[string]string
+}
+
+// New creates a compiler with the built-in patterns and the given custom patterns
+// Custom patterns override built-in patterns of the same name
+func New(custom map[string]string) (*Grok, error) {
+	patterns := make(map[string]string, len(BuiltinPatterns)+len(custom))
+	for name, pattern := range BuiltinPatterns {
+		patterns[name] = pattern
+	}
+	for name, pattern := range custom {
+		patterns[name] = pattern
+	}
+
+	g := &Grok{patterns: patterns}
+	for name := range custom {
+		if _, err := g.Compile("%{" + name + "}"); err != nil {
+			return nil, fmt.Errorf("pattern %s: %w", name, err)
+		}
+	}
+	return g, nil
+}
+
+// capture is a named field of a compiled expression
+type capture struct {
+	field string
+	kind  string
+}
+
+// Pattern is a compiled grok expression
+type Pattern struct {
+	expr     string
+	re       *regexp.Regexp
+	captures []capture
+}
+
+// Compile expands the pattern references of expr into a regular expression
+func (g *Grok) Compile(expr string) (*Pattern, error) {
+	p := &Pattern{expr: expr}
+	expanded, err := g.expand(expr, p, 0)
+	if err != nil {
+		return nil, err
+	}
+	re, err := regexp.Compile(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", expr, err)
+	}
+	p.re = re
+	return p, nil
+}
+
+func (g *Grok) expand(expr string, p *Pattern, depth int) (string, error) {
+	if depth > maxExpandDepth {
+		return "", fmt.Errorf("pattern references nested too deeply (cycle?) in %q", expr)
+	}
+
+	var expandErr error
+	expanded := referenceRe.ReplaceAllStringFunc(expr, func(ref string) string {
+		if expandErr != nil {
+			return ""
+		}
+		m := referenceRe.FindStringSubmatch(ref)
+		pattern, ok := g.patterns[m[1]]
+		if !ok {
+			expandErr = fmt.Errorf("unknown pattern %s", m[1])
+			return ""
+		}
+
+		// Captures are numbered in the order their group opens, so register before expanding
+		index := -1
+		if m[2] != "" {
+			index = len(p.captures)
+			p.captures = append(p.captures, capture{field: m[2], kind: m[3]})
+		}
+
+		inner, err := g.expand(pattern, p, depth+1)
+		if err != nil {
+			expandErr = err
+			return ""
+		}
+		if index < 0 {
+			return "(?:" + inner + ")"
+		}
+		return fmt.Sprintf("(?P<_grok%d>%s)", index, inner)
+	})
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return expanded, nil
+}
+
+// Match applies the pattern to s and returns its named captures
+// Captures typed int or float are converted; those that do not convert stay strings
+func (p *Pattern) Match(s string) (map[string]interface{}, bool) {
+	m := p.re.FindStringSubmatchIndex(s)
+	if m == nil {
+		return nil, false
+	}
+
+	fields := make(map[string]interface{})
+	for i, name := range p.re.SubexpNames() {
+		if !strings.HasPrefix(name, "_grok") || m[2*i] < 0 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimPrefix(name, "_grok"))
+		if err != nil || index >= len(p.captures) {
+			continue
+		}
+		c := p.captures[index]
+		fields[c.field] = convert(s[m[2*i]:m[2*i+1]], c.kind)
+	}
+	return fields, true
+}
+
+// String returns the grok expression
+func (p *Pattern) String() string {
+	return p.expr
+}
+
+func convert(value, kind string) interface{} {
+	switch kind {
+	case "int":
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return n
+		}
+	case "float":
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return value
+}
@@ -0,0 +1,182 @@
+package grok
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"syslog-visualizer/internal/parser"
+)
+
+func TestCompileAndMatch(t *testing.T) {
+	g, err := New(map[string]string{"DURATION": `%{NUMBER:duration_ms:float}ms`})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		expr   string
+		input  string
+		fields map[string]interface{}
+	}{
+		{
+			name:  "nginx access log",
+			expr:  "%{COMBINEDAPACHELOG}",
+			input: `10.0.0.1 - bob [11/Oct/2024:22:14:15 +0000] "GET /api?x=1 HTTP/1.1" 503 1234 "-" "curl/8.0"`,
+			fields: map[string]interface{}{
+				"clientip": "10.0.0.1", "ident": "-", "auth": "bob", "timestamp": "11/Oct/2024:22:14:15 +0000",
+				"verb": "GET", "request": "/api?x=1", "httpversion": "1.1", "response": int64(503),
+				"bytes": int64(1234), "referrer": `"-"`, "agent": `"curl/8.0"`,
+			},
+		},
+		{
+			name:   "custom pattern and dotted field",
+			expr:   `user %{USERNAME:user.name} took %{DURATION}`,
+			input:  "user alice took 12.5ms",
+			fields: map[string]interface{}{"user.name": "alice", "duration_ms": 12.5},
+		},
+		{
+			name:   "unconvertible int stays a string",
+			expr:   `code=%{NOTSPACE:code:int}`,
+			input:  "code=E42",
+			fields: map[string]interface{}{"code": "E42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := g.Compile(tt.expr)
+			if err != nil {
+				t.Fatalf("Compile(%q): %v", tt.expr, err)
+			}
+			fields, ok := p.Match(tt.input)
+			if !ok {
+				t.Fatalf("Match(%q) did not match", tt.input)
+			}
+			if !reflect.DeepEqual(fields, tt.fields) {
+				t.Errorf("Match(%q) = %v, want %v", tt.input, fields, tt.fields)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	if _, err := New(map[string]string{"A": "%{B}", "B": "%{A}"}); err == nil {
+		t.Error("New with a pattern cycle succeeded, want error")
+	}
+
+	g, err := New(nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, expr := range []string{"%{NOPE:x}", "%{WORD:x} ("} {
+		if _, err := g.Compile(expr); err == nil {
+			t.Errorf("Compile(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestRules(t *testing.T) {
+	rules, err := NewRules(Config{
+		Rules: []Rule{
+			{Name: "nginx", Tag: "nginx", Patterns: []string{"%{COMMONAPACHELOG}"}},
+			{Name: "login", Patterns: []string{`Accepted %{WORD:method} for %{USERNAME:user} from %{IP:src_ip}`}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRules: %v", err)
+	}
+
+	msg := &parser.SyslogMessage{Tag: "sshd", Message: "Accepted publickey for root from 10.0.0.5 port 22"}
+	if name, ok := rules.Apply(msg); !ok || name != "login" {
+		t.Errorf("Apply() = %q, %v, want login", name, ok)
+	}
+	if msg.Fields["user"] != "root" || msg.Fields["src_ip"] != "10.0.0.5" {
+		t.Errorf("Fields = %v, want user and src_ip", msg.Fields)
+	}
+
+	// The nginx rule only applies to the nginx tag
+	msg = &parser.SyslogMessage{Tag: "apache", Message: `10.0.0.1 - - [11/Oct/2024:22:14:15 +0000] "GET / HTTP/1.1" 200 5`}
+	if name, ok := rules.Apply(msg); ok {
+		t.Errorf("Apply() = %q, want no match", name)
+	}
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := rules.SetFile(path); err != nil {
+		t.Fatalf("SetFile: %v", err)
+	}
+	if err := rules.Put(Rule{Name: "login", Patterns: []string{"%{GREEDYDATA}"}}); err == nil {
+		t.Error("Put replacing a config rule succeeded, want error")
+	}
+	if err := rules.Put(Rule{Name: "apache", Tag: "apache", Patterns: []string{"%{COMMONAPACHELOG}"}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if name, ok := rules.Apply(msg); !ok || name != "apache" || msg.Fields["response"] != int64(200) {
+		t.Errorf("Apply() = %q, %v with fields %v, want apache", name, ok, msg.Fields)
+	}
+
+	// API rules are reloaded from the file
+	reloaded, err := NewRules(Config{})
+	if err != nil {
+		t.Fatalf("NewRules: %v", err)
+	}
+	if err := reloaded.SetFile(path); err != nil {
+		t.Fatalf("SetFile: %v", err)
+	}
+	if list := reloaded.List(); len(list) != 1 || list[0].Name != "apache" || list[0].Source != SourceAPI {
+		t.Errorf("List() = %+v, want the apache API rule", list)
+	}
+
+	if err := reloaded.Delete("apache"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := reloaded.Delete("apache"); err == nil {
+		t.Error("Delete of a missing rule succeeded, want error")
+	}
+}
+
+func TestRulesReload(t *testing.T) {
+	rules, err := NewRules(Config{
+		Patterns: map[string]string{"CODE": `E%{INT}`},
+		Rules:    []Rule{{Name: "old", Patterns: []string{`error %{CODE:code}`}}},
+	})
+	if err != nil {
+		t.Fatalf("NewRules: %v", err)
+	}
+	if err := rules.Put(Rule{Name: "api", Patterns: []string{`fault %{CODE:code}`}}); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// A new rule set that would break the API rule is refused as a whole
+	if err := rules.Reload(Config{Rules: []Rule{{Name: "new", Patterns: []string{`%{GREEDYDATA}`}}}}); err == nil {
+		t.Error("Reload() without the CODE pattern succeeded, want error")
+	}
+	if err := rules.Reload(Config{
+		Patterns: map[string]string{"CODE": `E%{INT}`},
+		Rules:    []Rule{{Name: "api", Patterns: []string{`%{GREEDYDATA}`}}},
+	}); err == nil {
+		t.Error("Reload() with a rule named like an API rule succeeded, want error")
+	}
+	if list := rules.List(); len(list) != 2 || list[0].Name != "old" {
+		t.Errorf("List() after refused reloads = %+v, want old and api", list)
+	}
+
+	err = rules.Reload(Config{
+		Patterns: map[string]string{"CODE": `[A-Z]%{INT}`},
+		Rules:    []Rule{{Name: "new", Tag: "app", Patterns: []string{`error %{CODE:code}`}}},
+	})
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	list := rules.List()
+	if len(list) != 2 || list[0].Name != "new" || list[1].Name != "api" {
+		t.Errorf("List() = %+v, want new and api", list)
+	}
+
+	// The API rule uses the reloaded pattern
+	msg := &parser.SyslogMessage{Message: "fault W42"}
+	if name, ok := rules.Apply(msg); !ok || name != "api" || msg.Fields["code"] != "W42" {
+		t.Errorf("Apply() = %q, %v with fields %v, want api with code W42", name, ok, msg.Fields)
+	}
+}
@@ -0,0 +1,314 @@
+package grok
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"syslog-visualizer/internal/parser"
+)
+
+// Rule sources
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+// Config holds the custom patterns and rules of the configuration file
+type Config struct {
+	Patterns map[string]string `yaml:"patterns"`
+	Rules    []Rule            `yaml:"rules"`
+}
+
+// Rule applies grok patterns to the messages matching its conditions
+// Patterns are tried in order and the first match sets its named captures as fields
+type Rule struct {
+	Name     string   `yaml:"name" json:"name"`
+	Tag      string   `yaml:"tag" json:"tag,omitempty"`           // Only messages with this tag (empty for any)
+	Hostname string   `yaml:"hostname" json:"hostname,omitempty"` // Only messages from this hostname (empty for any)
+	Patterns []string `yaml:"patterns" json:"patterns"`
+	Source   string   `yaml:"-" json:"source,omitempty"`
+}
+
+// compiledRule is a rule with its compiled patterns
+type compiledRule struct {
+	Rule
+	patterns []*Pattern
+}
+
+// Rules holds the rules of the configuration file and those managed through the API
+// Configuration rules are tried first, then API rules, in order; the first match wins
+type Rules struct {
+	mu     sync.RWMutex
+	grok   *Grok
+	config []*compiledRule
+	api    []*compiledRule
+	file   string
+}
+
+// NewRules compiles the patterns and rules of the configuration
+func NewRules(cfg Config) (*Rules, error) {
+	g, config, err := compileConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Rules{grok: g, config: config}, nil
+}
+
+// compileConfig compiles the pattern library and the rules of the configuration
+func compileConfig(cfg Config) (*Grok, []*compiledRule, error) {
+	g, err := New(cfg.Patterns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var config []*compiledRule
+	names := make(map[string]bool)
+	for _, rule := range cfg.Rules {
+		rule.Source = SourceConfig
+		compiled, err := compileRule(g, rule)
+		if err != nil {
+			return nil, nil, err
+		}
+		if names[rule.Name] {
+			return nil, nil, fmt.Errorf("duplicate grok rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+		config = append(config, compiled)
+	}
+	return g, config, nil
+}
+
+// Reload replaces the patterns and rules of the configuration, keeping the API rules,
+// which are compiled again against the new patterns
+// Nothing changes when a rule fails to compile or an API rule has the name of a new
+// configuration rule
+func (r *Rules) Reload(cfg Config) error {
+	g, config, err := compileConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make(map[string]bool, len(config))
+	for _, rule := range config {
+		names[rule.Name] = true
+	}
+	api := make([]*compiledRule, 0, len(r.api))
+	for _, existing := range r.api {
+		if names[existing.Name] {
+			return fmt.Errorf("grok rule %s is also defined through the API", existing.Name)
+		}
+		compiled, err := compileRule(g, existing.Rule)
+		if err != nil {
+			return fmt.Errorf("API rule no longer compiles: %w", err)
+		}
+		api = append(api, compiled)
+	}
+
+	r.grok, r.config, r.api = g, config, api
+	return nil
+}
+
+// Compile checks a rule against the pattern library without adding it
+func (r *Rules) Compile(rule Rule) error {
+	_, err := r.compile(rule)
+	return err
+}
+
+// compile compiles a rule against the current pattern library
+func (r *Rules) compile(rule Rule) (*compiledRule, error) {
+	r.mu.RLock()
+	g := r.grok
+	r.mu.RUnlock()
+	return compileRule(g, rule)
+}
+
+func compileRule(g *Grok, rule Rule) (*compiledRule, error) {
+	if rule.Name == "" {
+		return nil, fmt.Errorf("grok rule name is required")
+	}
+	if len(rule.Patterns) == 0 {
+		return nil, fmt.Errorf("grok rule %s: at least one pattern is required", rule.Name)
+	}
+
+	compiled := &compiledRule{Rule: rule}
+	for _, expr := range rule.Patterns {
+		p, err := g.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("grok rule %s: %w", rule.Name, err)
+		}
+		compiled.patterns = append(compiled.patterns, p)
+	}
+	return compiled, nil
+}
+
+// Apply runs the rules on the message and returns the name of the rule that matched
+// Captured fields replace existing fields of the same name
+func (r *Rules) Apply(msg *parser.SyslogMessage) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rules := range [][]*compiledRule{r.config, r.api} {
+		for _, rule := range rules {
+			if rule.apply(msg) {
+				return rule.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Test runs a single rule, which is not added, on the message
+func (r *Rules) Test(rule Rule, msg *parser.SyslogMessage) (bool, error) {
+	compiled, err := r.compile(rule)
+	if err != nil {
+		return false, err
+	}
+	return compiled.apply(msg), nil
+}
+
+func (rule *compiledRule) apply(msg *parser.SyslogMessage) bool {
+	if rule.Tag != "" && rule.Tag != msg.Tag {
+		return false
+	}
+	if rule.Hostname != "" && rule.Hostname != msg.Hostname {
+		return false
+	}
+
+	for _, p := range rule.patterns {
+		fields, ok := p.Match(msg.Message)
+		if !ok {
+			continue
+		}
+		for key, value := range fields {
+			msg.SetField(key, value)
+		}
+		return true
+	}
+	return false
+}
+
+// List returns every rule in the order they are tried
+func (r *Rules) List() []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rules := make([]Rule, 0, len(r.config)+len(r.api))
+	for _, compiled := range r.config {
+		rules = append(rules, compiled.Rule)
+	}
+	for _, compiled := range r.api {
+		rules = append(rules, compiled.Rule)
+	}
+	return rules
+}
+
+// Put adds an API rule, or replaces the API rule with the same name
+// Configuration rules cannot be replaced through the API
+func (r *Rules) Put(rule Rule) error {
+	rule.Source = SourceAPI
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	compiled, err := compileRule(r.grok, rule)
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range r.config {
+		if existing.Name == rule.Name {
+			return fmt.Errorf("grok rule %s is defined in the configuration file", rule.Name)
+		}
+	}
+
+	replaced := false
+	for i, existing := range r.api {
+		if existing.Name == rule.Name {
+			r.api[i] = compiled
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		r.api = append(r.api, compiled)
+	}
+	return r.saveLocked()
+}
+
+// Delete removes an API rule
+func (r *Rules) Delete(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, existing := range r.api {
+		if existing.Name == name {
+			r.api = append(r.api[:i], r.api[i+1:]...)
+			return r.saveLocked()
+		}
+	}
+	return fmt.Errorf("grok rule not found: %s", name)
+}
+
+// SetFile enables persistence of API rules to a JSON file and loads existing rules from it
+func (r *Rules) SetFile(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.file = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read grok rules file: %w", err)
+	}
+
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return fmt.Errorf("failed to parse grok rules file: %w", err)
+	}
+
+	r.api = nil
+	for _, rule := range rules {
+		rule.Source = SourceAPI
+		compiled, err := compileRule(r.grok, rule)
+		if err != nil {
+			return fmt.Errorf("failed to load grok rules file: %w", err)
+		}
+		r.api = append(r.api, compiled)
+	}
+	return nil
+}
+
+// saveLocked writes the API rules to the rules file (caller must hold the lock)
+func (r *Rules) saveLocked() error {
+	if r.file == "" {
+		return nil
+	}
+
+	rules := make([]Rule, len(r.api))
+	for i, compiled := range r.api {
+		rules[i] = compiled.Rule
+		rules[i].Source = ""
+	}
+
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode grok rules: %w", err)
+	}
+
+	tmpPath := r.file + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write grok rules file: %w", err)
+	}
+	if err := os.Rename(tmpPath, r.file); err != nil {
+		return fmt.Errorf("failed to replace grok rules file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package ingest
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"syslog-visualizer/internal/collector"
+	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/pkg/syslog"
+)
+
+const (
+	// MaxBodySize is the maximum accepted (decompressed) request body size
+	MaxBodySize = 10 << 20 // 10 MB
+	// MaxLineSize is the maximum size of a single line
+	MaxLineSize = 64 << 10 // 64 KB
+
+	// ListenerName is the listener name of the messages received over HTTP
+	ListenerName = "http"
+)
+
+// LineResult reports the outcome for a single input line
+type LineResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"` // "accepted" or "rejected"
+	Error  string `json:"error,omitempty"`
+}
+
+// Response is the body returned by the ingestion endpoint
+type Response struct {
+	Accepted int          `json:"accepted"`
+	Rejected int          `json:"rejected"`
+	Results  []LineResult `json:"results"`
+}
+
+// jsonEvent is the NDJSON representation of a message
+// Both snake_case and the camelCase names used by the API are accepted
+type jsonEvent struct {
+	Timestamp json.RawMessage `json:"timestamp"`
+	Hostname  string          `json:"hostname"`
+	Host      string          `json:"host"`
+	Facility  json.RawMessage `json:"facility"`
+	Severity  json.RawMessage `json:"severity"`
+	Tag       string          `json:"tag"`
+	Message   string          `json:"message"`
+	Msg       string          `json:"msg"`
+	PID       string          `json:"pid"`
+	AppName   string          `json:"appName"`
+	AppName2  string          `json:"app_name"`
+	ProcID    string          `json:"procID"`
+	ProcID2   string          `json:"proc_id"`
+	MsgID     string          `json:"msgID"`
+	MsgID2    string          `json:"msg_id"`
+}
+
+// NewHTTPHandler returns a handler for POST /api/ingest
+// The body holds one message per line: raw syslog lines are parsed with opts,
+// lines starting with '{' are decoded as JSON events. Gzip bodies are accepted
+// with "Content-Encoding: gzip". Every message is passed to the collector's handler.
+func NewHTTPHandler(handler collector.MessageHandler, opts parser.Options) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body io.Reader = http.MaxBytesReader(w, r.Body, MaxBodySize)
+		if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+			gz, err := gzip.NewReader(body)
+			if err != nil {
+				http.Error(w, "Invalid gzip body", http.StatusBadRequest)
+				return
+			}
+			defer gz.Close()
+			body = io.LimitReader(gz, MaxBodySize)
+		}
+
+		forceJSON := isJSONContentType(r.Header.Get("Content-Type"))
+		sourceHost := remoteHost(r.RemoteAddr)
+
+		response := Response{Results: make([]LineResult, 0)}
+
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 4096), MaxLineSize)
+
+		lineNum := 0
+		for scanner.Scan() {
+			lineNum++
+			line := strings.TrimRight(scanner.Text(), "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+
+			msg, err := decodeLine(line, forceJSON, sourceHost, opts)
+			if err == nil && handler != nil {
+				msg.Listener = ListenerName
+				msg.SourceHost = sourceHost
+				msg.Acknowledged = true
+				err = handler(msg)
+			}
+
+			if err != nil {
+				response.Rejected++
+				response.Results = append(response.Results, LineResult{
+					Line:   lineNum,
+					Status: "rejected",
+					Error:  err.Error(),
+				})
+				continue
+			}
+
+			response.Accepted++
+			response.Results = append(response.Results, LineResult{
+				Line:   lineNum,
+				Status: "accepted",
+			})
+		}
+
+		if err := scanner.Err(); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to read body after line %d: %v", lineNum, err), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if response.Accepted == 0 && response.Rejected > 0 {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+		}
+		json.NewEncoder(w).Encode(response)
+	}
+}
+
+// decodeLine turns a single input line into a message
+func decodeLine(line string, forceJSON bool, sourceHost string, opts parser.Options) (*parser.SyslogMessage, error) {
+	if forceJSON || strings.HasPrefix(strings.TrimSpace(line), "{") {
+		return decodeJSON(line, sourceHost)
+	}
+	opts.SourceHost = sourceHost
+	opts.ReceivedAt = time.Now()
+	return parser.ParseWithOptions(line, opts)
+}
+
+// decodeJSON maps a JSON event onto a SyslogMessage
+func decodeJSON(line string, sourceHost string) (*parser.SyslogMessage, error) {
+	var event jsonEvent
+	if err := json.Unmarshal([]byte(line), &event); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+
+	msg := &parser.SyslogMessage{
+		Hostname:   firstNonEmpty(event.Hostname, event.Host, sourceHost),
+		Facility:   syslog.FacilityUser,
+		Severity:   syslog.SeverityInfo,
+		Message:    firstNonEmpty(event.Message, event.Msg),
+		PID:        event.PID,
+		AppName:    firstNonEmpty(event.AppName, event.AppName2),
+		ProcID:     firstNonEmpty(event.ProcID, event.ProcID2),
+		MsgID:      firstNonEmpty(event.MsgID, event.MsgID2),
+		Raw:        line,
+		ReceivedAt: time.Now(),
+	}
+	msg.Tag = firstNonEmpty(event.Tag, msg.AppName)
+	if msg.PID == "" {
+		msg.PID = msg.ProcID
+	}
+
+	if msg.Message == "" {
+		return nil, fmt.Errorf("missing message field")
+	}
+
+	if len(event.Facility) > 0 {
+		facility, ok := syslog.ParseFacility(unquote(event.Facility))
+		if !ok {
+			return nil, fmt.Errorf("invalid facility: %s", event.Facility)
+		}
+		msg.Facility = facility
+	}
+
+	if len(event.Severity) > 0 {
+		severity, ok := syslog.ParseSeverity(unquote(event.Severity))
+		if !ok {
+			return nil, fmt.Errorf("invalid severity: %s", event.Severity)
+		}
+		msg.Severity = severity
+	}
+
+	msg.Timestamp = time.Now().UTC()
+	if len(event.Timestamp) > 0 && string(event.Timestamp) != "null" {
+		timestamp, err := parseTimestamp(unquote(event.Timestamp))
+		if err != nil {
+			return nil, err
+		}
+		msg.Timestamp = timestamp
+	}
+
+	return msg, nil
+}
+
+// parseTimestamp accepts RFC 3339 strings and Unix epoch seconds (with optional fraction)
+func parseTimestamp(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	if epoch, err := strconv.ParseFloat(s, 64); err == nil {
+		sec := int64(epoch)
+		nsec := int64((epoch - float64(sec)) * 1e9)
+		return time.Unix(sec, nsec).UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp: %s", s)
+}
+
+// unquote returns the string value of a raw JSON string or number
+func unquote(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+func isJSONContentType(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	return strings.Contains(contentType, "ndjson") || strings.Contains(contentType, "application/json")
+}
+
+func remoteHost(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,85 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"syslog-visualizer/internal/parser"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	var received []*parser.SyslogMessage
+	handler := NewHTTPHandler(func(msg *parser.SyslogMessage) error {
+		received = append(received, msg)
+		return nil
+	}, parser.Options{})
+
+	body := strings.Join([]string{
+		"<34>Oct 11 22:14:15 mymachine su[1234]: 'su root' failed",
+		`{"host":"fn-1","severity":"warning","facility":"local0","tag":"api","message":"slow request","timestamp":"2024-10-11T22:14:15Z"}`,
+		"",
+		"not a syslog message",
+		`{"host":"fn-2"}`,
+	}, "\n")
+
+	req := httptest.NewRequest(http.MethodPost, "/api/ingest", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+
+	var response Response
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	if response.Accepted != 2 || response.Rejected != 2 {
+		t.Errorf("accepted/rejected = %d/%d, want 2/2", response.Accepted, response.Rejected)
+	}
+	if len(response.Results) != 4 || response.Results[2].Line != 4 || response.Results[2].Status != "rejected" {
+		t.Errorf("unexpected results: %+v", response.Results)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("handler received %d messages, want 2", len(received))
+	}
+	if received[0].Hostname != "mymachine" || received[0].Tag != "su" {
+		t.Errorf("syslog line parsed as %+v", received[0])
+	}
+	event := received[1]
+	if event.Hostname != "fn-1" || event.Severity != 4 || event.Facility != 16 || event.Tag != "api" {
+		t.Errorf("JSON event mapped as %+v", event)
+	}
+	if event.Timestamp.Year() != 2024 {
+		t.Errorf("Timestamp = %v, want 2024-10-11", event.Timestamp)
+	}
+}
+
+func TestHTTPHandlerGzip(t *testing.T) {
+	count := 0
+	handler := NewHTTPHandler(func(msg *parser.SyslogMessage) error {
+		count++
+		return nil
+	}, parser.Options{})
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte("<13>Feb  5 17:32:18 10.0.0.99 myapp: one\n<13>Feb  5 17:32:19 10.0.0.99 myapp: two\n"))
+	gz.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/ingest", &buf)
+	req.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || count != 2 {
+		t.Errorf("status = %d, messages = %d, want 200 and 2", w.Code, count)
+	}
+}
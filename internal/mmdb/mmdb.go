@@ -0,0 +1,356 @@
+package mmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata at the end of the file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the size of the zero bytes between the search tree and the data
+const dataSectionSeparator = 16
+
+// maxDepth bounds the nesting of decoded values, against corrupt files
+const maxDepth = 32
+
+// Data types
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// Metadata describes a database
+type Metadata struct {
+	DatabaseType string
+	IPVersion    int
+	RecordSize   int
+	NodeCount    int
+	BuildEpoch   uint64
+}
+
+// Reader looks up addresses in a MaxMind DB file (the format of GeoIP2 and GeoLite2
+// databases) loaded in memory
+type Reader struct {
+	Metadata Metadata
+
+	tree      []byte
+	data      []byte
+	ipv4Start int // Node of ::/96, where IPv4 lookups start in an IPv6 tree
+}
+
+// Open reads a database file
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database: %w", err)
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes reads a database held in memory
+func FromBytes(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, errors.New("invalid database: metadata not found")
+	}
+	meta := decoder{buf: buf[i+len(metadataMarker):]}
+	value, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid database metadata: %w", err)
+	}
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid database metadata: not a map")
+	}
+
+	r := &Reader{Metadata: Metadata{
+		DatabaseType: stringValue(fields["database_type"]),
+		IPVersion:    int(uintValue(fields["ip_version"])),
+		RecordSize:   int(uintValue(fields["record_size"])),
+		NodeCount:    int(uintValue(fields["node_count"])),
+		BuildEpoch:   uintValue(fields["build_epoch"]),
+	}}
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size: %d", r.Metadata.RecordSize)
+	}
+	if r.Metadata.IPVersion != 4 && r.Metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version: %d", r.Metadata.IPVersion)
+	}
+
+	treeSize := r.Metadata.NodeCount * r.Metadata.RecordSize / 4
+	if treeSize+dataSectionSeparator > i {
+		return nil, errors.New("invalid database: search tree larger than the file")
+	}
+	r.tree = buf[:treeSize]
+	r.data = buf[treeSize+dataSectionSeparator : i]
+
+	if r.Metadata.IPVersion == 6 {
+		for bit := 0; bit < 96 && r.ipv4Start < r.Metadata.NodeCount; bit++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+// Lookup returns the record of the network holding the address
+func (r *Reader) Lookup(ip net.IP) (interface{}, bool, error) {
+	node, bits := 0, ip.To4()
+	if bits != nil {
+		node = r.ipv4Start
+	} else if r.Metadata.IPVersion == 4 {
+		return nil, false, nil
+	} else {
+		bits = ip.To16()
+		if bits == nil {
+			return nil, false, fmt.Errorf("invalid address: %v", ip)
+		}
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	for i := 0; i < len(bits)*8 && node < nodeCount; i++ {
+		bit := int(bits[i/8]>>(7-uint(i%8))) & 1
+		node = r.record(node, bit)
+	}
+
+	switch {
+	case node == nodeCount:
+		return nil, false, nil
+	case node < nodeCount:
+		return nil, false, errors.New("invalid database: search tree deeper than the address")
+	}
+
+	offset := node - nodeCount - dataSectionSeparator
+	if offset < 0 || offset >= len(r.data) {
+		return nil, false, fmt.Errorf("invalid database: data offset %d out of range", offset)
+	}
+	d := decoder{buf: r.data}
+	value, _, err := d.decode(offset, 0)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid database record: %w", err)
+	}
+	return value, true, nil
+}
+
+// record returns the left (0) or right (1) record of a node
+func (r *Reader) record(node, bit int) int {
+	switch r.Metadata.RecordSize {
+	case 24:
+		b := r.tree[node*6+bit*3:]
+		return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	case 28:
+		b := r.tree[node*7:]
+		if bit == 0 {
+			return int(b[3]&0xf0)<<20 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+		}
+		return int(b[3]&0x0f)<<24 | int(b[4])<<16 | int(b[5])<<8 | int(b[6])
+	default:
+		return int(binary.BigEndian.Uint32(r.tree[node*8+bit*4:]))
+	}
+}
+
+// decoder decodes the values of the data or metadata section
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at an offset and the offset that follows it
+func (d *decoder) decode(offset, depth int) (interface{}, int, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("values nested too deeply")
+	}
+	kind, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if kind == typePointer {
+		pointer, next, err := d.pointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer, depth+1)
+		return value, next, err
+	}
+
+	switch kind {
+	case typeMap:
+		m := make(map[string]interface{}, min(size, 64))
+		for i := 0; i < size; i++ {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			value, after, err := d.decode(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+			offset = after
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, min(size, 64))
+		for i := 0; i < size; i++ {
+			value, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > len(d.buf) {
+		return nil, 0, fmt.Errorf("value at %d runs past the end of the section", offset)
+	}
+	b := d.buf[offset:end]
+	switch kind {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return append([]byte(nil), b...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size: %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size: %d", size)
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid integer size: %d", size)
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, end, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid integer size: %d", size)
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), end, nil
+	case typeUint128:
+		// Too large for the caller to use as a number; kept as big-endian bytes
+		return append([]byte(nil), b...), end, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", kind)
+	}
+}
+
+// control reads a control byte and its extensions: the type, the size and the offset of
+// the payload
+func (d *decoder) control(offset int) (int, int, int, error) {
+	if offset >= len(d.buf) {
+		return 0, 0, 0, fmt.Errorf("offset %d past the end of the section", offset)
+	}
+	ctrl := d.buf[offset]
+	offset++
+	kind := int(ctrl >> 5)
+
+	if kind == typePointer {
+		return kind, int(ctrl), offset, nil
+	}
+	if kind == typeExtended {
+		if offset >= len(d.buf) {
+			return 0, 0, 0, errors.New("truncated extended type")
+		}
+		kind = 7 + int(d.buf[offset])
+		offset++
+		if kind <= typeMap || kind == typeContainer || kind == typeEndMarker {
+			return 0, 0, 0, fmt.Errorf("invalid extended type %d", kind)
+		}
+	}
+
+	size := int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > len(d.buf) {
+			return 0, 0, 0, errors.New("truncated size")
+		}
+		extra := 0
+		for _, c := range d.buf[offset : offset+n] {
+			extra = extra<<8 | int(c)
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + extra
+		case 2:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+	return kind, size, offset, nil
+}
+
+// pointer resolves a pointer from its control byte; it returns the target and the
+// offset that follows the pointer
+func (d *decoder) pointer(ctrl, offset int) (int, int, error) {
+	n := (ctrl>>3)&0x3 + 1
+	if offset+n > len(d.buf) {
+		return 0, 0, errors.New("truncated pointer")
+	}
+	b := d.buf[offset : offset+n]
+
+	var pointer int
+	switch n {
+	case 1:
+		pointer = (ctrl&0x7)<<8 | int(b[0])
+	case 2:
+		pointer = ((ctrl&0x7)<<16 | int(b[0])<<8 | int(b[1])) + 2048
+	case 3:
+		pointer = ((ctrl&0x7)<<24 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])) + 526336
+	default:
+		pointer = int(binary.BigEndian.Uint32(b))
+	}
+	return pointer, offset + n, nil
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+func uintValue(v interface{}) uint64 {
+	n, _ := v.(uint64)
+	return n
+}
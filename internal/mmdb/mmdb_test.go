@@ -0,0 +1,232 @@
+package mmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testWriter builds small databases: the data section, then a search tree of networks
+type testWriter struct {
+	data  bytes.Buffer
+	nodes [][2]int // Records: a node index, -1 when empty, or -2-offset for data
+}
+
+func (w *testWriter) control(kind, size int) {
+	var extra []byte
+	switch {
+	case size >= 65821:
+		size -= 65821
+		extra = []byte{byte(size >> 16), byte(size >> 8), byte(size)}
+		size = 31
+	case size >= 285:
+		size -= 285
+		extra = []byte{byte(size >> 8), byte(size)}
+		size = 30
+	case size >= 29:
+		extra = []byte{byte(size - 29)}
+		size = 29
+	}
+	if kind > typeMap {
+		w.data.WriteByte(byte(size))
+		w.data.WriteByte(byte(kind - 7))
+	} else {
+		w.data.WriteByte(byte(kind<<5 | size))
+	}
+	w.data.Write(extra)
+}
+
+// write encodes a value and returns its offset
+func (w *testWriter) write(value interface{}) int {
+	offset := w.data.Len()
+	switch v := value.(type) {
+	case string:
+		w.control(typeString, len(v))
+		w.data.WriteString(v)
+	case float64:
+		w.control(typeDouble, 8)
+		binary.Write(&w.data, binary.BigEndian, math.Float64bits(v))
+	case uint32:
+		w.control(typeUint32, 4)
+		binary.Write(&w.data, binary.BigEndian, v)
+	case int32:
+		w.control(typeInt32, 4)
+		binary.Write(&w.data, binary.BigEndian, v)
+	case bool:
+		size := 0
+		if v {
+			size = 1
+		}
+		w.control(typeBool, size)
+	case pointer:
+		w.data.WriteByte(byte(typePointer<<5 | int(v)>>8&0x7))
+		w.data.WriteByte(byte(v))
+	case []interface{}:
+		w.control(typeArray, len(v))
+		for _, item := range v {
+			w.write(item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		w.control(typeMap, len(v))
+		for _, key := range keys {
+			w.write(key)
+			w.write(v[key])
+		}
+	}
+	return offset
+}
+
+// pointer is written as a pointer to a data offset (below 2048)
+type pointer int
+
+// insert maps a network, which must not overlap those already inserted, to the record
+// at a data offset
+func (w *testWriter) insert(network string, offset int) {
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		panic(err)
+	}
+	// IPv4 networks live under ::/96 of the IPv6 tree
+	ip := ipNet.IP.To16()
+	ones, _ := ipNet.Mask.Size()
+	if v4 := ipNet.IP.To4(); v4 != nil {
+		ip = append(make(net.IP, 12), v4...)
+		ones += 96
+	}
+
+	if len(w.nodes) == 0 {
+		w.nodes = append(w.nodes, [2]int{-1, -1})
+	}
+	node := 0
+	for i := 0; i < ones; i++ {
+		bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+		if i == ones-1 {
+			w.nodes[node][bit] = -2 - offset
+			return
+		}
+		if w.nodes[node][bit] < 0 {
+			w.nodes = append(w.nodes, [2]int{-1, -1})
+			w.nodes[node][bit] = len(w.nodes) - 1
+		}
+		node = w.nodes[node][bit]
+	}
+}
+
+// bytes returns the database file
+func (w *testWriter) bytes(recordSize int) []byte {
+	nodeCount := len(w.nodes)
+	value := func(record int) int {
+		switch {
+		case record == -1:
+			return nodeCount
+		case record < -1:
+			return nodeCount + dataSectionSeparator + (-2 - record)
+		default:
+			return record
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, node := range w.nodes {
+		left, right := value(node[0]), value(node[1])
+		switch recordSize {
+		case 24:
+			buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			buf.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(left>>20&0xf0 | right>>24&0x0f), byte(right >> 16), byte(right >> 8), byte(right)})
+		default:
+			binary.Write(&buf, binary.BigEndian, uint32(left))
+			binary.Write(&buf, binary.BigEndian, uint32(right))
+		}
+	}
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(w.data.Bytes())
+	buf.Write(metadataMarker)
+
+	meta := &testWriter{}
+	meta.write(map[string]interface{}{
+		"database_type": "Test-City",
+		"ip_version":    uint32(6),
+		"record_size":   uint32(recordSize),
+		"node_count":    uint32(nodeCount),
+		"build_epoch":   uint32(1700000000),
+	})
+	buf.Write(meta.data.Bytes())
+	return buf.Bytes()
+}
+
+func TestLookup(t *testing.T) {
+	w := &testWriter{}
+	name := w.write("Paris")
+	city := w.write(map[string]interface{}{
+		"city":     map[string]interface{}{"names": map[string]interface{}{"en": pointer(name)}},
+		"country":  map[string]interface{}{"iso_code": "FR"},
+		"location": map[string]interface{}{"latitude": 48.8566, "longitude": 2.3522},
+		"flags":    []interface{}{true, int32(-5)},
+	})
+	long := w.write(map[string]interface{}{"note": strings.Repeat("x", 300)})
+	w.insert("82.0.0.0/8", city)
+	w.insert("81.2.69.0/24", long)
+	w.insert("2001:db8::/32", city)
+
+	wantCity := map[string]interface{}{
+		"city":     map[string]interface{}{"names": map[string]interface{}{"en": "Paris"}},
+		"country":  map[string]interface{}{"iso_code": "FR"},
+		"location": map[string]interface{}{"latitude": 48.8566, "longitude": 2.3522},
+		"flags":    []interface{}{true, int64(-5)},
+	}
+	tests := []struct {
+		ip    string
+		want  interface{}
+		found bool
+	}{
+		{"82.1.2.3", wantCity, true},
+		{"81.2.69.160", map[string]interface{}{"note": strings.Repeat("x", 300)}, true},
+		{"81.2.70.1", nil, false},
+		{"2001:db8::1", wantCity, true},
+		{"2001:db9::1", nil, false},
+	}
+
+	for _, recordSize := range []int{24, 28, 32} {
+		r, err := FromBytes(w.bytes(recordSize))
+		if err != nil {
+			t.Fatalf("FromBytes(record size %d) error = %v", recordSize, err)
+		}
+		if r.Metadata.DatabaseType != "Test-City" || r.Metadata.RecordSize != recordSize || r.Metadata.BuildEpoch != 1700000000 {
+			t.Errorf("Metadata = %+v", r.Metadata)
+		}
+
+		for _, tt := range tests {
+			got, found, err := r.Lookup(net.ParseIP(tt.ip))
+			if err != nil {
+				t.Fatalf("Lookup(%s) error = %v", tt.ip, err)
+			}
+			if found != tt.found || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("record size %d: Lookup(%s) = %v, %v, want %v, %v", recordSize, tt.ip, got, found, tt.want, tt.found)
+			}
+		}
+	}
+}
+
+func TestInvalidDatabase(t *testing.T) {
+	if _, err := FromBytes([]byte("not a database")); err == nil {
+		t.Errorf("FromBytes() error = nil, want an error")
+	}
+
+	w := &testWriter{}
+	w.insert("10.0.0.0/8", w.write("x"))
+	buf := w.bytes(24)
+	if _, err := FromBytes(buf[len(buf)-40:]); err == nil {
+		t.Errorf("FromBytes(truncated) error = nil, want an error")
+	}
+}
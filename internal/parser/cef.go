@@ -0,0 +1,289 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"syslog-visualizer/pkg/syslog"
+)
+
+// cefStartRe matches the start of a CEF payload
+var cefStartRe = regexp.MustCompile(`CEF:\d+\|`)
+
+// leefStartRe matches the start of a LEEF payload
+var leefStartRe = regexp.MustCompile(`LEEF:[12]\.0\|`)
+
+// cefKeyRe matches an extension key followed by an unescaped "="
+var cefKeyRe = regexp.MustCompile(`(?:^|\s)([A-Za-z0-9_.\[\]-]+)=`)
+
+// cefHeaderFields are the fields set from the CEF header, and the format set by the registry
+var cefHeaderFields = map[string]bool{
+	"cef_version": true, "device_vendor": true, "device_product": true, "device_version": true,
+	"signature_id": true, "name": true, "cef_severity": true, "format": true,
+}
+
+// leefHeaderFields are the fields set from the LEEF header, and the format set by the registry
+var leefHeaderFields = map[string]bool{
+	"leef_version": true, "device_vendor": true, "device_product": true, "device_version": true,
+	"event_id": true, "format": true,
+}
+
+// extensionField returns the field name of an extension key or label: one that collides
+// with a header field is prefixed with "ext_", so that the header is never overwritten
+func extensionField(key string, header map[string]bool) string {
+	if header[key] {
+		return "ext_" + key
+	}
+	return key
+}
+
+// CEFParser decodes ArcSight Common Event Format payloads
+// Format: [syslog header] CEF:Version|Vendor|Product|Version|Signature ID|Name|Severity|Extension
+type CEFParser struct{}
+
+// Name returns the format name
+func (CEFParser) Name() string { return "cef" }
+
+// Detect reports whether raw contains a CEF payload
+func (CEFParser) Detect(raw string) bool {
+	return strings.Contains(raw, "CEF:") && cefStartRe.MatchString(raw)
+}
+
+// Parse decodes the syslog header and the CEF payload
+// Header fields: cef_version, device_vendor, device_product, device_version, signature_id, name, cef_severity
+// Extension keys become fields; csN values are also stored under their csNLabel
+// Keys named like a header field are stored with the "ext_" prefix
+func (CEFParser) Parse(raw string, opts Options) (*SyslogMessage, error) {
+	start := cefStartRe.FindStringIndex(raw)
+	if start == nil {
+		return nil, fmt.Errorf("invalid CEF payload")
+	}
+
+	msg, err := parseSyslogHeader(raw, raw[:start[0]], opts)
+	if err != nil {
+		return nil, err
+	}
+
+	header, extension := splitEscaped(raw[start[0]+len("CEF:"):], 7)
+	if len(header) < 7 {
+		return nil, fmt.Errorf("invalid CEF payload: %d header fields, want 7", len(header))
+	}
+
+	msg.SetField("cef_version", header[0])
+	msg.SetField("device_vendor", header[1])
+	msg.SetField("device_product", header[2])
+	msg.SetField("device_version", header[3])
+	msg.SetField("signature_id", header[4])
+	msg.SetField("name", header[5])
+	msg.SetField("cef_severity", header[6])
+
+	if severity, ok := cefSeverity(header[6]); ok {
+		msg.Severity = severity
+	}
+	if msg.Tag == "" {
+		msg.Tag = header[2]
+	}
+
+	ext := parseCEFExtension(extension)
+	for key, value := range ext {
+		msg.SetField(extensionField(key, cefHeaderFields), typedValue(keyValue{key: key, value: value}))
+	}
+	// Custom fields carry their meaning in a label: cs1Label=policy cs1=allow-web
+	for key, value := range ext {
+		if label, ok := ext[key+"Label"]; ok && label != "" {
+			msg.SetField(extensionField(label, cefHeaderFields), typedValue(keyValue{key: label, value: value}))
+		}
+	}
+
+	msg.Message = header[5]
+	if text := ext["msg"]; text != "" {
+		msg.Message += ": " + text
+	}
+	if timestamp, ok := parseEventTime(ext["rt"], opts.location(msg.Hostname)); ok {
+		msg.Timestamp = timestamp
+	}
+
+	return msg, nil
+}
+
+// LEEFParser decodes IBM QRadar Log Event Extended Format payloads
+// Format: [syslog header] LEEF:Version|Vendor|Product|Version|EventID|[Delimiter|]Attributes
+type LEEFParser struct{}
+
+// Name returns the format name
+func (LEEFParser) Name() string { return "leef" }
+
+// Detect reports whether raw contains a LEEF payload
+func (LEEFParser) Detect(raw string) bool {
+	return strings.Contains(raw, "LEEF:") && leefStartRe.MatchString(raw)
+}
+
+// Parse decodes the syslog header and the LEEF payload
+// Header fields: leef_version, device_vendor, device_product, device_version, event_id
+// Attributes named like a header field are stored with the "ext_" prefix
+func (LEEFParser) Parse(raw string, opts Options) (*SyslogMessage, error) {
+	start := leefStartRe.FindStringIndex(raw)
+	if start == nil {
+		return nil, fmt.Errorf("invalid LEEF payload")
+	}
+
+	msg, err := parseSyslogHeader(raw, raw[:start[0]], opts)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := raw[start[0]+len("LEEF:"):]
+	version := payload[:3]
+
+	// LEEF 2.0 adds a delimiter field after the event ID (a character or its hex code)
+	headerCount := 5
+	delimiter := "\t"
+	if version == "2.0" {
+		headerCount = 6
+	}
+	header, attributes := splitEscaped(payload, headerCount)
+	if len(header) < headerCount {
+		return nil, fmt.Errorf("invalid LEEF payload: %d header fields, want %d", len(header), headerCount)
+	}
+	if version == "2.0" && header[5] != "" {
+		delimiter = leefDelimiter(header[5])
+	}
+
+	msg.SetField("leef_version", header[0])
+	msg.SetField("device_vendor", header[1])
+	msg.SetField("device_product", header[2])
+	msg.SetField("device_version", header[3])
+	msg.SetField("event_id", header[4])
+
+	attrs := make(map[string]string)
+	for _, pair := range strings.Split(attributes, delimiter) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		attrs[key] = value
+		msg.SetField(extensionField(key, leefHeaderFields), typedValue(keyValue{key: key, value: value}))
+	}
+
+	if severity, ok := cefSeverity(attrs["sev"]); ok {
+		msg.Severity = severity
+	}
+	if msg.Tag == "" {
+		msg.Tag = header[2]
+	}
+	msg.Message = firstNonEmptyString(attrs["msg"], header[4])
+	if timestamp, ok := parseEventTime(attrs["devTime"], opts.location(msg.Hostname)); ok {
+		msg.Timestamp = timestamp
+	}
+
+	return msg, nil
+}
+
+// splitEscaped splits the first n "|"-separated header fields, unescaping "\|" and "\\"
+// and returns them with the remainder of the payload
+func splitEscaped(s string, n int) ([]string, string) {
+	var fields []string
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && (s[i+1] == '|' || s[i+1] == '\\'):
+			i++
+			b.WriteByte(s[i])
+		case s[i] == '|':
+			fields = append(fields, b.String())
+			b.Reset()
+			if len(fields) == n {
+				return fields, s[i+1:]
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return fields, ""
+}
+
+// parseCEFExtension decodes "key=value key2=value with spaces" where values run until the next key
+// Escapes: \= \\ \n \r
+func parseCEFExtension(s string) map[string]string {
+	ext := make(map[string]string)
+
+	// An escaped "\=" never matches: keys cannot contain a backslash
+	keys := cefKeyRe.FindAllStringSubmatchIndex(s, -1)
+
+	for i, loc := range keys {
+		end := len(s)
+		if i+1 < len(keys) {
+			end = keys[i+1][0]
+		}
+		value := strings.TrimSpace(s[loc[1]:end])
+		ext[s[loc[2]:loc[3]]] = unescapeCEF(value)
+	}
+	return ext
+}
+
+var cefUnescaper = strings.NewReplacer(`\=`, "=", `\\`, `\`, `\n`, "\n", `\r`, "\r")
+
+func unescapeCEF(s string) string {
+	return cefUnescaper.Replace(s)
+}
+
+// cefSeverity maps a CEF severity (0-10 or Low/Medium/High/Very-High) to a syslog severity
+func cefSeverity(value string) (int, bool) {
+	switch strings.ToLower(value) {
+	case "low":
+		return syslog.SeverityInfo, true
+	case "medium":
+		return syslog.SeverityWarning, true
+	case "high":
+		return syslog.SeverityError, true
+	case "very-high":
+		return syslog.SeverityCritical, true
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 || n > 10 {
+		return 0, false
+	}
+	switch {
+	case n <= 3:
+		return syslog.SeverityInfo, true
+	case n <= 6:
+		return syslog.SeverityWarning, true
+	case n <= 8:
+		return syslog.SeverityError, true
+	default:
+		return syslog.SeverityCritical, true
+	}
+}
+
+// leefDelimiter decodes the LEEF 2.0 delimiter: a character, or its hex code ("x09", "0x5E")
+func leefDelimiter(value string) string {
+	if len(value) > 1 {
+		hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(value), "0x"), "x")
+		if code, err := strconv.ParseUint(hex, 16, 8); err == nil {
+			return string(rune(code))
+		}
+	}
+	return value
+}
+
+// parseEventTime parses CEF rt / LEEF devTime: epoch milliseconds or "Jan 02 2006 15:04:05" in loc
+func parseEventTime(value string, loc *time.Location) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms).UTC(), true
+	}
+	for _, layout := range []string{"Jan 02 2006 15:04:05", "Jan 02 2006 15:04:05.000", time.RFC3339} {
+		if timestamp, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return timestamp.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
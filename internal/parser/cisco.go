@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ciscoMnemonicRe matches the %FACILITY-SEVERITY-MNEMONIC: code of Cisco IOS messages
+var ciscoMnemonicRe = regexp.MustCompile(`%([A-Z0-9_]+(?:-[A-Z0-9_]+)*)-([0-7])-([A-Z0-9_]+):\s*`)
+
+// ciscoHeaderRe matches what precedes the code:
+// optional relay header, sequence number, origin hostname and device timestamp
+// Examples: "52: router1: *Mar  1 00:03:12.911 UTC: " or "Oct 11 2024 22:14:15 asa01 : " (ASA)
+var ciscoHeaderRe = regexp.MustCompile(`^(?:([A-Z][a-z]{2} [ \d]\d(?: \d{4})? \d\d:\d\d:\d\d) (\S+) (?:: )?)?` +
+	`(?:(\d+): )?` +
+	`(?:([A-Za-z][\w.-]*): )?` +
+	`(?:([*.])?([A-Z][a-z]{2} +\d{1,2}(?: \d{4})? \d\d:\d\d:\d\d(?:\.\d+)?)(?: ([A-Z]{2,5}))?: )?$`)
+
+// Cisco device timestamp formats ("service timestamps log datetime [msec] [year]")
+// Milliseconds are accepted by time.Parse after the seconds
+var ciscoTimeFormats = []string{
+	"Jan 2 15:04:05",
+	"Jan 2 2006 15:04:05",
+}
+
+// CiscoParser decodes Cisco IOS / IOS-XE / NX-OS messages
+// Format: <PRI>SEQ: [HOST: ][*]TIMESTAMP: %FAC-SEV-MNEMONIC: MESSAGE
+type CiscoParser struct{}
+
+// Name returns the format name
+func (CiscoParser) Name() string { return "cisco" }
+
+// Detect reports whether raw carries a %FAC-SEV-MNEMONIC code
+func (CiscoParser) Detect(raw string) bool {
+	return strings.Contains(raw, "%") && ciscoMnemonicRe.MatchString(raw)
+}
+
+// Parse decodes a Cisco message
+// Fields: facility, severity, mnemonic, sequence, clock_unsynced, device_time
+func (CiscoParser) Parse(raw string, opts Options) (*SyslogMessage, error) {
+	if opts.ReceivedAt.IsZero() {
+		opts.ReceivedAt = time.Now()
+	}
+	msg := &SyslogMessage{Raw: raw, ReceivedAt: opts.ReceivedAt}
+
+	pri, rest, err := parsePriority(raw)
+	if err != nil {
+		return nil, err
+	}
+	msg.Facility = pri / 8
+	msg.Severity = pri % 8
+
+	loc := ciscoMnemonicRe.FindStringSubmatchIndex(rest)
+	if loc == nil {
+		return nil, fmt.Errorf("invalid Cisco format: missing mnemonic")
+	}
+
+	header := ciscoHeaderRe.FindStringSubmatch(strings.TrimLeft(rest[:loc[0]], " "))
+	if header == nil {
+		return nil, fmt.Errorf("invalid Cisco format: unrecognized header")
+	}
+
+	facility := rest[loc[2]:loc[3]]
+	severity, _ := strconv.Atoi(rest[loc[4]:loc[5]])
+	mnemonic := rest[loc[6]:loc[7]]
+
+	msg.Severity = severity
+	msg.Tag = facility + "-" + strconv.Itoa(severity) + "-" + mnemonic
+	msg.Message = rest[loc[1]:]
+
+	msg.SetField("facility", facility)
+	msg.SetField("severity", severity)
+	msg.SetField("mnemonic", mnemonic)
+	if header[3] != "" {
+		sequence, _ := strconv.ParseInt(header[3], 10, 64)
+		msg.SetField("sequence", sequence)
+	}
+
+	// Hostname: origin-id, then relay header, then the sender
+	switch {
+	case header[4] != "":
+		msg.Hostname = header[4]
+	case header[2] != "":
+		msg.Hostname = header[2]
+	case opts.DefaultHostname != "":
+		msg.Hostname = opts.DefaultHostname
+	default:
+		msg.Hostname = opts.SourceHost
+	}
+
+	// A leading "*" means the device clock is not synchronized: keep the
+	// device time as a field but use the reception time
+	msg.Timestamp = opts.ReceivedAt.UTC()
+	if header[6] != "" {
+		msg.SetField("device_time", header[6])
+		if header[5] == "*" {
+			msg.SetField("clock_unsynced", true)
+		} else if timestamp, ok := parseCiscoTimestamp(header[6], header[7]); ok {
+			msg.Timestamp = opts.timestamp(timestamp, msg.Hostname)
+		}
+	} else if header[1] != "" {
+		if timestamp, ok := parseCiscoTimestamp(header[1], ""); ok {
+			msg.Timestamp = opts.timestamp(timestamp, msg.Hostname)
+		}
+	}
+
+	return msg, nil
+}
+
+// parseCiscoTimestamp parses a device timestamp, with the year when present
+// Only UTC and GMT zones are known; others are left to the sender's timezone
+func parseCiscoTimestamp(value, zone string) (deviceTime, bool) {
+	var loc *time.Location
+	if zone == "UTC" || zone == "GMT" {
+		loc = time.UTC
+	}
+	// Collapse the padding of single-digit days ("Mar  1")
+	value = strings.Join(strings.Fields(value), " ")
+
+	for _, format := range ciscoTimeFormats {
+		if timestamp, err := time.Parse(format, value); err == nil {
+			return deviceTime{clock: timestamp, local: true, zone: loc, year: timestamp.Year() != 0}, true
+		}
+	}
+	return deviceTime{}, false
+}
@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// bsdTimestampRe matches "Oct 11 22:14:15", optionally with a year ("Oct 11 2024 22:14:15")
+// and fractional seconds ("Oct 11 22:14:15.123")
+var bsdTimestampRe = regexp.MustCompile(`^[A-Z][a-z]{2} [ 0-9][0-9] (?:[0-9]{4} )?[0-9]{2}:[0-9]{2}:[0-9]{2}(?:\.[0-9]{1,9})?`)
+
+// rfc3339TimestampRe matches the RFC 3339 timestamps many "RFC 3164" senders use instead
+var rfc3339TimestampRe = regexp.MustCompile(`^[0-9]{4}-[0-9]{2}-[0-9]{2}T[0-9]{2}:[0-9]{2}:[0-9]{2}(?:\.[0-9]{1,9})?(?:Z|[+-][0-9]{2}:?[0-9]{2})?`)
+
+// bsdTimeFormats are the layouts of bsdTimestampRe; fractional seconds are accepted by time.Parse
+var bsdTimeFormats = []string{
+	"Jan _2 15:04:05",
+	"Jan _2 2006 15:04:05",
+}
+
+// rfc3339TimeFormats are the layouts of rfc3339TimestampRe, zoned first
+var rfc3339TimeFormats = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04:05.999999999",
+}
+
+// deviceTime is a timestamp as written by the sender
+type deviceTime struct {
+	clock time.Time      // Wall clock, in UTC when local is set
+	local bool           // No offset: the clock is in the sender's timezone, or in zone
+	zone  *time.Location // Timezone named by the sender instead of an offset (nil for none)
+	year  bool           // The year was given
+}
+
+// TimezoneRule maps senders to the timezone of their timestamps
+// Hosts are hostnames (with * wildcards), IP addresses or CIDR ranges
+type TimezoneRule struct {
+	Timezone string   `yaml:"timezone" json:"timezone"`
+	Hosts    []string `yaml:"hosts" json:"hosts"`
+}
+
+// timezoneEntry is a compiled TimezoneRule
+type timezoneEntry struct {
+	location *time.Location
+	names    []string
+	networks []*net.IPNet
+}
+
+// TimezoneMap resolves the timezone of a sender from its hostname or address
+type TimezoneMap struct {
+	defaultLocation *time.Location
+	entries         []timezoneEntry
+}
+
+// NewTimezoneMap loads the timezones of the rules; defaultTimezone applies to other
+// senders (empty for the server's timezone)
+func NewTimezoneMap(defaultTimezone string, rules []TimezoneRule) (*TimezoneMap, error) {
+	m := &TimezoneMap{defaultLocation: time.Local}
+	if defaultTimezone != "" {
+		location, err := time.LoadLocation(defaultTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default timezone: %w", err)
+		}
+		m.defaultLocation = location
+	}
+
+	for i, rule := range rules {
+		location, err := time.LoadLocation(rule.Timezone)
+		if err != nil || rule.Timezone == "" {
+			return nil, fmt.Errorf("timezone rule %d: invalid timezone %q", i, rule.Timezone)
+		}
+		entry := timezoneEntry{location: location}
+		for _, host := range rule.Hosts {
+			if _, network, err := net.ParseCIDR(host); err == nil {
+				entry.networks = append(entry.networks, network)
+				continue
+			}
+			if _, err := path.Match(host, ""); err != nil {
+				return nil, fmt.Errorf("timezone rule %d: invalid host pattern %q", i, host)
+			}
+			entry.names = append(entry.names, strings.ToLower(host))
+		}
+		m.entries = append(m.entries, entry)
+	}
+	return m, nil
+}
+
+// Lookup returns the timezone of a sender: rules matching the hostname first, then
+// rules matching the source address, then the default timezone
+func (m *TimezoneMap) Lookup(hostname, sourceHost string) *time.Location {
+	if hostname != "" {
+		for _, entry := range m.entries {
+			if entry.matchName(hostname) {
+				return entry.location
+			}
+		}
+	}
+	if sourceHost != "" {
+		ip := net.ParseIP(sourceHost)
+		for _, entry := range m.entries {
+			if entry.matchName(sourceHost) || ip != nil && entry.contains(ip) {
+				return entry.location
+			}
+		}
+	}
+	return m.defaultLocation
+}
+
+func (e timezoneEntry) matchName(name string) bool {
+	name = strings.ToLower(name)
+	for _, pattern := range e.names {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (e timezoneEntry) contains(ip net.IP) bool {
+	for _, network := range e.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// location returns the timezone of timestamps sent by hostname
+func (o Options) location(hostname string) *time.Location {
+	if o.Timezones == nil {
+		return time.Local
+	}
+	return o.Timezones.Lookup(hostname, o.SourceHost)
+}
+
+// parseRFC3164Timestamp parses the timestamp at the start of s and returns its length
+// BSD timestamps have no year or timezone; RFC 3339 timestamps are also accepted
+func parseRFC3164Timestamp(s string) (deviceTime, int, bool) {
+	if value := rfc3339TimestampRe.FindString(s); value != "" {
+		for i, format := range rfc3339TimeFormats {
+			if timestamp, err := time.Parse(format, value); err == nil {
+				return deviceTime{clock: timestamp, local: i == len(rfc3339TimeFormats)-1, year: true}, len(value), true
+			}
+		}
+	}
+
+	if value := bsdTimestampRe.FindString(s); value != "" {
+		for _, format := range bsdTimeFormats {
+			if timestamp, err := time.Parse(format, value); err == nil {
+				return deviceTime{clock: timestamp, local: true, year: timestamp.Year() != 0}, len(value), true
+			}
+		}
+	}
+
+	return deviceTime{}, 0, false
+}
+
+// timestamp converts a device time to UTC, in the sender's timezone when it has none and
+// names none
+// A missing year is the year of ReceivedAt; a timestamp more than a day ahead is from
+// last year and one more than eleven months behind from next year (New Year's Eve)
+func (o Options) timestamp(t deviceTime, hostname string) time.Time {
+	if !t.local {
+		return t.clock.UTC()
+	}
+
+	loc := t.zone
+	if loc == nil {
+		loc = o.location(hostname)
+	}
+	receivedAt := o.ReceivedAt
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	receivedAt = receivedAt.In(loc)
+
+	year := t.clock.Year()
+	if !t.year {
+		year = receivedAt.Year()
+	}
+	c := t.clock
+	timestamp := time.Date(year, c.Month(), c.Day(), c.Hour(), c.Minute(), c.Second(), c.Nanosecond(), loc)
+
+	if !t.year {
+		if timestamp.After(receivedAt.Add(24 * time.Hour)) {
+			timestamp = timestamp.AddDate(-1, 0, 0)
+		} else if timestamp.Before(receivedAt.AddDate(0, -11, 0)) {
+			timestamp = timestamp.AddDate(1, 0, 0)
+		}
+	}
+	return timestamp.UTC()
+}
+
+// checkClock flags timestamps further than MaxClockSkew from the reception time and
+// replaces the timestamp with the reception time when TrustReceiveTime is set
+func checkClock(msg *SyslogMessage, opts Options) {
+	if opts.ReceivedAt.IsZero() {
+		return
+	}
+
+	if opts.MaxClockSkew > 0 {
+		skew := msg.Timestamp.Sub(opts.ReceivedAt)
+		if skew > opts.MaxClockSkew || skew < -opts.MaxClockSkew {
+			msg.warn(WarningClockSkew)
+			msg.SetField("clock_skew_seconds", int64(skew.Seconds()))
+		}
+	}
+
+	if opts.TrustReceiveTime {
+		if _, ok := msg.Fields["device_time"]; !ok && !msg.Timestamp.Equal(opts.ReceivedAt) {
+			msg.SetField("device_time", msg.Timestamp.Format(time.RFC3339Nano))
+		}
+		msg.Timestamp = opts.ReceivedAt.UTC()
+	}
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimezones(t *testing.T) {
+	timezones, err := NewTimezoneMap("UTC", []TimezoneRule{
+		{Timezone: "America/New_York", Hosts: []string{"nyc-*", "10.20.0.0/16"}},
+		{Timezone: "Asia/Tokyo", Hosts: []string{"tokyo-fw1", "192.0.2.7"}},
+	})
+	if err != nil {
+		t.Fatalf("NewTimezoneMap: %v", err)
+	}
+	receivedAt := time.Date(2024, 10, 12, 3, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		input  string
+		source string
+		want   time.Time
+	}{
+		{
+			name:  "hostname wildcard",
+			input: "<34>Oct 11 22:14:15 NYC-core1 su: test",
+			want:  time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:   "source CIDR",
+			input:  "<34>Oct 11 22:14:15 core1 su: test",
+			source: "10.20.3.4",
+			want:   time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:   "hostname takes precedence over source",
+			input:  "<34>Oct 12 11:14:15 tokyo-fw1 su: test",
+			source: "10.20.3.4",
+			want:   time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:   "source IP without hostname",
+			input:  "<34>Oct 12 11:14:15 su[12]: test",
+			source: "192.0.2.7",
+			want:   time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:  "default timezone",
+			input: "<34>Oct 12 02:14:15 other su: test",
+			want:  time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:  "milliseconds",
+			input: "<34>Oct 12 02:14:15.123 other su: test",
+			want:  time.Date(2024, 10, 12, 2, 14, 15, 123000000, time.UTC),
+		},
+		{
+			name:  "year in the timestamp",
+			input: "<34>Oct 12 2023 02:14:15 other su: test",
+			want:  time.Date(2023, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:  "RFC 3339 with offset ignores the mapping",
+			input: "<34>2024-10-11T22:14:15.5-04:00 nyc-core1 su: test",
+			want:  time.Date(2024, 10, 12, 2, 14, 15, 500000000, time.UTC),
+		},
+		{
+			name:  "RFC 3339 without offset uses the mapping",
+			input: "<34>2024-10-11T22:14:15 nyc-core1 su: test",
+			want:  time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:  "Cisco origin hostname",
+			input: "<187>1234: nyc-sw1: Oct 11 22:14:15: %LINEPROTO-5-UPDOWN: Line protocol changed state to down",
+			want:  time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:   "Cisco UTC ignores the mapping",
+			input:  "<189>1234: Oct 12 02:14:15 UTC: %SYS-5-CONFIG_I: Configured",
+			source: "10.20.3.4",
+			want:   time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+		{
+			name:   "vendor header",
+			input:  "<134>Oct 11 22:14:15 fw CEF:0|Vendor|Product|1.0|100|Blocked|5|src=10.0.0.1",
+			source: "10.20.3.4",
+			want:   time.Date(2024, 10, 12, 2, 14, 15, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := ParseWithOptions(tt.input, Options{Timezones: timezones, SourceHost: tt.source, ReceivedAt: receivedAt})
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			if !msg.Timestamp.Equal(tt.want) {
+				t.Errorf("Timestamp = %v, want %v", msg.Timestamp, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewTimezoneMap("", []TimezoneRule{{Timezone: "Mars/Olympus", Hosts: []string{"x"}}}); err == nil {
+		t.Error("NewTimezoneMap with an unknown timezone succeeded, want error")
+	}
+}
+
+func TestYearInference(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		receivedAt time.Time
+		wantYear   int
+	}{
+		{"same year", "<34>Oct 11 22:14:15 h su: test", time.Date(2024, 10, 12, 0, 0, 0, 0, time.UTC), 2024},
+		{"December message received in January", "<34>Dec 31 23:59:59 h su: test", time.Date(2025, 1, 1, 0, 0, 5, 0, time.UTC), 2024},
+		{"January message received in December", "<34>Jan  1 00:00:05 h su: test", time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC), 2025},
+		{"Cisco January message received in December", "<189>1234: Jan  1 00:00:05 UTC: %SYS-5-CONFIG_I: Configured", time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC), 2025},
+		{"Cisco December message received in January", "<189>1234: Dec 31 23:59:59: %SYS-5-CONFIG_I: Configured", time.Date(2025, 1, 1, 0, 0, 5, 0, time.UTC), 2024},
+	}
+
+	utc, err := NewTimezoneMap("UTC", nil)
+	if err != nil {
+		t.Fatalf("NewTimezoneMap: %v", err)
+	}
+	for _, tt := range tests {
+		msg, err := ParseWithOptions(tt.input, Options{Timezones: utc, ReceivedAt: tt.receivedAt})
+		if err != nil {
+			t.Fatalf("%s: ParseWithOptions() error = %v", tt.name, err)
+		}
+		if msg.Timestamp.Year() != tt.wantYear {
+			t.Errorf("%s: year = %d, want %d", tt.name, msg.Timestamp.Year(), tt.wantYear)
+		}
+	}
+}
+
+func TestClockSkewAndReceiveTime(t *testing.T) {
+	receivedAt := time.Date(2024, 10, 12, 3, 0, 0, 0, time.UTC)
+	input := "<34>2024-10-11T22:00:00Z host su: test"
+
+	msg, err := ParseWithOptions(input, Options{ReceivedAt: receivedAt, MaxClockSkew: time.Hour})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if len(msg.ParseWarnings) != 1 || msg.ParseWarnings[0] != WarningClockSkew {
+		t.Errorf("ParseWarnings = %v, want [%s]", msg.ParseWarnings, WarningClockSkew)
+	}
+	if msg.Fields["clock_skew_seconds"] != int64(-5*3600) {
+		t.Errorf("clock_skew_seconds = %v, want %d", msg.Fields["clock_skew_seconds"], -5*3600)
+	}
+
+	msg, err = ParseWithOptions(input, Options{ReceivedAt: receivedAt, TrustReceiveTime: true})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if !msg.Timestamp.Equal(receivedAt) {
+		t.Errorf("Timestamp = %v, want %v", msg.Timestamp, receivedAt)
+	}
+	if msg.Fields["device_time"] != "2024-10-11T22:00:00Z" {
+		t.Errorf("device_time = %v, want 2024-10-11T22:00:00Z", msg.Fields["device_time"])
+	}
+	if len(msg.ParseWarnings) != 0 {
+		t.Errorf("ParseWarnings = %v, want none without MaxClockSkew", msg.ParseWarnings)
+	}
+}
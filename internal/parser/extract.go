@@ -0,0 +1,92 @@
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// maxExtractDepth bounds the nesting flattened from JSON payloads
+const maxExtractDepth = 8
+
+// ExtractFields detects a JSON object or key=value pairs in the message text and stores
+// them as typed fields. Nested JSON objects are flattened with "." separated keys
+// Messages decoded by a vendor parser are left alone, and existing fields are never overwritten
+func ExtractFields(msg *SyslogMessage) {
+	if msg == nil || msg.Message == "" {
+		return
+	}
+	if _, ok := msg.Fields["format"]; ok {
+		return
+	}
+
+	if fields, ok := extractJSON(msg.Message); ok {
+		for key, value := range fields {
+			setMissingField(msg, key, value)
+		}
+		return
+	}
+
+	pairs := parseKeyValues(msg.Message)
+	// A single pair is only a payload when it is the whole message ("latency=42"),
+	// otherwise prose such as "login failed for user=x" would be picked up
+	if len(pairs) == 0 || len(pairs) == 1 && strings.Count(strings.TrimSpace(msg.Message), " ") > 0 {
+		return
+	}
+	for _, kv := range pairs {
+		setMissingField(msg, kv.key, typedValue(kv))
+	}
+}
+
+// extractJSON decodes the first JSON object found in s
+func extractJSON(s string) (map[string]interface{}, bool) {
+	start := strings.IndexByte(s, '{')
+	if start < 0 {
+		return nil, false
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(s[start:]))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || len(object) == 0 {
+		return nil, false
+	}
+
+	fields := make(map[string]interface{})
+	flattenJSON(fields, "", object, 0)
+	return fields, true
+}
+
+// flattenJSON copies object into fields, joining nested keys with "."
+func flattenJSON(fields map[string]interface{}, prefix string, object map[string]interface{}, depth int) {
+	for key, value := range object {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok && depth < maxExtractDepth {
+			flattenJSON(fields, key, nested, depth+1)
+			continue
+		}
+		fields[key] = jsonValue(value)
+	}
+}
+
+// jsonValue converts json.Number values (also inside arrays) to int64 or float64
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case json.Number:
+		return jsonNumberValue(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+		return v
+	}
+	return value
+}
+
+func setMissingField(msg *SyslogMessage, key string, value interface{}) {
+	if _, exists := msg.Fields[key]; exists || key == "" {
+		return
+	}
+	msg.SetField(key, value)
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		fields  map[string]interface{}
+	}{
+		{
+			name:    "JSON after a prefix",
+			message: `request done msg={"user":"alice","latency_ms":42,"ratio":0.5,"ok":true}`,
+			fields: map[string]interface{}{
+				"user": "alice", "latency_ms": int64(42), "ratio": 0.5, "ok": true,
+			},
+		},
+		{
+			name:    "nested JSON is flattened",
+			message: `{"http":{"status":503,"path":"/api"},"tags":["a",1]}`,
+			fields: map[string]interface{}{
+				"http.status": int64(503), "http.path": "/api", "tags": []interface{}{"a", int64(1)},
+			},
+		},
+		{
+			name:    "logfmt",
+			message: `level=info user=x latency=42 msg="slow query" id=007`,
+			fields: map[string]interface{}{
+				"level": "info", "user": "x", "latency": int64(42), "msg": "slow query", "id": "007",
+			},
+		},
+		{
+			name:    "single pair message",
+			message: "latency=42",
+			fields:  map[string]interface{}{"latency": int64(42)},
+		},
+		{
+			name:    "single pair in prose is ignored",
+			message: "login failed for user=x",
+		},
+		{
+			name:    "plain text",
+			message: "Connection closed by 10.0.0.1 {preauth}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := &SyslogMessage{Message: tt.message}
+			ExtractFields(msg)
+			if len(msg.Fields) != len(tt.fields) {
+				t.Fatalf("Fields = %v, want %v", msg.Fields, tt.fields)
+			}
+			for key, want := range tt.fields {
+				if got := msg.Fields[key]; !reflect.DeepEqual(got, want) {
+					t.Errorf("Fields[%q] = %#v, want %#v", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractFieldsKeepsExisting(t *testing.T) {
+	msg := &SyslogMessage{Message: "user=x latency=42"}
+	msg.SetField("user", "gelf-user")
+	ExtractFields(msg)
+	if msg.Fields["user"] != "gelf-user" {
+		t.Errorf("Fields[user] = %v, want gelf-user", msg.Fields["user"])
+	}
+	if msg.Fields["latency"] != int64(42) {
+		t.Errorf("Fields[latency] = %v, want 42", msg.Fields["latency"])
+	}
+
+	vendor := &SyslogMessage{Message: "src=10.0.0.1 dst=10.0.0.2"}
+	vendor.SetField("format", "cef")
+	ExtractFields(vendor)
+	if len(vendor.Fields) != 1 {
+		t.Errorf("Fields = %v, want only format for vendor messages", vendor.Fields)
+	}
+}
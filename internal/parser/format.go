@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FormatRFC3164 formats the message as a BSD syslog line
+// Format: <PRI>TIMESTAMP HOSTNAME TAG[PID]: MESSAGE
+func (m *SyslogMessage) FormatRFC3164() string {
+	hostname := headerField(m.Hostname, 255)
+	if hostname == "-" {
+		hostname = "localhost"
+	}
+
+	tag := m.Tag
+	if tag == "" {
+		tag = m.AppName
+	}
+	tag = strings.NewReplacer(" ", "_", ":", "_", "[", "_").Replace(tag)
+	if len(tag) > 32 {
+		tag = tag[:32]
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<%d>%s %s ", m.Priority(), m.Timestamp.Local().Format(time.Stamp), hostname)
+	if tag != "" {
+		b.WriteString(tag)
+		if pid := m.PID; pid != "" {
+			fmt.Fprintf(&b, "[%s]", pid)
+		}
+		b.WriteString(": ")
+	}
+	b.WriteString(m.Message)
+	return b.String()
+}
+
+// FormatRFC5424 formats the message as an RFC 5424 line without structured data
+// Format: <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID - MSG
+func (m *SyslogMessage) FormatRFC5424() string {
+	appName := m.AppName
+	if appName == "" {
+		appName = m.Tag
+	}
+	procID := m.ProcID
+	if procID == "" {
+		procID = m.PID
+	}
+
+	timestamp := "-"
+	if !m.Timestamp.IsZero() {
+		timestamp = m.Timestamp.UTC().Format("2006-01-02T15:04:05.000000Z07:00")
+	}
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %s %s -",
+		m.Priority(),
+		timestamp,
+		headerField(m.Hostname, 255),
+		headerField(appName, 48),
+		headerField(procID, 128),
+		headerField(m.MsgID, 32),
+	)
+	if m.Message != "" {
+		line += " " + m.Message
+	}
+	return line
+}
+
+// headerField returns an RFC 5424 header value: printable ASCII without spaces, "-" when empty
+func headerField(s string, maxLen int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	if len(s) > maxLen {
+		s = s[:maxLen]
+	}
+	return s
+}
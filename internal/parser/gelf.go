@@ -0,0 +1,105 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"syslog-visualizer/pkg/syslog"
+	"time"
+)
+
+// ParseGELF decodes an uncompressed GELF (Graylog Extended Log Format) JSON payload
+// Mapping: host -> Hostname, short_message -> Message, level -> Severity,
+// timestamp -> Timestamp, _-prefixed additional fields -> Fields
+func ParseGELF(payload []byte) (*SyslogMessage, error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid GELF payload: %w", err)
+	}
+
+	msg := &SyslogMessage{
+		Raw:        string(payload),
+		Facility:   syslog.FacilityUser,
+		Severity:   syslog.SeverityAlert, // GELF default level is 1 (alert)
+		ReceivedAt: time.Now(),
+	}
+
+	shortMessage, _ := doc["short_message"].(string)
+	if shortMessage == "" {
+		return nil, fmt.Errorf("invalid GELF payload: missing short_message")
+	}
+	msg.Message = shortMessage
+
+	host, _ := doc["host"].(string)
+	if host == "" {
+		return nil, fmt.Errorf("invalid GELF payload: missing host")
+	}
+	msg.Hostname = host
+
+	if level, ok := doc["level"].(json.Number); ok {
+		if l, err := level.Int64(); err == nil && l >= 0 && l <= syslog.SeverityDebug {
+			msg.Severity = int(l)
+		}
+	}
+
+	msg.Timestamp = time.Now().UTC()
+	if ts, ok := doc["timestamp"].(json.Number); ok {
+		if seconds, err := ts.Float64(); err == nil {
+			sec := int64(seconds)
+			msg.Timestamp = time.Unix(sec, int64((seconds-float64(sec))*1e9)).UTC()
+		}
+	}
+
+	// GELF 1.0 fields, deprecated in 1.1 but still sent by some libraries
+	if facility, ok := doc["facility"].(string); ok {
+		if code, ok := syslog.ParseFacility(facility); ok {
+			msg.Facility = code
+		} else {
+			msg.Tag = facility
+		}
+	}
+
+	if fullMessage, ok := doc["full_message"].(string); ok && fullMessage != "" {
+		msg.SetField("full_message", fullMessage)
+	}
+
+	for key, value := range doc {
+		if !strings.HasPrefix(key, "_") || key == "_id" {
+			continue
+		}
+		if number, ok := value.(json.Number); ok {
+			value = jsonNumberValue(number)
+		}
+		msg.SetField(strings.TrimPrefix(key, "_"), value)
+	}
+
+	// Docker's GELF driver sends the tag and container name as additional fields
+	for _, key := range []string{"tag", "application_name", "container_name"} {
+		if msg.Tag != "" {
+			break
+		}
+		if value, ok := msg.Fields[key].(string); ok {
+			msg.Tag = value
+		}
+	}
+	if pid, ok := msg.Fields["pid"]; ok {
+		msg.PID = fmt.Sprint(pid)
+	}
+
+	return msg, nil
+}
+
+// jsonNumberValue converts a json.Number to int64 when integral, float64 otherwise
+func jsonNumberValue(n json.Number) interface{} {
+	if i, err := n.Int64(); err == nil {
+		return i
+	}
+	if f, err := n.Float64(); err == nil {
+		return f
+	}
+	return n.String()
+}
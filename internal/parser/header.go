@@ -0,0 +1,79 @@
+package parser
+
+import (
+	"strings"
+	"time"
+)
+
+// parseSyslogHeader decodes the syslog header preceding an embedded payload (CEF, LEEF, CSV…)
+// The header is <PRI> followed by an RFC 5424 header, an RFC 3164 timestamp, hostname and
+// optional TAG[PID]:, or nothing. Missing values fall back to the options.
+func parseSyslogHeader(raw, header string, opts Options) (*SyslogMessage, error) {
+	if opts.ReceivedAt.IsZero() {
+		opts.ReceivedAt = time.Now()
+	}
+	msg := &SyslogMessage{Raw: raw, ReceivedAt: opts.ReceivedAt, Timestamp: opts.ReceivedAt.UTC()}
+
+	pri, rest, err := parsePriority(header)
+	if err != nil {
+		if !opts.Lenient {
+			return nil, err
+		}
+		pri = 13
+		rest = header
+		msg.warn(WarningDefaultPriority)
+	}
+	msg.Facility = pri / 8
+	msg.Severity = pri % 8
+
+	// BSD timestamps are resolved once the hostname is known
+	var timestamp deviceTime
+	var hasTimestamp bool
+
+	if isRFC5424(header) {
+		// VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID
+		fields := strings.Fields(rest)
+		value := func(i int) string {
+			if i < len(fields) && fields[i] != "-" {
+				return fields[i]
+			}
+			return ""
+		}
+		if timestamp, err := time.Parse(time.RFC3339Nano, value(1)); err == nil {
+			msg.Timestamp = timestamp
+		}
+		msg.Hostname = value(2)
+		msg.AppName = value(3)
+		msg.Tag = msg.AppName
+		msg.ProcID = value(4)
+		msg.PID = msg.ProcID
+		msg.MsgID = value(5)
+	} else {
+		rest = strings.TrimSpace(rest)
+		var n int
+		timestamp, n, hasTimestamp = parseRFC3164Timestamp(rest)
+		if hasTimestamp {
+			rest = strings.TrimSpace(rest[n:])
+		}
+
+		tokens := strings.Fields(rest)
+		if len(tokens) > 0 && (opts.DefaultHostname == "" || !isTagToken(tokens[0])) {
+			msg.Hostname = tokens[0]
+			tokens = tokens[1:]
+		}
+		if len(tokens) > 0 {
+			if matches := tagRe.FindStringSubmatch(tokens[0]); matches != nil {
+				msg.Tag = matches[1]
+				msg.PID = matches[2]
+			}
+		}
+	}
+
+	if msg.Hostname == "" {
+		msg.Hostname = firstNonEmptyString(opts.DefaultHostname, opts.SourceHost)
+	}
+	if hasTimestamp {
+		msg.Timestamp = opts.timestamp(timestamp, msg.Hostname)
+	}
+	return msg, nil
+}
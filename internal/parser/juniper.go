@@ -0,0 +1,60 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// juniperEventRe matches the Junos event tag at the start of a BSD-format message
+var juniperEventRe = regexp.MustCompile(`^([A-Z][A-Z0-9]*(?:_[A-Z0-9]+)+): `)
+
+// juniperBSDRe detects BSD-format Junos messages: TIMESTAMP HOST daemon[pid]: EVENT_TAG: message
+var juniperBSDRe = regexp.MustCompile(`^<\d+>[A-Z][a-z]{2} [ \d]\d \d\d:\d\d:\d\d \S+ [^\s\[:]+(?:\[\d+\])?: [A-Z][A-Z0-9]*(?:_[A-Z0-9]+)+: `)
+
+// JuniperParser decodes Junos messages in structured-data (RFC 5424) or BSD format
+// Example: <165>1 2024-10-11T22:14:15.003Z fw01 RT_FLOW - RT_FLOW_SESSION_CREATE [junos@2636.1.1.1.2.40 source-address="10.0.0.1" ...] session created
+type JuniperParser struct{}
+
+// Name returns the format name
+func (JuniperParser) Name() string { return "juniper" }
+
+// Detect reports whether raw carries Junos structured data or a Junos event tag
+func (JuniperParser) Detect(raw string) bool {
+	return strings.Contains(raw, "[junos@2636.") || juniperBSDRe.MatchString(raw)
+}
+
+// Parse decodes a Junos message
+// The event tag goes into the "event" field and structured-data parameters into their own fields
+func (JuniperParser) Parse(raw string, opts Options) (*SyslogMessage, error) {
+	if isRFC5424(raw) {
+		msg, err := parseRFC5424(raw, opts)
+		if err != nil {
+			return nil, err
+		}
+		if msg.MsgID != "" {
+			msg.SetField("event", msg.MsgID)
+		}
+
+		if start := strings.Index(raw, "[junos@2636."); start >= 0 {
+			element := raw[start:]
+			if end := findStructuredDataEnd(element); end > 0 {
+				element = element[:end-1]
+			}
+			// Junos quotes every parameter value, numbers included
+			for _, kv := range parseKeyValues(element[1:]) {
+				msg.SetField(kv.key, typedValue(keyValue{key: kv.key, value: kv.value}))
+			}
+		}
+		return msg, nil
+	}
+
+	msg, err := parseRFC3164(raw, opts)
+	if err != nil {
+		return nil, err
+	}
+	if matches := juniperEventRe.FindStringSubmatch(msg.Message); matches != nil {
+		msg.SetField("event", matches[1])
+		msg.Message = msg.Message[len(matches[0]):]
+	}
+	return msg, nil
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// keyValue is one key=value pair of a key=value formatted message
+type keyValue struct {
+	key    string
+	value  string
+	quoted bool
+}
+
+// parseKeyValues extracts key=value pairs separated by spaces
+// Values may be double-quoted with backslash escapes; tokens that are not pairs are skipped
+func parseKeyValues(s string) []keyValue {
+	var pairs []keyValue
+
+	i := 0
+	for i < len(s) {
+		// Skip separators
+		for i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			i++
+		}
+		start := i
+		for i < len(s) && isKeyChar(s[i]) {
+			i++
+		}
+		if i == start || i >= len(s) || s[i] != '=' {
+			// Not a pair: skip the token
+			for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+				i++
+			}
+			continue
+		}
+		key := s[start:i]
+		i++
+
+		if i < len(s) && s[i] == '"' {
+			i++
+			var b strings.Builder
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				b.WriteByte(s[i])
+				i++
+			}
+			i++ // closing quote
+			pairs = append(pairs, keyValue{key: key, value: b.String(), quoted: true})
+			continue
+		}
+
+		valueStart := i
+		for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+			i++
+		}
+		pairs = append(pairs, keyValue{key: key, value: s[valueStart:i]})
+	}
+
+	return pairs
+}
+
+func isKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.'
+}
+
+// typedValue returns unquoted numbers as int64 or float64 so they compare numerically
+// Values with leading zeros (identifiers such as "0000000013") stay strings
+func typedValue(kv keyValue) interface{} {
+	if kv.quoted || kv.value == "" {
+		return kv.value
+	}
+	if len(kv.value) > 1 && kv.value[0] == '0' && kv.value[1] != '.' {
+		return kv.value
+	}
+	if n, err := strconv.ParseInt(kv.value, 10, 64); err == nil {
+		return n
+	}
+	if f, err := strconv.ParseFloat(kv.value, 64); err == nil && !strings.ContainsAny(kv.value, "eEnN") {
+		return f
+	}
+	return kv.value
+}
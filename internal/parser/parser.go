@@ -19,10 +19,45 @@ type SyslogMessage struct {
 	Tag       string    `json:"tag"`
 	Message   string    `json:"message"`
 	Raw       string    `json:"raw,omitempty"`
-	PID       string    `json:"pid,omitempty"`       // Process ID (RFC 3164)
-	AppName   string    `json:"appName,omitempty"`   // Application name (RFC 5424)
-	ProcID    string    `json:"procID,omitempty"`    // Process ID (RFC 5424)
-	MsgID     string    `json:"msgID,omitempty"`     // Message ID (RFC 5424)
+	PID       string    `json:"pid,omitempty"`     // Process ID (RFC 3164)
+	AppName   string    `json:"appName,omitempty"` // Application name (RFC 5424)
+	ProcID    string    `json:"procID,omitempty"`  // Process ID (RFC 5424)
+	MsgID     string    `json:"msgID,omitempty"`   // Message ID (RFC 5424)
+	PeerPID   int       `json:"peerPID,omitempty"` // Sender PID from socket credentials (local sockets)
+	PeerUID   *int      `json:"peerUID,omitempty"` // Sender UID from socket credentials (local sockets)
+
+	// Listener is the name of the listener that received the message (not stored)
+	Listener string `json:"-"`
+
+	// SourceHost is the address of the sender, without port (not stored)
+	SourceHost string `json:"-"`
+
+	// Acknowledged is set when the sender is told the message was committed (RELP, HTTP
+	// ingestion), which must then be on disk before storing returns (not stored)
+	Acknowledged bool `json:"-"`
+
+	// ReceivedAt is when the collector received the message
+	ReceivedAt time.Time `json:"receivedAt"`
+
+	// ParseWarnings lists the heuristics applied to a non-compliant message (lenient mode)
+	ParseWarnings []string `json:"parseWarnings,omitempty"`
+
+	// Fields holds structured attributes extracted from the message (e.g. GELF additional fields)
+	Fields map[string]interface{} `json:"fields,omitempty"`
+
+	// RepeatCount is the number of identical messages collapsed into this one by
+	// deduplication, received between FirstSeen and LastSeen (0 or 1 for a single message)
+	RepeatCount int       `json:"repeatCount,omitempty"`
+	FirstSeen   time.Time `json:"firstSeen,omitzero"`
+	LastSeen    time.Time `json:"lastSeen,omitzero"`
+}
+
+// SetField sets a structured field, allocating the map if needed
+func (m *SyslogMessage) SetField(key string, value interface{}) {
+	if m.Fields == nil {
+		m.Fields = make(map[string]interface{})
+	}
+	m.Fields[key] = value
 }
 
 // FacilityName returns the human-readable name for the facility
@@ -40,27 +75,76 @@ func (m *SyslogMessage) Priority() int {
 	return m.Facility*8 + m.Severity
 }
 
-// RFC 3164 timestamp formats (BSD syslog)
-var rfc3164TimeFormats = []string{
-	"Jan _2 15:04:05",
-	"Jan 02 15:04:05",
+// Parse warnings recorded on messages
+const (
+	WarningDefaultPriority    = "default-priority"     // Lenient: no valid PRI, 13 (user.notice) assumed
+	WarningMissingTimestamp   = "missing-timestamp"    // Lenient: no valid timestamp, ReceivedAt used
+	WarningHostnameFromSource = "hostname-from-source" // Lenient: no hostname, sender address used
+	WarningClockSkew          = "clock-skew"           // Timestamp further than MaxClockSkew from ReceivedAt
+	WarningTruncated          = "truncated"            // Cut at the listener's maximum message size
+	WarningSampled            = "sampled"              // Over the listener's rate limit, kept as a sample
+	WarningHostnameMismatch   = "hostname-mismatch"    // Hostname differs from the one expected from the source address
+)
+
+// Options tunes how messages are parsed
+type Options struct {
+	// DefaultHostname is used when the message carries no hostname,
+	// e.g. messages sent to /dev/log by logger or syslog(3)
+	DefaultHostname string
+
+	// Lenient accepts non-compliant RFC 3164 messages (RFC 3164 section 4.3.3):
+	// a missing PRI defaults to 13, a missing or garbled timestamp is replaced by
+	// ReceivedAt, and a missing hostname by SourceHost
+	Lenient bool
+
+	// SourceHost is the sender address, used as hostname fallback in lenient mode
+	SourceHost string
+
+	// ReceivedAt is when the message was received (default now)
+	ReceivedAt time.Time
+
+	// Registry selects vendor format parsers (default DefaultRegistry)
+	Registry *Registry
+
+	// Timezones resolves the timezone of timestamps that carry none (default time.Local)
+	Timezones *TimezoneMap
+
+	// TrustReceiveTime replaces the sender's timestamp with ReceivedAt;
+	// the sender's timestamp is kept in the device_time field
+	TrustReceiveTime bool
+
+	// MaxClockSkew flags messages whose timestamp is further than this from ReceivedAt (0 disables)
+	MaxClockSkew time.Duration
+}
+
+// warn records a lenient parsing heuristic
+func (m *SyslogMessage) warn(warning string) {
+	m.ParseWarnings = append(m.ParseWarnings, warning)
 }
 
-// Parse parses a raw syslog message according to RFC 3164 or RFC 5424
+// Parse parses a raw syslog message according to RFC 3164, RFC 5424 or a vendor format
 // Auto-detects the format based on the message structure
 func Parse(raw string) (*SyslogMessage, error) {
+	return ParseWithOptions(raw, Options{})
+}
+
+// ParseWithOptions parses a raw syslog message like Parse, using the given options
+func ParseWithOptions(raw string, opts Options) (*SyslogMessage, error) {
 	if raw == "" {
 		return nil, fmt.Errorf("empty syslog message")
 	}
+	if opts.ReceivedAt.IsZero() {
+		opts.ReceivedAt = time.Now()
+	}
 
-	// Try to detect format
+	// Vendor formats are detected first; otherwise
 	// RFC 5424 has format: <PRI>VERSION where VERSION is a digit
 	// RFC 3164 has format: <PRI>TIMESTAMP
-	if isRFC5424(raw) {
-		return ParseRFC5424(raw)
+	registry := opts.Registry
+	if registry == nil {
+		registry = DefaultRegistry
 	}
-
-	return ParseRFC3164(raw)
+	return registry.Parse(raw, opts)
 }
 
 // isRFC5424 detects if the message is in RFC 5424 format
@@ -75,83 +159,115 @@ func isRFC5424(raw string) bool {
 // Format: <PRI>TIMESTAMP HOSTNAME TAG[PID]: MESSAGE
 // Example: <34>Oct 11 22:14:15 mymachine su[1234]: 'su root' failed
 func ParseRFC3164(raw string) (*SyslogMessage, error) {
-	msg := &SyslogMessage{Raw: raw}
+	return parseRFC3164(raw, Options{})
+}
 
-	priEnd := strings.Index(raw, ">")
-	if priEnd == -1 || raw[0] != '<' {
-		return nil, fmt.Errorf("invalid RFC 3164 format: missing priority")
+func parseRFC3164(raw string, opts Options) (*SyslogMessage, error) {
+	if opts.ReceivedAt.IsZero() {
+		opts.ReceivedAt = time.Now()
 	}
+	msg := &SyslogMessage{Raw: raw, ReceivedAt: opts.ReceivedAt}
 
-	priStr := raw[1:priEnd]
-	pri, err := strconv.Atoi(priStr)
+	pri, rest, err := parsePriority(raw)
+	if err == nil && opts.Lenient && pri > 191 {
+		err = fmt.Errorf("invalid priority: %d", pri)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("invalid priority: %w", err)
+		if !opts.Lenient {
+			return nil, err
+		}
+		// RFC 3164 4.3.3: a relay adds PRI 13 and treats the whole packet as content
+		pri = 13
+		rest = raw
+		msg.warn(WarningDefaultPriority)
 	}
 
 	msg.Facility = pri / 8
 	msg.Severity = pri % 8
 
-	rest := strings.TrimSpace(raw[priEnd+1:])
+	rest = strings.TrimSpace(rest)
 
 	// Extract timestamp (e.g., "Oct 11 22:14:05")
 	// BSD syslog timestamp is 15 or 16 characters
-	if len(rest) < 16 {
+	if len(rest) < 16 && !opts.Lenient {
 		return nil, fmt.Errorf("invalid RFC 3164 format: message too short")
 	}
 
-	timestampStr := rest[:15]
-	var timestamp time.Time
-	var parseErr error
-
-	for _, format := range rfc3164TimeFormats {
-		// Parse in local timezone since RFC 3164 doesn't include timezone info
-		// and most syslog servers send local time
-		timestamp, parseErr = time.ParseInLocation(format, timestampStr, time.Local)
-		if parseErr == nil {
-			// Add current year since BSD syslog doesn't include it
-			now := time.Now()
-			timestamp = time.Date(
-				now.Year(),
-				timestamp.Month(),
-				timestamp.Day(),
-				timestamp.Hour(),
-				timestamp.Minute(),
-				timestamp.Second(),
-				timestamp.Nanosecond(),
-				time.Local,
-			)
-
-			// If timestamp is more than 24 hours in the future, it's probably from last year
-			// This handles year boundary (e.g., receiving Jan logs in December)
-			if timestamp.After(now.Add(24 * time.Hour)) {
-				timestamp = timestamp.AddDate(-1, 0, 0)
-			}
-
-			// Convert to UTC for consistent storage
-			// This ensures the timestamp is stored as the actual moment in time
-			timestamp = timestamp.UTC()
-
-			break
+	timestamp, n, ok := parseRFC3164Timestamp(rest)
+	switch {
+	case ok:
+		msg.Timestamp = opts.timestamp(timestamp, "")
+		rest = strings.TrimSpace(rest[n:])
+	case opts.Lenient:
+		// RFC 3164 4.3.2: without a valid timestamp the rest is kept as is
+		msg.Timestamp = opts.ReceivedAt.UTC()
+		msg.warn(WarningMissingTimestamp)
+		return parseRFC3164Content(msg, rest, opts)
+	default:
+		msg.Timestamp = opts.ReceivedAt.UTC()
+		if len(rest) > 15 {
+			rest = strings.TrimSpace(rest[15:])
 		}
 	}
 
-	if parseErr != nil {
-		timestamp = time.Now()
+	// Local senders (logger, syslog(3) via /dev/log) omit the hostname: on the
+	// listeners that supply one, a first token ending with a colon is already the TAG
+	if opts.DefaultHostname != "" && isTagToken(rest) {
+		return parseRFC3164Content(msg, rest, opts)
 	}
-	msg.Timestamp = timestamp
 
-	rest = strings.TrimSpace(rest[15:])
 	parts := strings.SplitN(rest, " ", 2)
 	if len(parts) < 2 {
-		return nil, fmt.Errorf("invalid RFC 3164 format: missing hostname or message")
+		if !opts.Lenient {
+			return nil, fmt.Errorf("invalid RFC 3164 format: missing hostname or message")
+		}
+		return parseRFC3164Content(msg, rest, opts)
 	}
 
 	msg.Hostname = parts[0]
-	rest = parts[1]
+	if ok {
+		// The sender's timezone may be mapped by hostname
+		msg.Timestamp = opts.timestamp(timestamp, msg.Hostname)
+	}
+	extractTag(msg, parts[1])
+	return msg, nil
+}
+
+// parsePriority extracts the <PRI> prefix and returns the remainder
+func parsePriority(raw string) (int, string, error) {
+	priEnd := strings.Index(raw, ">")
+	if priEnd == -1 || raw[0] != '<' {
+		return 0, "", fmt.Errorf("invalid RFC 3164 format: missing priority")
+	}
+
+	pri, err := strconv.Atoi(raw[1:priEnd])
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid priority: %w", err)
+	}
+	return pri, raw[priEnd+1:], nil
+}
+
+// parseRFC3164Content handles a message without hostname: the hostname comes from the
+// options and the content is either TAG[PID]: MESSAGE or, in lenient mode, free text
+func parseRFC3164Content(msg *SyslogMessage, content string, opts Options) (*SyslogMessage, error) {
+	msg.Hostname = opts.DefaultHostname
+	if msg.Hostname == "" && opts.Lenient && opts.SourceHost != "" {
+		msg.Hostname = opts.SourceHost
+		msg.warn(WarningHostnameFromSource)
+	}
+
+	if opts.Lenient && !isTagToken(content) {
+		msg.Message = content
+		return msg, nil
+	}
+
+	extractTag(msg, content)
+	return msg, nil
+}
 
-	// Extract TAG[PID]: MESSAGE
-	// TAG can be followed by [PID] and then : or just :
-	tagRe := regexp.MustCompile(`^([^\s\[:]+)(?:\[(\d+)\])?:\s*(.*)$`)
+// extractTag splits TAG[PID]: MESSAGE
+// TAG can be followed by [PID] and then : or just :
+func extractTag(msg *SyslogMessage, rest string) {
 	matches := tagRe.FindStringSubmatch(rest)
 
 	if matches != nil {
@@ -168,15 +284,32 @@ func ParseRFC3164(raw string) (*SyslogMessage, error) {
 			msg.Message = parts[1]
 		}
 	}
+}
 
-	return msg, nil
+// tagRe matches TAG[PID]: MESSAGE
+var tagRe = regexp.MustCompile(`^([^\s\[:]+)(?:\[(\d+)\])?:\s*(.*)$`)
+
+// tagTokenRe matches a first token that is a TAG rather than a hostname
+var tagTokenRe = regexp.MustCompile(`^[^\s\[:]+(?:\[\d+\])?:(?:\s|$)`)
+
+// isTagToken reports whether the text after the timestamp starts with TAG[PID]:
+// Hostnames never end with a colon, so such a token means the hostname was omitted
+func isTagToken(s string) bool {
+	return tagTokenRe.MatchString(s)
 }
 
 // ParseRFC5424 parses a syslog message in RFC 5424 format
 // Format: <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
 // Example: <34>1 2024-10-11T22:14:15.003Z mymachine su 1234 ID47 - 'su root' failed
 func ParseRFC5424(raw string) (*SyslogMessage, error) {
-	msg := &SyslogMessage{Raw: raw}
+	return parseRFC5424(raw, Options{})
+}
+
+func parseRFC5424(raw string, opts Options) (*SyslogMessage, error) {
+	if opts.ReceivedAt.IsZero() {
+		opts.ReceivedAt = time.Now()
+	}
+	msg := &SyslogMessage{Raw: raw, ReceivedAt: opts.ReceivedAt}
 
 	priEnd := strings.Index(raw, ">")
 	if priEnd == -1 || raw[0] != '<' {
@@ -214,11 +347,13 @@ func ParseRFC5424(raw string) (*SyslogMessage, error) {
 		}
 		msg.Timestamp = timestamp
 	} else {
-		msg.Timestamp = time.Now()
+		msg.Timestamp = opts.ReceivedAt.UTC()
 	}
 
 	if fields[2] != "-" {
 		msg.Hostname = fields[2]
+	} else {
+		msg.Hostname = opts.DefaultHostname
 	}
 
 	if fields[3] != "-" {
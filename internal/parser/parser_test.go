@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -332,6 +333,24 @@ func TestTimestampParsing(t *testing.T) {
 	}
 }
 
+func TestReceivedAtFallbackIsUTC(t *testing.T) {
+	receivedAt := time.Date(2024, 10, 11, 22, 14, 15, 0, time.FixedZone("CEST", 2*3600))
+	opts := Options{ReceivedAt: receivedAt}
+
+	for _, input := range []string{
+		"<34>Xyz 99 99:99:99 mymachine su: garbled timestamp",
+		"<165>1 - mymachine app - - - nil timestamp",
+	} {
+		msg, err := ParseWithOptions(input, opts)
+		if err != nil {
+			t.Fatalf("ParseWithOptions(%q) error = %v", input, err)
+		}
+		if !msg.Timestamp.Equal(receivedAt) || msg.Timestamp.Location() != time.UTC {
+			t.Errorf("ParseWithOptions(%q) Timestamp = %v, want %v in UTC", input, msg.Timestamp, receivedAt.UTC())
+		}
+	}
+}
+
 func TestHelperMethods(t *testing.T) {
 	// Priority <34> = Facility 4 (auth) + Severity 2 (critical)
 	input := "<34>Oct 11 22:14:15 mymachine su: test"
@@ -371,3 +390,196 @@ func TestHelperMethods(t *testing.T) {
 		t.Errorf("Priority() = %v, want 165", msg2.Priority())
 	}
 }
+
+func TestParseWithoutHostname(t *testing.T) {
+	opts := Options{DefaultHostname: "localbox"}
+
+	tests := []struct {
+		name     string
+		input    string
+		hostname string
+		tag      string
+		pid      string
+		message  string
+	}{
+		{
+			name:     "logger without PID",
+			input:    "<13>Oct 11 22:14:15 alice: hello world",
+			hostname: "localbox",
+			tag:      "alice",
+			message:  "hello world",
+		},
+		{
+			name:     "syslog(3) with LOG_PID",
+			input:    "<30>Oct 11 22:14:15 sshd[4242]: Accepted publickey",
+			hostname: "localbox",
+			tag:      "sshd",
+			pid:      "4242",
+			message:  "Accepted publickey",
+		},
+		{
+			name:     "Hostname present is kept",
+			input:    "<34>Oct 11 22:14:15 mymachine su[1234]: 'su root' failed",
+			hostname: "mymachine",
+			tag:      "su",
+			pid:      "1234",
+			message:  "'su root' failed",
+		},
+		{
+			name:     "RFC 5424 nil hostname",
+			input:    "<165>1 2003-10-11T22:14:15.003Z - app - - - event",
+			hostname: "localbox",
+			tag:      "app",
+			message:  "event",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWithOptions(tt.input, opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			if got.Hostname != tt.hostname {
+				t.Errorf("Hostname = %v, want %v", got.Hostname, tt.hostname)
+			}
+			if got.Tag != tt.tag {
+				t.Errorf("Tag = %v, want %v", got.Tag, tt.tag)
+			}
+			if got.PID != tt.pid {
+				t.Errorf("PID = %v, want %v", got.PID, tt.pid)
+			}
+			if got.Message != tt.message {
+				t.Errorf("Message = %v, want %v", got.Message, tt.message)
+			}
+		})
+	}
+}
+
+func TestParseHostnameEndingWithColon(t *testing.T) {
+	// Without a default hostname (network listeners) the first token stays the hostname,
+	// even when it ends with a colon
+	got, err := ParseWithOptions("<13>Oct 11 22:14:15 fw1: sshd[42]: Accepted publickey", Options{})
+	if err != nil {
+		t.Fatalf("ParseWithOptions() error = %v", err)
+	}
+	if got.Hostname != "fw1:" || got.Tag != "sshd" || got.PID != "42" {
+		t.Errorf("Hostname, Tag, PID = %q, %q, %q, want fw1:, sshd, 42", got.Hostname, got.Tag, got.PID)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	receivedAt := time.Date(2024, 10, 11, 22, 14, 15, 0, time.UTC)
+	opts := Options{Lenient: true, SourceHost: "192.0.2.7", ReceivedAt: receivedAt}
+
+	tests := []struct {
+		name     string
+		input    string
+		priority int
+		hostname string
+		tag      string
+		message  string
+		warnings []string
+	}{
+		{
+			name:     "Missing PRI",
+			input:    "Oct 11 22:14:15 switch01 link down",
+			priority: 13,
+			hostname: "switch01",
+			tag:      "link",
+			message:  "down",
+			warnings: []string{WarningDefaultPriority},
+		},
+		{
+			name:     "Bare text",
+			input:    "link down",
+			priority: 13,
+			hostname: "192.0.2.7",
+			message:  "link down",
+			warnings: []string{WarningDefaultPriority, WarningMissingTimestamp, WarningHostnameFromSource},
+		},
+		{
+			name:     "Short message with PRI",
+			input:    "<27>fan failed",
+			priority: 27,
+			hostname: "192.0.2.7",
+			message:  "fan failed",
+			warnings: []string{WarningMissingTimestamp, WarningHostnameFromSource},
+		},
+		{
+			name:     "Garbled timestamp with tag",
+			input:    "<30>2024/10/11 22:14 dhcpd: lease renewed",
+			priority: 30,
+			hostname: "192.0.2.7",
+			message:  "2024/10/11 22:14 dhcpd: lease renewed",
+			warnings: []string{WarningMissingTimestamp, WarningHostnameFromSource},
+		},
+		{
+			name:     "Tag without timestamp",
+			input:    "<30>dhcpd[12]: lease renewed",
+			priority: 30,
+			hostname: "192.0.2.7",
+			tag:      "dhcpd",
+			message:  "lease renewed",
+			warnings: []string{WarningMissingTimestamp, WarningHostnameFromSource},
+		},
+		{
+			name:     "Compliant message has no warnings",
+			input:    "<34>Oct 11 22:14:15 mymachine su: 'su root' failed",
+			priority: 34,
+			hostname: "mymachine",
+			tag:      "su",
+			message:  "'su root' failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWithOptions(tt.input, opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			if got.Priority() != tt.priority {
+				t.Errorf("Priority = %v, want %v", got.Priority(), tt.priority)
+			}
+			if got.Hostname != tt.hostname {
+				t.Errorf("Hostname = %v, want %v", got.Hostname, tt.hostname)
+			}
+			if got.Tag != tt.tag {
+				t.Errorf("Tag = %v, want %v", got.Tag, tt.tag)
+			}
+			if got.Message != tt.message {
+				t.Errorf("Message = %v, want %v", got.Message, tt.message)
+			}
+			if strings.Join(got.ParseWarnings, ",") != strings.Join(tt.warnings, ",") {
+				t.Errorf("ParseWarnings = %v, want %v", got.ParseWarnings, tt.warnings)
+			}
+			if !got.ReceivedAt.Equal(receivedAt) {
+				t.Errorf("ReceivedAt = %v, want %v", got.ReceivedAt, receivedAt)
+			}
+		})
+	}
+
+	// The same messages are rejected in strict mode
+	for _, input := range []string{"link down", "<27>fan failed"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) expected error in strict mode", input)
+		}
+	}
+}
+
+func TestFormatRFC3164(t *testing.T) {
+	msg := &SyslogMessage{
+		Facility:  4,
+		Severity:  2,
+		Hostname:  "mymachine",
+		Tag:       "su",
+		PID:       "123",
+		Message:   "'su root' failed",
+		Timestamp: time.Date(2024, 10, 11, 22, 14, 15, 0, time.Local),
+	}
+	want := "<34>Oct 11 22:14:15 mymachine su[123]: 'su root' failed"
+	if got := msg.FormatRFC3164(); got != want {
+		t.Errorf("FormatRFC3164() = %q, want %q", got, want)
+	}
+}
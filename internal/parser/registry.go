@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"sync"
+)
+
+// FormatParser decodes one message format (typically a vendor format)
+type FormatParser interface {
+	// Name identifies the format; it is recorded in the "format" field
+	Name() string
+	// Detect reports whether raw looks like this format
+	Detect(raw string) bool
+	// Parse decodes raw; it is only called when Detect returned true
+	Parse(raw string, opts Options) (*SyslogMessage, error)
+}
+
+// Registry selects the parser of a message by format detection
+// Messages no registered parser recognizes are parsed as RFC 5424 or RFC 3164
+type Registry struct {
+	mu      sync.RWMutex
+	parsers []FormatParser
+}
+
+// NewRegistry creates a registry with the given parsers, tried in order
+func NewRegistry(parsers ...FormatParser) *Registry {
+	return &Registry{parsers: parsers}
+}
+
+// DefaultRegistry is used by Parse and ParseWithOptions when no registry is set
+var DefaultRegistry = NewRegistry(
+	CEFParser{},
+	LEEFParser{},
+	CiscoParser{},
+	FortinetParser{},
+	PaloAltoParser{},
+	JuniperParser{},
+)
+
+// Register adds a parser, tried after the ones already registered
+func (r *Registry) Register(p FormatParser) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parsers = append(r.parsers, p)
+}
+
+// Formats returns the names of the registered parsers
+func (r *Registry) Formats() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, len(r.parsers))
+	for i, p := range r.parsers {
+		names[i] = p.Name()
+	}
+	return names
+}
+
+// Parse decodes raw with the first parser that detects its format
+// If that parser fails, the message falls back to RFC 5424 / RFC 3164 parsing
+func (r *Registry) Parse(raw string, opts Options) (*SyslogMessage, error) {
+	r.mu.RLock()
+	parsers := r.parsers
+	r.mu.RUnlock()
+
+	for _, p := range parsers {
+		if !p.Detect(raw) {
+			continue
+		}
+		msg, err := p.Parse(raw, opts)
+		if err != nil {
+			break
+		}
+		msg.SetField("format", p.Name())
+		checkClock(msg, opts)
+		return msg, nil
+	}
+
+	var msg *SyslogMessage
+	var err error
+	if isRFC5424(raw) {
+		msg, err = parseRFC5424(raw, opts)
+	} else {
+		msg, err = parseRFC3164(raw, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	checkClock(msg, opts)
+	return msg, nil
+}
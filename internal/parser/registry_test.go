@@ -0,0 +1,256 @@
+package parser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVendorFormats(t *testing.T) {
+	receivedAt := time.Date(2024, 10, 11, 22, 30, 0, 0, time.UTC)
+	opts := Options{SourceHost: "192.0.2.1", ReceivedAt: receivedAt}
+
+	tests := []struct {
+		name     string
+		input    string
+		format   string
+		hostname string
+		tag      string
+		severity int
+		message  string
+		fields   map[string]interface{}
+	}{
+		{
+			name:     "Cisco IOS with unsynchronized clock",
+			input:    "<189>52: *Mar  1 00:03:12.911: %SYS-5-CONFIG_I: Configured from console by console",
+			format:   "cisco",
+			hostname: "192.0.2.1",
+			tag:      "SYS-5-CONFIG_I",
+			severity: 5,
+			message:  "Configured from console by console",
+			fields: map[string]interface{}{
+				"facility": "SYS", "mnemonic": "CONFIG_I", "sequence": int64(52), "clock_unsynced": true,
+			},
+		},
+		{
+			name:     "Cisco IOS with origin hostname",
+			input:    "<187>1234: core-sw1: Oct 11 22:14:15.123 UTC: %LINEPROTO-5-UPDOWN: Line protocol on Interface Gi1/0/1, changed state to down",
+			format:   "cisco",
+			hostname: "core-sw1",
+			tag:      "LINEPROTO-5-UPDOWN",
+			severity: 5,
+			message:  "Line protocol on Interface Gi1/0/1, changed state to down",
+			fields:   map[string]interface{}{"facility": "LINEPROTO", "sequence": int64(1234)},
+		},
+		{
+			name:     "Cisco ASA",
+			input:    "<166>Oct 11 2024 22:14:15 asa01 : %ASA-6-302013: Built inbound TCP connection 1",
+			format:   "cisco",
+			hostname: "asa01",
+			tag:      "ASA-6-302013",
+			severity: 6,
+			message:  "Built inbound TCP connection 1",
+			fields:   map[string]interface{}{"facility": "ASA", "mnemonic": "302013"},
+		},
+		{
+			name: "FortiGate traffic log",
+			input: `<189>date=2024-10-11 time=22:14:15 devname="fw01" devid="FGT60FTK1234" eventtime=1728684855000000000 ` +
+				`logid="0000000013" type="traffic" subtype="forward" level="notice" srcip=10.0.0.5 dstport=443 sentbyte=5120 action="accept" msg="traffic allowed"`,
+			format:   "fortinet",
+			hostname: "fw01",
+			tag:      "traffic",
+			severity: 5,
+			message:  "traffic allowed",
+			fields: map[string]interface{}{
+				"logid": "0000000013", "srcip": "10.0.0.5", "dstport": int64(443), "sentbyte": int64(5120), "subtype": "forward",
+			},
+		},
+		{
+			name: "Palo Alto traffic log",
+			input: "<14>Oct 11 22:14:15 PA-VM 1,2024/10/11 22:14:15,012801012345,TRAFFIC,end,2560,2024/10/11 22:14:14," +
+				"10.0.0.5,8.8.8.8,0.0.0.0,0.0.0.0,allow-dns,,,dns,vsys1,trust,untrust,ethernet1/2,ethernet1/1,default,,33421,1,52100,53,0,0,0x19,udp,allow,180,80,100,2",
+			format:   "paloalto",
+			hostname: "PA-VM",
+			tag:      "TRAFFIC",
+			severity: 6,
+			message:  "TRAFFIC end allow 10.0.0.5:52100 -> 8.8.8.8:53 dns",
+			fields: map[string]interface{}{
+				"serial": "012801012345", "src": "10.0.0.5", "dport": int64(53), "rule": "allow-dns", "bytes": int64(180),
+			},
+		},
+		{
+			name: "Palo Alto threat log",
+			input: "<12>Oct 11 22:14:15 PA-VM 1,2024/10/11 22:14:15,012801012345,THREAT,vulnerability,2560,2024/10/11 22:14:14," +
+				"10.0.0.5,203.0.113.9,0.0.0.0,0.0.0.0,block-vulns,,,web-browsing,vsys1,trust,untrust,ethernet1/2,ethernet1/1,default,,33422,1,52101,80,0,0,0x2000,tcp,reset-both," +
+				`"evil.example/x",Apache Struts RCE(33441),any,critical,client-to-server`,
+			format:   "paloalto",
+			hostname: "PA-VM",
+			tag:      "THREAT",
+			severity: 2,
+			message:  "THREAT vulnerability reset-both 10.0.0.5:52101 -> 203.0.113.9:80 web-browsing evil.example/x",
+			fields:   map[string]interface{}{"threatid": "Apache Struts RCE(33441)", "direction": "client-to-server"},
+		},
+		{
+			name: "Juniper structured data",
+			input: `<14>1 2024-10-11T22:14:15.003Z srx01 RT_FLOW - RT_FLOW_SESSION_CREATE [junos@2636.1.1.1.2.40 ` +
+				`source-address="10.0.0.5" source-port="52100" destination-port="443" policy-name="allow-web"] session created`,
+			format:   "juniper",
+			hostname: "srx01",
+			tag:      "RT_FLOW",
+			severity: 6,
+			message:  "session created",
+			fields: map[string]interface{}{
+				"event": "RT_FLOW_SESSION_CREATE", "source-address": "10.0.0.5", "destination-port": int64(443), "policy-name": "allow-web",
+			},
+		},
+		{
+			name:     "Juniper BSD format",
+			input:    "<189>Oct 11 22:14:15 mx01 mgd[4242]: UI_COMMIT: User 'admin' requested 'commit' operation",
+			format:   "juniper",
+			hostname: "mx01",
+			tag:      "mgd",
+			severity: 5,
+			message:  "User 'admin' requested 'commit' operation",
+			fields:   map[string]interface{}{"event": "UI_COMMIT"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseWithOptions(tt.input, opts)
+			if err != nil {
+				t.Fatalf("ParseWithOptions() error = %v", err)
+			}
+			if got.Fields["format"] != tt.format {
+				t.Errorf("format = %v, want %v", got.Fields["format"], tt.format)
+			}
+			if got.Hostname != tt.hostname {
+				t.Errorf("Hostname = %v, want %v", got.Hostname, tt.hostname)
+			}
+			if got.Tag != tt.tag {
+				t.Errorf("Tag = %v, want %v", got.Tag, tt.tag)
+			}
+			if got.Severity != tt.severity {
+				t.Errorf("Severity = %v, want %v", got.Severity, tt.severity)
+			}
+			if got.Message != tt.message {
+				t.Errorf("Message = %v, want %v", got.Message, tt.message)
+			}
+			for key, want := range tt.fields {
+				if got.Fields[key] != want {
+					t.Errorf("Fields[%s] = %#v, want %#v", key, got.Fields[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestVendorTimestamps(t *testing.T) {
+	opts := Options{ReceivedAt: time.Date(2024, 10, 11, 22, 30, 0, 0, time.UTC)}
+
+	cisco, _ := ParseWithOptions("<189>1234: Oct 11 22:14:15.123 UTC: %SYS-5-CONFIG_I: Configured", opts)
+	if want := time.Date(2024, 10, 11, 22, 14, 15, 123000000, time.UTC); !cisco.Timestamp.Equal(want) {
+		t.Errorf("Cisco Timestamp = %v, want %v", cisco.Timestamp, want)
+	}
+
+	forti, _ := ParseWithOptions(`<189>date=2024-10-11 time=22:14:15 tz="+0200" devname="fw01" devid="FGT60F" logid="0000000013" type="event"`, opts)
+	if want := time.Date(2024, 10, 11, 20, 14, 15, 0, time.UTC); !forti.Timestamp.Equal(want) {
+		t.Errorf("FortiGate Timestamp = %v, want %v", forti.Timestamp, want)
+	}
+}
+
+func TestRegistryFallback(t *testing.T) {
+	// A message mentioning a Cisco-like code but not in Cisco format is parsed as RFC 3164
+	msg, err := Parse("<34>Oct 11 22:14:15 mymachine app: forwarded %SYS-5-CONFIG_I: text")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if msg.Hostname != "mymachine" || msg.Tag != "app" || msg.Fields["format"] != nil {
+		t.Errorf("Parse() = %+v, want plain RFC 3164", msg)
+	}
+
+	registry := NewRegistry()
+	if got, _ := registry.Parse("<189>52: *Mar  1 00:03:12: %SYS-5-CONFIG_I: x", Options{}); got != nil && got.Fields["format"] != nil {
+		t.Errorf("empty registry applied format %v", got.Fields["format"])
+	}
+}
+
+func TestCEFAndLEEF(t *testing.T) {
+	opts := Options{SourceHost: "192.0.2.1", ReceivedAt: time.Date(2024, 10, 11, 22, 30, 0, 0, time.UTC)}
+
+	cef, err := ParseWithOptions(`<134>Oct 11 22:14:15 ids01 CEF:0|Security|threatmanager|1.0|100|worm successfully stopped|10|`+
+		`src=10.0.0.1 dst=2.1.2.2 spt=1232 msg=Detected a threat. No action needed\= path C:\\temp cs1Label=policy cs1=block all rt=1728684855000`, opts)
+	if err != nil {
+		t.Fatalf("ParseWithOptions(CEF) error = %v", err)
+	}
+	if cef.Fields["format"] != "cef" || cef.Hostname != "ids01" || cef.Tag != "threatmanager" || cef.Severity != 2 {
+		t.Errorf("CEF = %+v", cef)
+	}
+	if cef.Message != "worm successfully stopped: Detected a threat. No action needed= path C:\\temp" {
+		t.Errorf("CEF Message = %q", cef.Message)
+	}
+	wantFields := map[string]interface{}{
+		"device_vendor": "Security", "signature_id": "100", "cef_severity": "10",
+		"src": "10.0.0.1", "spt": int64(1232), "cs1": "block all", "policy": "block all",
+	}
+	for key, want := range wantFields {
+		if cef.Fields[key] != want {
+			t.Errorf("CEF Fields[%s] = %#v, want %#v", key, cef.Fields[key], want)
+		}
+	}
+	if !cef.Timestamp.Equal(time.UnixMilli(1728684855000)) {
+		t.Errorf("CEF Timestamp = %v", cef.Timestamp)
+	}
+
+	// Escaped pipes in the header
+	escaped, _ := ParseWithOptions(`<134>CEF:0|Vendor|Prod\|uct|1.0|7|Name|Medium|`, opts)
+	if escaped.Fields["device_product"] != "Prod|uct" || escaped.Severity != 4 || escaped.Hostname != "192.0.2.1" {
+		t.Errorf("escaped CEF = %+v", escaped)
+	}
+
+	leef, err := ParseWithOptions("<13>Oct 11 22:14:15 qradar LEEF:1.0|IBM|QRadar|7.5|Login|src=10.0.0.1\tusrName=alice\tsev=8", opts)
+	if err != nil {
+		t.Fatalf("ParseWithOptions(LEEF 1.0) error = %v", err)
+	}
+	if leef.Fields["format"] != "leef" || leef.Fields["usrName"] != "alice" || leef.Fields["event_id"] != "Login" || leef.Severity != 3 {
+		t.Errorf("LEEF 1.0 = %+v", leef)
+	}
+
+	leef2, err := ParseWithOptions("<13>LEEF:2.0|Lancope|StealthWatch|1.0|41|^|src=10.0.1.8^dst=10.0.0.5^proto=6", opts)
+	if err != nil {
+		t.Fatalf("ParseWithOptions(LEEF 2.0) error = %v", err)
+	}
+	if leef2.Fields["dst"] != "10.0.0.5" || leef2.Fields["proto"] != int64(6) {
+		t.Errorf("LEEF 2.0 fields = %v", leef2.Fields)
+	}
+}
+
+func TestCEFHeaderPriority(t *testing.T) {
+	// Extension keys and labels cannot overwrite the header fields
+	cef, err := ParseWithOptions(`<134>Oct 11 22:14:15 ids01 CEF:0|Security|threatmanager|1.0|100|worm stopped|10|`+
+		`device_vendor=Evil cef_severity=0 name=spoofed format=syslog cs1Label=signature_id cs1=999 src=10.0.0.1`, Options{})
+	if err != nil {
+		t.Fatalf("ParseWithOptions(CEF) error = %v", err)
+	}
+	wantFields := map[string]interface{}{
+		"device_vendor": "Security", "cef_severity": "10", "name": "worm stopped", "signature_id": "100", "format": "cef",
+		"ext_device_vendor": "Evil", "ext_cef_severity": int64(0), "ext_name": "spoofed", "ext_format": "syslog",
+		"ext_signature_id": int64(999), "src": "10.0.0.1",
+	}
+	for key, want := range wantFields {
+		if cef.Fields[key] != want {
+			t.Errorf("CEF Fields[%s] = %#v, want %#v", key, cef.Fields[key], want)
+		}
+	}
+	if cef.Severity != 2 {
+		t.Errorf("CEF Severity = %d, want 2 from the header", cef.Severity)
+	}
+
+	leef, err := ParseWithOptions("<13>LEEF:1.0|IBM|QRadar|7.5|Login|event_id=Logout\tdevice_product=Other\tusrName=alice", Options{})
+	if err != nil {
+		t.Fatalf("ParseWithOptions(LEEF) error = %v", err)
+	}
+	if leef.Fields["event_id"] != "Login" || leef.Fields["device_product"] != "QRadar" ||
+		leef.Fields["ext_event_id"] != "Logout" || leef.Fields["ext_device_product"] != "Other" {
+		t.Errorf("LEEF fields = %v", leef.Fields)
+	}
+}
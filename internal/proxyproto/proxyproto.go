@@ -0,0 +1,186 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// v1MaxLength is the longest v1 header, CRLF included
+const v1MaxLength = 107
+
+// v2Signature starts every v2 header
+var v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// Header is a decoded PROXY protocol header
+// Source and Destination are nil when the proxy sent no addresses (LOCAL or UNKNOWN
+// connections, such as its own health checks); the connection's own addresses apply
+type Header struct {
+	Version     int
+	Source      net.Addr
+	Destination net.Addr
+}
+
+// Read reads a PROXY protocol header, v1 (text) or v2 (binary), from the start of a stream
+func Read(r *bufio.Reader) (*Header, error) {
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	switch first[0] {
+	case 'P':
+		return readV1(r)
+	case '\r':
+		return readV2(r)
+	default:
+		return nil, fmt.Errorf("missing PROXY protocol header")
+	}
+}
+
+// readV1 reads "PROXY TCP4 192.0.2.10 198.51.100.1 51514 514\r\n"
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for len(line) < v1MaxLength {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PROXY v1 header: %w", err)
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("invalid PROXY v1 header: no CRLF within %d bytes", v1MaxLength)
+	}
+
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+	if parts[0] != "PROXY" || len(parts) < 2 {
+		return nil, fmt.Errorf("invalid PROXY v1 header: %q", line)
+	}
+
+	header := &Header{Version: 1}
+	switch parts[1] {
+	case "UNKNOWN":
+		return header, nil
+	case "TCP4", "TCP6":
+	default:
+		return nil, fmt.Errorf("invalid PROXY v1 header: unsupported protocol %s", parts[1])
+	}
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("invalid PROXY v1 header: %q", line)
+	}
+
+	source, err := parseV1Address(parts[2], parts[4], parts[1])
+	if err != nil {
+		return nil, err
+	}
+	destination, err := parseV1Address(parts[3], parts[5], parts[1])
+	if err != nil {
+		return nil, err
+	}
+	header.Source, header.Destination = source, destination
+	return header, nil
+}
+
+func parseV1Address(host, port, protocol string) (*net.TCPAddr, error) {
+	ip := net.ParseIP(host)
+	if ip == nil || (protocol == "TCP4") != (ip.To4() != nil) {
+		return nil, fmt.Errorf("invalid PROXY v1 header: bad %s address %q", protocol, host)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PROXY v1 header: bad port %q", port)
+	}
+	return &net.TCPAddr{IP: ip, Port: int(p)}, nil
+}
+
+// readV2 reads a binary header: signature, version and command, family and
+// protocol, address length, addresses and optional TLVs (skipped)
+func readV2(r *bufio.Reader) (*Header, error) {
+	fixed := make([]byte, 16)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("failed to read PROXY v2 header: %w", err)
+	}
+	if !bytes.Equal(fixed[:12], v2Signature) {
+		return nil, fmt.Errorf("invalid PROXY v2 header: bad signature")
+	}
+	if fixed[12]>>4 != 2 {
+		return nil, fmt.Errorf("invalid PROXY v2 header: unsupported version %d", fixed[12]>>4)
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint16(fixed[14:16]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("failed to read PROXY v2 addresses: %w", err)
+	}
+
+	header := &Header{Version: 2}
+	switch command := fixed[12] & 0x0f; command {
+	case 0x0: // LOCAL
+		return header, nil
+	case 0x1: // PROXY
+	default:
+		return nil, fmt.Errorf("invalid PROXY v2 header: unsupported command %d", command)
+	}
+
+	var size int
+	switch family := fixed[13] >> 4; family {
+	case 0x1: // AF_INET
+		size = net.IPv4len
+	case 0x2: // AF_INET6
+		size = net.IPv6len
+	default:
+		// AF_UNSPEC or AF_UNIX: no usable address
+		return header, nil
+	}
+	if len(payload) < 2*size+4 {
+		return nil, fmt.Errorf("invalid PROXY v2 header: address block too short")
+	}
+
+	header.Source = &net.TCPAddr{
+		IP:   net.IP(payload[:size]),
+		Port: int(binary.BigEndian.Uint16(payload[2*size:])),
+	}
+	header.Destination = &net.TCPAddr{
+		IP:   net.IP(payload[size : 2*size]),
+		Port: int(binary.BigEndian.Uint16(payload[2*size+2:])),
+	}
+	return header, nil
+}
+
+// Conn is a connection whose remote address is the client announced by the proxy
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+	remote net.Addr
+}
+
+// NewConn wraps a connection whose header was read from reader; buffered data is
+// read before the connection. A nil remote keeps the connection's own address
+func NewConn(conn net.Conn, reader *bufio.Reader, remote net.Addr) *Conn {
+	if remote == nil {
+		remote = conn.RemoteAddr()
+	}
+	return &Conn{Conn: conn, reader: reader, remote: remote}
+}
+
+// Read reads from the buffer first, then from the connection
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// RemoteAddr returns the client address announced by the proxy
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// ProxyAddr returns the address of the proxy itself
+func (c *Conn) ProxyAddr() net.Addr {
+	return c.Conn.RemoteAddr()
+}
@@ -0,0 +1,144 @@
+package proxyproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// v2Header builds a binary header with the given command, family and address block
+func v2Header(command, family byte, addresses []byte) string {
+	header := append([]byte{}, v2Signature...)
+	header = append(header, 0x20|command, family<<4|0x1, 0, 0)
+	binary.BigEndian.PutUint16(header[14:], uint16(len(addresses)))
+	return string(append(header, addresses...))
+}
+
+func TestRead(t *testing.T) {
+	ipv4 := []byte{192, 0, 2, 10, 198, 51, 100, 1, 0xc9, 0x3a, 0x02, 0x02}
+	ipv6 := make([]byte, 36)
+	copy(ipv6, net.ParseIP("2001:db8::10"))
+	copy(ipv6[16:], net.ParseIP("2001:db8::1"))
+	binary.BigEndian.PutUint16(ipv6[32:], 51514)
+	binary.BigEndian.PutUint16(ipv6[34:], 514)
+
+	tests := []struct {
+		name       string
+		input      string
+		wantSource string
+		wantErr    bool
+	}{
+		{
+			name:       "v1 TCP4",
+			input:      "PROXY TCP4 192.0.2.10 198.51.100.1 51514 514\r\n",
+			wantSource: "192.0.2.10:51514",
+		},
+		{
+			name:       "v1 TCP6",
+			input:      "PROXY TCP6 2001:db8::10 2001:db8::1 51514 514\r\n",
+			wantSource: "[2001:db8::10]:51514",
+		},
+		{
+			name:  "v1 UNKNOWN",
+			input: "PROXY UNKNOWN\r\n",
+		},
+		{
+			name:    "v1 address of the wrong family",
+			input:   "PROXY TCP4 2001:db8::10 198.51.100.1 51514 514\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "v1 without CRLF",
+			input:   "PROXY TCP4 192.0.2.10 198.51.100.1 51514 514" + strings.Repeat(" ", 100),
+			wantErr: true,
+		},
+		{
+			name:       "v2 IPv4",
+			input:      v2Header(0x1, 0x1, ipv4),
+			wantSource: "192.0.2.10:51514",
+		},
+		{
+			name:       "v2 IPv4 with TLVs",
+			input:      v2Header(0x1, 0x1, append(append([]byte{}, ipv4...), 0x04, 0x00, 0x01, 0xff)),
+			wantSource: "192.0.2.10:51514",
+		},
+		{
+			name:       "v2 IPv6",
+			input:      v2Header(0x1, 0x2, ipv6),
+			wantSource: "[2001:db8::10]:51514",
+		},
+		{
+			name:  "v2 LOCAL",
+			input: v2Header(0x0, 0x0, nil),
+		},
+		{
+			name:    "v2 truncated addresses",
+			input:   v2Header(0x1, 0x1, ipv4[:6]),
+			wantErr: true,
+		},
+		{
+			name:    "no header",
+			input:   "<13>Oct 11 22:14:15 host app: direct\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(strings.NewReader(tt.input + "<13>message"))
+			header, err := Read(reader)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			source := ""
+			if header.Source != nil {
+				source = header.Source.String()
+			}
+			if source != tt.wantSource {
+				t.Errorf("Source = %q, want %q", source, tt.wantSource)
+			}
+
+			rest, _ := io.ReadAll(reader)
+			if string(rest) != "<13>message" {
+				t.Errorf("data after header = %q, want %q", rest, "<13>message")
+			}
+		})
+	}
+}
+
+func TestConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	go client.Write([]byte("PROXY TCP4 192.0.2.10 198.51.100.1 51514 514\r\nhello"))
+
+	reader := bufio.NewReader(server)
+	header, err := Read(reader)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	conn := NewConn(server, reader, header.Source)
+
+	if got := conn.RemoteAddr().String(); got != "192.0.2.10:51514" {
+		t.Errorf("RemoteAddr() = %q, want 192.0.2.10:51514", got)
+	}
+	if got := conn.ProxyAddr(); got != server.RemoteAddr() {
+		t.Errorf("ProxyAddr() = %v, want %v", got, server.RemoteAddr())
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "hello" {
+		t.Errorf("Read() = %q, %v, want hello", buf, err)
+	}
+
+	local := NewConn(server, reader, nil)
+	if local.RemoteAddr() != server.RemoteAddr() {
+		t.Errorf("RemoteAddr() without a source = %v, want the connection's", local.RemoteAddr())
+	}
+}
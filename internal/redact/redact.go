@@ -0,0 +1,373 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"syslog-visualizer/internal/parser"
+)
+
+// Redaction modes
+const (
+	ModeMask = "mask" // Replace the value with the rule's replacement
+	ModeHash = "hash" // Replace the value with a keyed hash, equal for equal values
+	ModeDrop = "drop" // Remove the value; fields left empty are removed
+)
+
+// Raw message handling
+const (
+	RawRedact = "redact" // Apply the rules to the raw message too (default)
+	RawDrop   = "drop"   // Do not store the raw message
+	RawKeep   = "keep"   // Store the raw message as received
+)
+
+// DefaultReplacement replaces masked values
+const DefaultReplacement = "[REDACTED]"
+
+// Config holds the redaction rules
+type Config struct {
+	Rules   []Rule `yaml:"rules"`
+	Raw     string `yaml:"raw"`      // "redact" (default), "drop" or "keep"
+	HashKey string `yaml:"hash_key"` // Secret key of the hash mode
+}
+
+// Rule finds sensitive values with a built-in detector or a regular expression
+// When the expression has a capture group, only the first group is redacted
+type Rule struct {
+	Name        string   `yaml:"name"`
+	Detector    string   `yaml:"detector"`    // email, ipv4, ipv6, credit_card or bearer_token
+	Pattern     string   `yaml:"pattern"`     // Regular expression, instead of a detector
+	Mode        string   `yaml:"mode"`        // "mask" (default), "hash" or "drop"
+	Replacement string   `yaml:"replacement"` // Replacement of the mask mode (default [REDACTED])
+	Fields      []string `yaml:"fields"`      // Only these fields and not the message (default: the message and every field)
+}
+
+// detector is a built-in rule: an expression and an optional check of its matches
+type detector struct {
+	pattern string
+	valid   func(string) bool
+}
+
+var detectors = map[string]detector{
+	"email": {pattern: `[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`},
+	"ipv4":  {pattern: `\b(?:(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9][0-9]|[1-9]?[0-9])\b`},
+	"ipv6": {
+		pattern: `[0-9A-Fa-f]{0,4}:[0-9A-Fa-f:]*:[0-9A-Fa-f.]*`,
+		valid: func(s string) bool {
+			return net.ParseIP(s) != nil
+		},
+	},
+	"credit_card":  {pattern: `\b[0-9](?:[ \-]?[0-9]){12,18}\b`, valid: luhn},
+	"bearer_token": {pattern: `(?i)\bbearer\s+([A-Za-z0-9\-._~+/]+=*)`},
+}
+
+// Detectors returns the names of the built-in detectors
+func Detectors() []string {
+	names := make([]string, 0, len(detectors))
+	for name := range detectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// luhn checks the digits of a card number, ignoring separators
+func luhn(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n >= 13 && sum%10 == 0
+}
+
+// compiledRule is a rule with its expression
+type compiledRule struct {
+	Rule
+	re     *regexp.Regexp
+	valid  func(string) bool
+	fields map[string]bool
+	count  *atomic.Int64
+}
+
+// RuleSet is a compiled configuration, applied by Set
+type RuleSet struct {
+	rules   []*compiledRule
+	raw     string
+	hashKey []byte
+}
+
+// Redactor removes sensitive values from messages before they are stored
+type Redactor struct {
+	rules atomic.Pointer[RuleSet]
+
+	mu     sync.Mutex
+	counts map[string]*atomic.Int64 // Redactions per rule name, kept across reloads
+}
+
+// New compiles the rules of the configuration
+func New(cfg Config) (*Redactor, error) {
+	r := &Redactor{counts: make(map[string]*atomic.Int64)}
+	if err := r.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate checks the rules without applying them
+func (cfg Config) Validate() error {
+	_, err := Compile(cfg)
+	return err
+}
+
+// Reload replaces the rules; nothing changes when one of them is invalid
+func (r *Redactor) Reload(cfg Config) error {
+	set, err := Compile(cfg)
+	if err != nil {
+		return err
+	}
+	r.Set(set)
+	return nil
+}
+
+// Set replaces the rules with compiled ones
+// Counts are kept for the rules whose name remains
+func (r *Redactor) Set(set *RuleSet) {
+	r.mu.Lock()
+	for _, rule := range set.rules {
+		count, ok := r.counts[rule.Name]
+		if !ok {
+			count = &atomic.Int64{}
+			r.counts[rule.Name] = count
+		}
+		rule.count = count
+	}
+	r.mu.Unlock()
+
+	r.rules.Store(set)
+}
+
+// Compile compiles the rules of the configuration without applying them
+func Compile(cfg Config) (*RuleSet, error) {
+	set := &RuleSet{raw: strings.ToLower(cfg.Raw), hashKey: []byte(cfg.HashKey)}
+	switch set.raw {
+	case "":
+		set.raw = RawRedact
+	case RawRedact, RawDrop, RawKeep:
+	default:
+		return nil, fmt.Errorf("unsupported raw handling: %s", cfg.Raw)
+	}
+
+	names := make(map[string]bool)
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" {
+			rule.Name = rule.Detector
+		}
+		if rule.Name == "" {
+			return nil, fmt.Errorf("redaction rule %d: name is required", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate redaction rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		compiled := &compiledRule{Rule: rule}
+		pattern := rule.Pattern
+		switch {
+		case rule.Detector != "" && rule.Pattern != "":
+			return nil, fmt.Errorf("redaction rule %s: detector and pattern are exclusive", rule.Name)
+		case rule.Detector != "":
+			d, ok := detectors[rule.Detector]
+			if !ok {
+				return nil, fmt.Errorf("redaction rule %s: unknown detector %s (available: %s)",
+					rule.Name, rule.Detector, strings.Join(Detectors(), ", "))
+			}
+			pattern, compiled.valid = d.pattern, d.valid
+		case rule.Pattern == "":
+			return nil, fmt.Errorf("redaction rule %s: detector or pattern is required", rule.Name)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redaction rule %s: %w", rule.Name, err)
+		}
+		compiled.re = re
+
+		compiled.Mode = strings.ToLower(rule.Mode)
+		switch compiled.Mode {
+		case "":
+			compiled.Mode = ModeMask
+		case ModeMask, ModeDrop:
+		case ModeHash:
+			if cfg.HashKey == "" {
+				return nil, fmt.Errorf("redaction rule %s: hash mode needs hash_key", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("redaction rule %s: unsupported mode: %s", rule.Name, rule.Mode)
+		}
+		if compiled.Replacement == "" {
+			compiled.Replacement = DefaultReplacement
+		}
+
+		if len(rule.Fields) > 0 {
+			compiled.fields = make(map[string]bool, len(rule.Fields))
+			for _, field := range rule.Fields {
+				compiled.fields[field] = true
+			}
+		}
+
+		set.rules = append(set.rules, compiled)
+	}
+	return set, nil
+}
+
+// Apply redacts the message text, its fields and its raw form
+func (r *Redactor) Apply(msg *parser.SyslogMessage) {
+	set := r.rules.Load()
+	msg.Raw = set.redactRaw(msg.Raw)
+	if len(set.rules) == 0 {
+		return
+	}
+
+	msg.Message = set.redactText(msg.Message, "")
+	for key, value := range msg.Fields {
+		redacted, keep := set.redactValue(value, key)
+		if keep {
+			msg.Fields[key] = redacted
+		} else {
+			delete(msg.Fields, key)
+		}
+	}
+}
+
+// Raw returns a raw message as it may be stored, e.g. for rejected messages
+func (r *Redactor) Raw(raw string) string {
+	return r.rules.Load().redactRaw(raw)
+}
+
+// redactRaw applies the raw handling
+// Every rule applies to the raw message, which holds the fields too; these
+// redactions are not counted, those of the message and fields already are
+func (set *RuleSet) redactRaw(raw string) string {
+	switch set.raw {
+	case RawDrop:
+		return ""
+	case RawRedact:
+		for _, rule := range set.rules {
+			raw = set.replace(rule, raw, false)
+		}
+	}
+	return raw
+}
+
+// redactValue redacts a field value, descending into objects and arrays; it reports
+// false when nothing is left of the value
+func (set *RuleSet) redactValue(value interface{}, field string) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		redacted := set.redactText(v, field)
+		return redacted, redacted != "" || v == ""
+	case map[string]interface{}:
+		for key, nested := range v {
+			redacted, keep := set.redactValue(nested, field+"."+key)
+			if keep {
+				v[key] = redacted
+			} else {
+				delete(v, key)
+			}
+		}
+		return v, true
+	case []interface{}:
+		kept := v[:0]
+		for _, nested := range v {
+			if redacted, keep := set.redactValue(nested, field); keep {
+				kept = append(kept, redacted)
+			}
+		}
+		return kept, true
+	default:
+		return value, true
+	}
+}
+
+// redactText applies the rules to the message text (field "") or a field value
+func (set *RuleSet) redactText(s, field string) string {
+	for _, rule := range set.rules {
+		if rule.fields != nil && !rule.fields[field] {
+			continue
+		}
+		s = set.replace(rule, s, true)
+	}
+	return s
+}
+
+// replace redacts the matches of a rule, or their first group
+func (set *RuleSet) replace(rule *compiledRule, s string, count bool) string {
+	matches := rule.re.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		value := s[start:end]
+		if rule.valid != nil && !rule.valid(value) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(set.replacement(rule, value))
+		last = end
+		if count {
+			rule.count.Add(1)
+		}
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func (set *RuleSet) replacement(rule *compiledRule, value string) string {
+	switch rule.Mode {
+	case ModeHash:
+		mac := hmac.New(sha256.New, set.hashKey)
+		mac.Write([]byte(value))
+		return rule.Name + ":" + hex.EncodeToString(mac.Sum(nil))[:16]
+	case ModeDrop:
+		return ""
+	default:
+		return rule.Replacement
+	}
+}
+
+// Counts returns the number of redactions per rule
+func (r *Redactor) Counts() map[string]int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]int64, len(r.counts))
+	for name, count := range r.counts {
+		counts[name] = count.Load()
+	}
+	return counts
+}
@@ -0,0 +1,341 @@
+package routing
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/pkg/syslog"
+)
+
+// StorageTarget is the route target of the database, next to the forwarding outputs
+const StorageTarget = "storage"
+
+// Config holds the ordered routing rules
+type Config struct {
+	Rules []Rule `yaml:"rules"`
+}
+
+// Rule acts on the messages matching its conditions
+// Its actions apply in this order: drop, sample, rewrite, set fields, route, stop
+type Rule struct {
+	Name     string            `yaml:"name"`
+	Match    Match             `yaml:"match"`
+	Drop     bool              `yaml:"drop"`     // Discard the message
+	Sample   int               `yaml:"sample"`   // Keep 1 in N messages, discard the others
+	Severity string            `yaml:"severity"` // Rewrite the severity (e.g. "notice")
+	Facility string            `yaml:"facility"` // Rewrite the facility (e.g. "local0")
+	Fields   map[string]string `yaml:"fields"`   // Set these fields
+	Outputs  []string          `yaml:"outputs"`  // Send only to these outputs ("storage" for the database)
+	Stop     bool              `yaml:"stop"`     // Skip the rules that follow
+}
+
+// Match selects messages; empty conditions match everything
+// Hostnames, tags and listeners accept shell patterns such as "web-*"
+type Match struct {
+	Hostnames   []string `yaml:"hostnames"`
+	Facilities  []string `yaml:"facilities"`
+	Severities  []string `yaml:"severities"`
+	MinSeverity string   `yaml:"min_severity"` // e.g. "warning" matches warning and more severe
+	Tags        []string `yaml:"tags"`
+	Listeners   []string `yaml:"listeners"`
+	Message     string   `yaml:"message"` // Regular expression on the message text
+}
+
+// RuleStats reports the counters of a rule
+type RuleStats struct {
+	Matched int64 `json:"matched"`
+	Dropped int64 `json:"dropped"` // Dropped or sampled out
+}
+
+// Decision is the outcome of the rules for a message
+type Decision struct {
+	Drop      bool     // The message is discarded
+	DroppedBy string   // Rule that discarded the message
+	Targets   []string // Outputs and storage to send to; nil for all of them
+}
+
+// Sends reports whether the message goes to a target ("storage" or an output name)
+func (d Decision) Sends(target string) bool {
+	if d.Drop {
+		return false
+	}
+	if d.Targets == nil {
+		return true
+	}
+	for _, t := range d.Targets {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
+
+// compiledRule is a rule with its lookups and counters
+type compiledRule struct {
+	Rule
+	maxSeverity int
+	severities  map[int]bool
+	facilities  map[int]bool
+	message     *regexp.Regexp
+	severity    int // Rewritten severity, -1 to keep it
+	facility    int // Rewritten facility, -1 to keep it
+	stats       *counters
+}
+
+// counters are the counters of a rule name, kept across reloads
+type counters struct {
+	matched atomic.Int64
+	dropped atomic.Int64
+}
+
+// RuleSet is a compiled configuration, applied by Set
+type RuleSet struct {
+	rules []*compiledRule
+}
+
+// Router applies the routing rules to the received messages
+type Router struct {
+	rules atomic.Pointer[RuleSet]
+
+	mu    sync.Mutex
+	stats map[string]*counters
+}
+
+// New compiles the rules of the configuration
+func New(cfg Config) (*Router, error) {
+	r := &Router{stats: make(map[string]*counters)}
+	if err := r.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Validate checks the rules without applying them
+func (cfg Config) Validate() error {
+	_, err := Compile(cfg)
+	return err
+}
+
+// Targets returns the output names the rules route to, "storage" included
+func (cfg Config) Targets() []string {
+	seen := make(map[string]bool)
+	var targets []string
+	for _, rule := range cfg.Rules {
+		for _, target := range rule.Outputs {
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+	sort.Strings(targets)
+	return targets
+}
+
+// Reload replaces the rules; nothing changes when one of them is invalid
+func (r *Router) Reload(cfg Config) error {
+	set, err := Compile(cfg)
+	if err != nil {
+		return err
+	}
+	r.Set(set)
+	return nil
+}
+
+// Set replaces the rules with compiled ones
+// Counters are kept for the rules whose name remains
+func (r *Router) Set(set *RuleSet) {
+	r.mu.Lock()
+	for _, rule := range set.rules {
+		stats, ok := r.stats[rule.Name]
+		if !ok {
+			stats = &counters{}
+			r.stats[rule.Name] = stats
+		}
+		rule.stats = stats
+	}
+	r.mu.Unlock()
+
+	r.rules.Store(set)
+}
+
+// Compile compiles the rules of the configuration without applying them
+func Compile(cfg Config) (*RuleSet, error) {
+	rules := make([]*compiledRule, 0, len(cfg.Rules))
+	names := make(map[string]bool)
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" {
+			return nil, fmt.Errorf("routing rule %d: name is required", i)
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate routing rule name: %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		compiled, err := compileRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("routing rule %s: %w", rule.Name, err)
+		}
+		rules = append(rules, compiled)
+	}
+	return &RuleSet{rules: rules}, nil
+}
+
+func compileRule(rule Rule) (*compiledRule, error) {
+	compiled := &compiledRule{Rule: rule, maxSeverity: syslog.SeverityDebug, severity: -1, facility: -1}
+	m := rule.Match
+
+	for _, patterns := range [][]string{m.Hostnames, m.Tags, m.Listeners} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	if m.MinSeverity != "" {
+		severity, ok := syslog.ParseSeverity(m.MinSeverity)
+		if !ok {
+			return nil, fmt.Errorf("invalid min_severity: %s", m.MinSeverity)
+		}
+		compiled.maxSeverity = severity
+	}
+	if len(m.Severities) > 0 {
+		compiled.severities = make(map[int]bool, len(m.Severities))
+		for _, name := range m.Severities {
+			severity, ok := syslog.ParseSeverity(name)
+			if !ok {
+				return nil, fmt.Errorf("invalid severity: %s", name)
+			}
+			compiled.severities[severity] = true
+		}
+	}
+	if len(m.Facilities) > 0 {
+		compiled.facilities = make(map[int]bool, len(m.Facilities))
+		for _, name := range m.Facilities {
+			facility, ok := syslog.ParseFacility(name)
+			if !ok {
+				return nil, fmt.Errorf("invalid facility: %s", name)
+			}
+			compiled.facilities[facility] = true
+		}
+	}
+	if m.Message != "" {
+		re, err := regexp.Compile(m.Message)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message expression: %w", err)
+		}
+		compiled.message = re
+	}
+
+	if rule.Sample < 0 {
+		return nil, fmt.Errorf("sample must not be negative")
+	}
+	if rule.Severity != "" {
+		severity, ok := syslog.ParseSeverity(rule.Severity)
+		if !ok {
+			return nil, fmt.Errorf("invalid severity: %s", rule.Severity)
+		}
+		compiled.severity = severity
+	}
+	if rule.Facility != "" {
+		facility, ok := syslog.ParseFacility(rule.Facility)
+		if !ok {
+			return nil, fmt.Errorf("invalid facility: %s", rule.Facility)
+		}
+		compiled.facility = facility
+	}
+	for _, target := range rule.Outputs {
+		if strings.TrimSpace(target) == "" {
+			return nil, fmt.Errorf("empty output name")
+		}
+	}
+	if rule.Outputs != nil && len(rule.Outputs) == 0 {
+		return nil, fmt.Errorf("outputs must name at least one output (use drop to discard)")
+	}
+	return compiled, nil
+}
+
+// Apply runs the rules on the message, rewriting it, and decides where it goes
+func (r *Router) Apply(msg *parser.SyslogMessage) Decision {
+	var decision Decision
+	for _, rule := range r.rules.Load().rules {
+		if !rule.matches(msg) {
+			continue
+		}
+		n := rule.stats.matched.Add(1)
+
+		// Sampling keeps the first of every N matching messages
+		if rule.Drop || (rule.Sample > 1 && (n-1)%int64(rule.Sample) != 0) {
+			rule.stats.dropped.Add(1)
+			return Decision{Drop: true, DroppedBy: rule.Name}
+		}
+		if rule.severity >= 0 {
+			msg.Severity = rule.severity
+		}
+		if rule.facility >= 0 {
+			msg.Facility = rule.facility
+		}
+		for key, value := range rule.Fields {
+			msg.SetField(key, value)
+		}
+		if len(rule.Outputs) > 0 {
+			decision.Targets = rule.Outputs
+		}
+		if rule.Stop {
+			break
+		}
+	}
+	return decision
+}
+
+// matches reports whether the rule's conditions accept the message
+func (rule *compiledRule) matches(msg *parser.SyslogMessage) bool {
+	if msg.Severity > rule.maxSeverity {
+		return false
+	}
+	if rule.severities != nil && !rule.severities[msg.Severity] {
+		return false
+	}
+	if rule.facilities != nil && !rule.facilities[msg.Facility] {
+		return false
+	}
+	if !matchAny(rule.Match.Hostnames, msg.Hostname) ||
+		!matchAny(rule.Match.Tags, msg.Tag) ||
+		!matchAny(rule.Match.Listeners, msg.Listener) {
+		return false
+	}
+	if rule.message != nil && !rule.message.MatchString(msg.Message) {
+		return false
+	}
+	return true
+}
+
+// matchAny reports whether a value matches one of the patterns; no pattern matches all
+func matchAny(patterns []string, value string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats returns the counters of every rule
+func (r *Router) Stats() map[string]RuleStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stats := make(map[string]RuleStats, len(r.stats))
+	for name, c := range r.stats {
+		stats[name] = RuleStats{Matched: c.matched.Load(), Dropped: c.dropped.Load()}
+	}
+	return stats
+}
@@ -0,0 +1,169 @@
+package routing
+
+import (
+	"reflect"
+	"testing"
+
+	"syslog-visualizer/internal/parser"
+	"syslog-visualizer/pkg/syslog"
+)
+
+func TestApply(t *testing.T) {
+	r, err := New(Config{Rules: []Rule{
+		{Name: "drop-debug", Match: Match{Severities: []string{"debug"}, Hostnames: []string{"web-*"}}, Drop: true},
+		{Name: "auth", Match: Match{Facilities: []string{"auth"}}, Outputs: []string{"siem", StorageTarget}},
+		{Name: "quiet-cron", Match: Match{Tags: []string{"CRON"}, Message: `^\(root\) CMD`}, Severity: "debug", Fields: map[string]string{"team": "ops"}, Stop: true},
+		{Name: "after-stop", Match: Match{Tags: []string{"CRON"}}, Drop: true},
+		{Name: "relp-only", Match: Match{Listeners: []string{"relp"}}, Outputs: []string{"archive"}},
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		msg          parser.SyslogMessage
+		wantDrop     bool
+		wantTargets  []string
+		wantSeverity int
+		wantFields   map[string]interface{}
+	}{
+		{
+			name:         "debug from web host dropped",
+			msg:          parser.SyslogMessage{Hostname: "web-1", Severity: syslog.SeverityDebug},
+			wantDrop:     true,
+			wantSeverity: syslog.SeverityDebug,
+		},
+		{
+			name:         "debug from other host kept",
+			msg:          parser.SyslogMessage{Hostname: "db-1", Severity: syslog.SeverityDebug},
+			wantSeverity: syslog.SeverityDebug,
+		},
+		{
+			name:         "auth routed",
+			msg:          parser.SyslogMessage{Hostname: "db-1", Facility: syslog.FacilityAuth, Severity: syslog.SeverityInfo},
+			wantTargets:  []string{"siem", StorageTarget},
+			wantSeverity: syslog.SeverityInfo,
+		},
+		{
+			name:         "cron rewritten and stopped",
+			msg:          parser.SyslogMessage{Tag: "CRON", Message: "(root) CMD (run-parts)", Severity: syslog.SeverityInfo},
+			wantSeverity: syslog.SeverityDebug,
+			wantFields:   map[string]interface{}{"team": "ops"},
+		},
+		{
+			name:         "other cron message reaches later rule",
+			msg:          parser.SyslogMessage{Tag: "CRON", Message: "session opened", Severity: syslog.SeverityInfo},
+			wantDrop:     true,
+			wantSeverity: syslog.SeverityInfo,
+		},
+		{
+			name:         "listener match",
+			msg:          parser.SyslogMessage{Listener: "relp", Severity: syslog.SeverityInfo},
+			wantTargets:  []string{"archive"},
+			wantSeverity: syslog.SeverityInfo,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := tt.msg
+			decision := r.Apply(&msg)
+			if decision.Drop != tt.wantDrop {
+				t.Errorf("Drop = %v, want %v", decision.Drop, tt.wantDrop)
+			}
+			if !reflect.DeepEqual(decision.Targets, tt.wantTargets) {
+				t.Errorf("Targets = %v, want %v", decision.Targets, tt.wantTargets)
+			}
+			if msg.Severity != tt.wantSeverity {
+				t.Errorf("Severity = %d, want %d", msg.Severity, tt.wantSeverity)
+			}
+			if !reflect.DeepEqual(msg.Fields, tt.wantFields) {
+				t.Errorf("Fields = %v, want %v", msg.Fields, tt.wantFields)
+			}
+		})
+	}
+
+	stats := r.Stats()
+	if got := stats["drop-debug"]; got != (RuleStats{Matched: 1, Dropped: 1}) {
+		t.Errorf("drop-debug stats = %+v, want 1 matched, 1 dropped", got)
+	}
+	if got := stats["after-stop"]; got != (RuleStats{Matched: 1, Dropped: 1}) {
+		t.Errorf("after-stop stats = %+v, want 1 matched, 1 dropped", got)
+	}
+}
+
+func TestSample(t *testing.T) {
+	r, err := New(Config{Rules: []Rule{{Name: "sample", Match: Match{MinSeverity: "info"}, Sample: 10}}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	kept := 0
+	for i := 0; i < 100; i++ {
+		if !r.Apply(&parser.SyslogMessage{Severity: syslog.SeverityInfo}).Drop {
+			kept++
+		}
+	}
+	if kept != 10 {
+		t.Errorf("kept = %d, want 10", kept)
+	}
+	if r.Apply(&parser.SyslogMessage{Severity: syslog.SeverityDebug}).Drop {
+		t.Errorf("debug message dropped by a rule matching info and more severe")
+	}
+
+	// Counters survive a reload that keeps the rule
+	if err := r.Reload(Config{Rules: []Rule{{Name: "sample", Drop: true}}}); err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+	if got := r.Stats()["sample"].Matched; got != 100 {
+		t.Errorf("matched after reload = %d, want 100", got)
+	}
+}
+
+func TestDecisionSends(t *testing.T) {
+	tests := []struct {
+		decision Decision
+		target   string
+		want     bool
+	}{
+		{Decision{}, StorageTarget, true},
+		{Decision{}, "siem", true},
+		{Decision{Drop: true}, StorageTarget, false},
+		{Decision{Targets: []string{"siem"}}, StorageTarget, false},
+		{Decision{Targets: []string{"siem"}}, "siem", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.decision.Sends(tt.target); got != tt.want {
+			t.Errorf("%+v.Sends(%q) = %v, want %v", tt.decision, tt.target, got, tt.want)
+		}
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"missing name", Rule{Drop: true}},
+		{"bad severity", Rule{Name: "r", Match: Match{Severities: []string{"loud"}}}},
+		{"bad facility", Rule{Name: "r", Facility: "local9"}},
+		{"bad expression", Rule{Name: "r", Match: Match{Message: "("}}},
+		{"bad pattern", Rule{Name: "r", Match: Match{Hostnames: []string{"web-["}}}},
+		{"negative sample", Rule{Name: "r", Sample: -1}},
+		{"empty outputs", Rule{Name: "r", Outputs: []string{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := (Config{Rules: []Rule{tt.rule}}).Validate(); err == nil {
+				t.Errorf("Validate() error = nil, want an error")
+			}
+		})
+	}
+
+	if err := (Config{Rules: []Rule{{Name: "r"}, {Name: "r"}}}).Validate(); err == nil {
+		t.Errorf("Validate() with duplicate names error = nil, want an error")
+	}
+}
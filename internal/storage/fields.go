@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Field filter operators
+const (
+	FieldExists       = ""
+	FieldEqual        = "="
+	FieldNotEqual     = "!="
+	FieldGreater      = ">"
+	FieldGreaterEqual = ">="
+	FieldLess         = "<"
+	FieldLessEqual    = "<="
+	FieldContains     = "~"
+)
+
+// fieldOperators are tried in order, so two-character operators come first
+var fieldOperators = []string{FieldGreaterEqual, FieldLessEqual, FieldNotEqual, FieldGreater, FieldLess, FieldEqual, FieldContains}
+
+// fieldName is what a field filter or facet may name: the field names are used in JSON paths
+var fieldName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// ValidFieldName reports whether a structured field name can be filtered or faceted
+func ValidFieldName(name string) bool {
+	return fieldName.MatchString(name)
+}
+
+// fieldPath returns the JSON path of a field, bound as a query parameter
+func fieldPath(name string) string {
+	return `$."` + name + `"`
+}
+
+// FieldFilter matches messages on a structured field, e.g. latency_ms > 500
+type FieldFilter struct {
+	Name  string
+	Op    string
+	Value string
+}
+
+// ParseFieldFilter parses "name", "name=value", "name!=value", "name>value", "name>=value",
+// "name<value", "name<=value" or "name~value" (case-insensitive contains)
+func ParseFieldFilter(expr string) (FieldFilter, error) {
+	name, op, value := expr, FieldExists, ""
+	if i := strings.IndexAny(expr, "=!<>~"); i >= 0 {
+		name = expr[:i]
+		for _, candidate := range fieldOperators {
+			if strings.HasPrefix(expr[i:], candidate) {
+				op = candidate
+				value = expr[i+len(candidate):]
+				break
+			}
+		}
+		if op == FieldExists {
+			return FieldFilter{}, fmt.Errorf("invalid field filter %q: unknown operator", expr)
+		}
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return FieldFilter{}, fmt.Errorf("invalid field filter %q: missing field name", expr)
+	}
+	if !ValidFieldName(name) {
+		return FieldFilter{}, fmt.Errorf("invalid field filter %q: field name may only contain letters, digits, '_', '.' and '-'", expr)
+	}
+
+	filter := FieldFilter{Name: name, Op: op, Value: strings.TrimSpace(value)}
+	switch op {
+	case FieldGreater, FieldGreaterEqual, FieldLess, FieldLessEqual:
+		if _, ok := filter.Number(); !ok {
+			return FieldFilter{}, fmt.Errorf("invalid field filter %q: %s needs a number", expr, op)
+		}
+	}
+	return filter, nil
+}
+
+// Number returns the filter value as a number when it is one
+func (f FieldFilter) Number() (float64, bool) {
+	n, err := strconv.ParseFloat(f.Value, 64)
+	return n, err == nil
+}
@@ -0,0 +1,106 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/parser"
+)
+
+func TestParseFieldFilter(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    FieldFilter
+		wantErr bool
+	}{
+		{expr: "latency_ms>500", want: FieldFilter{Name: "latency_ms", Op: ">", Value: "500"}},
+		{expr: "latency_ms>=500", want: FieldFilter{Name: "latency_ms", Op: ">=", Value: "500"}},
+		{expr: "status!=200", want: FieldFilter{Name: "status", Op: "!=", Value: "200"}},
+		{expr: "http.path=/api", want: FieldFilter{Name: "http.path", Op: "=", Value: "/api"}},
+		{expr: "user~ali", want: FieldFilter{Name: "user", Op: "~", Value: "ali"}},
+		{expr: "user", want: FieldFilter{Name: "user"}},
+		{expr: "latency_ms>slow", wantErr: true},
+		{expr: "=5", wantErr: true},
+		{expr: "http-status.code=200", want: FieldFilter{Name: "http-status.code", Op: "=", Value: "200"}},
+		{expr: `a"b=1`, wantErr: true},
+		{expr: "a'b=1", wantErr: true},
+		{expr: `user')) OR 1=1 --=x`, wantErr: true},
+		{expr: "user name=x", wantErr: true},
+		{expr: "a!5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFieldFilter(tt.expr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseFieldFilter(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ParseFieldFilter(%q) = %+v, want %+v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestQueryFieldFilters(t *testing.T) {
+	store, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now()
+	for i, fields := range []map[string]interface{}{
+		{"user": "alice", "latency_ms": int64(42), "ok": true},
+		{"user": "bob", "latency_ms": int64(900)},
+		{"user": "carol", "latency_ms": "slow"},
+		{},
+	} {
+		msg := &parser.SyslogMessage{Timestamp: now.Add(time.Duration(i) * time.Second), Hostname: "h", Message: "m", Fields: fields}
+		if len(fields) == 0 {
+			msg.Fields = nil
+		}
+		if err := store.Store(msg); err != nil {
+			t.Fatalf("Store: %v", err)
+		}
+	}
+
+	tests := []struct {
+		expr  string
+		count int64
+	}{
+		{"latency_ms>500", 1},
+		{"latency_ms<=42", 1},
+		{"latency_ms=42", 1},
+		{"latency_ms=slow", 1},
+		{"user!=alice", 3},
+		{"user~AL", 1},
+		{"ok=true", 1},
+		{"latency_ms", 3},
+	}
+
+	for _, tt := range tests {
+		filter, err := ParseFieldFilter(tt.expr)
+		if err != nil {
+			t.Fatalf("ParseFieldFilter(%q): %v", tt.expr, err)
+		}
+		_, count, err := store.QueryWithCount(QueryFilters{Fields: []FieldFilter{filter}})
+		if err != nil {
+			t.Fatalf("QueryWithCount(%q): %v", tt.expr, err)
+		}
+		if count != tt.count {
+			t.Errorf("QueryWithCount(%q) count = %d, want %d", tt.expr, count, tt.count)
+		}
+		messages, err := store.Query(QueryFilters{Fields: []FieldFilter{filter}})
+		if err != nil || int64(len(messages)) != tt.count {
+			t.Errorf("Query(%q) = %d messages (%v), want %d", tt.expr, len(messages), err, tt.count)
+		}
+	}
+
+	// A name that ParseFieldFilter refuses must not reach the SQL either
+	injected := FieldFilter{Name: `user"')) OR 1=1 --`, Op: FieldExists}
+	_, count, err := store.QueryWithCount(QueryFilters{Fields: []FieldFilter{injected}})
+	if err != nil || count != 0 {
+		t.Errorf("QueryWithCount(injected name) = %d (%v), want 0", count, err)
+	}
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RejectStore keeps the messages that could not be parsed (dead letters)
+type RejectStore interface {
+	StoreReject(reject *RejectedMessage) error
+	QueryRejects(filters RejectFilters) ([]*RejectedMessage, int64, error)
+	RejectSummary() ([]RejectSummary, error)
+	UpdateRejectError(id uint, parseError string) error
+	DeleteRejects(ids []uint) (int64, error)
+}
+
+// RejectedMessage is a message that could not be parsed
+type RejectedMessage struct {
+	ID         uint      `json:"id"`
+	ReceivedAt time.Time `json:"receivedAt"`
+	Listener   string    `json:"listener"`
+	Protocol   string    `json:"protocol"`
+	Source     string    `json:"source"`
+	Raw        string    `json:"raw"` // Raw bytes as received (may not be valid UTF-8)
+	Error      string    `json:"error"`
+	Attempts   int       `json:"attempts"` // Number of failed reprocessing attempts
+}
+
+// RejectFilters defines filters for querying rejected messages
+type RejectFilters struct {
+	IDs      []uint
+	Listener string
+	Source   string
+	Limit    int
+	Offset   int
+}
+
+// RejectSummary counts rejected messages per listener and source
+type RejectSummary struct {
+	Listener string `json:"listener"`
+	Source   string `json:"source"`
+	Count    int64  `json:"count"`
+}
+
+// RejectedMessageModel is the GORM model for rejected messages
+type RejectedMessageModel struct {
+	ID         uint      `gorm:"primaryKey"`
+	ReceivedAt time.Time `gorm:"index;not null"`
+	Listener   string    `gorm:"index;not null"`
+	Protocol   string    `gorm:"type:text;not null"`
+	Source     string    `gorm:"index;not null"`
+	Raw        []byte    `gorm:"type:blob;not null"`
+	Error      string    `gorm:"type:text;not null"`
+	Attempts   int
+}
+
+// TableName overrides the table name
+func (RejectedMessageModel) TableName() string {
+	return "rejected_messages"
+}
+
+func (m *RejectedMessageModel) toReject() *RejectedMessage {
+	return &RejectedMessage{
+		ID:         m.ID,
+		ReceivedAt: m.ReceivedAt,
+		Listener:   m.Listener,
+		Protocol:   m.Protocol,
+		Source:     m.Source,
+		Raw:        string(m.Raw),
+		Error:      m.Error,
+		Attempts:   m.Attempts,
+	}
+}
+
+// StoreReject stores a message that could not be parsed
+func (s *SQLiteStorage) StoreReject(reject *RejectedMessage) error {
+	model := &RejectedMessageModel{
+		ReceivedAt: reject.ReceivedAt,
+		Listener:   reject.Listener,
+		Protocol:   reject.Protocol,
+		Source:     reject.Source,
+		Raw:        []byte(reject.Raw),
+		Error:      reject.Error,
+	}
+
+	if err := s.db.Create(model).Error; err != nil {
+		return fmt.Errorf("failed to store rejected message: %w", err)
+	}
+	reject.ID = model.ID
+	return nil
+}
+
+// QueryRejects retrieves rejected messages, most recent first, with the total count
+func (s *SQLiteStorage) QueryRejects(filters RejectFilters) ([]*RejectedMessage, int64, error) {
+	applyFilters := func(query *gorm.DB) *gorm.DB {
+		if len(filters.IDs) > 0 {
+			query = query.Where("id IN ?", filters.IDs)
+		}
+		if filters.Listener != "" {
+			query = query.Where("listener = ?", filters.Listener)
+		}
+		if filters.Source != "" {
+			query = query.Where("source = ?", filters.Source)
+		}
+		return query
+	}
+
+	var totalCount int64
+	if err := applyFilters(s.db.Model(&RejectedMessageModel{})).Count(&totalCount).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count rejected messages: %w", err)
+	}
+
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = 1000
+	}
+	dataQuery := applyFilters(s.db.Model(&RejectedMessageModel{})).Order("received_at DESC").Limit(limit)
+	if filters.Offset > 0 {
+		dataQuery = dataQuery.Offset(filters.Offset)
+	}
+
+	var models []RejectedMessageModel
+	if err := dataQuery.Find(&models).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to query rejected messages: %w", err)
+	}
+
+	rejects := make([]*RejectedMessage, len(models))
+	for i := range models {
+		rejects[i] = models[i].toReject()
+	}
+
+	return rejects, totalCount, nil
+}
+
+// RejectSummary counts rejected messages per listener and source, largest first
+func (s *SQLiteStorage) RejectSummary() ([]RejectSummary, error) {
+	var summary []RejectSummary
+	err := s.db.Model(&RejectedMessageModel{}).
+		Select("listener, source, COUNT(*) AS count").
+		Group("listener, source").
+		Order("count DESC").
+		Scan(&summary).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize rejected messages: %w", err)
+	}
+	return summary, nil
+}
+
+// UpdateRejectError records a failed reprocessing attempt
+func (s *SQLiteStorage) UpdateRejectError(id uint, parseError string) error {
+	err := s.db.Model(&RejectedMessageModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"error":    parseError,
+		"attempts": gorm.Expr("attempts + 1"),
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to update rejected message: %w", err)
+	}
+	return nil
+}
+
+// DeleteRejects deletes rejected messages by ID
+func (s *SQLiteStorage) DeleteRejects(ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := s.db.Where("id IN ?", ids).Delete(&RejectedMessageModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete rejected messages: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"syslog-visualizer/internal/diskqueue"
+	"syslog-visualizer/internal/parser"
+)
+
+const (
+	spoolRetryInitial = time.Second
+	spoolRetryMax     = 30 * time.Second
+)
+
+// SpoolStats reports the state of the spool
+type SpoolStats struct {
+	Depth     int    `json:"depth"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Spooled   int64  `json:"spooled"`
+	Replayed  int64  `json:"replayed"`
+	Corrupt   int64  `json:"corrupt"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// SpoolStorage wraps a Storage and keeps messages on disk while it is failing
+// Spooled messages are replayed into the storage once it recovers
+type SpoolStorage struct {
+	Storage
+	queue *diskqueue.Queue
+	sync  bool // Sync acknowledged messages before Store returns
+
+	mu        sync.Mutex
+	spooled   int64
+	replayed  int64
+	corrupt   int64
+	lastError string
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewSpoolStorage opens the spool and starts replaying pending messages into inner
+func NewSpoolStorage(inner Storage, opts diskqueue.Options) (*SpoolStorage, error) {
+	queue, err := diskqueue.Open(opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool: %w", err)
+	}
+
+	s := &SpoolStorage{
+		Storage: inner,
+		queue:   queue,
+		sync:    opts.SyncPolicy != diskqueue.SyncNever,
+		done:    make(chan struct{}),
+	}
+
+	if pending := queue.Len(); pending > 0 {
+		log.Printf("Spool: %d message(s) pending replay", pending)
+	}
+
+	s.wg.Add(1)
+	go s.replayLoop()
+
+	return s, nil
+}
+
+// Store stores the message, spooling it to disk if the storage fails
+// While messages are pending replay, new messages are spooled behind them to keep their order
+// Acknowledged messages are synced to disk before Store returns, unless the sync policy is never
+func (s *SpoolStorage) Store(msg *parser.SyslogMessage) error {
+	if s.queue.Len() == 0 {
+		err := s.Storage.Store(msg)
+		if err == nil {
+			return nil
+		}
+		s.setError(err)
+		log.Printf("Storage failed, spooling message: %v", err)
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	if err := s.queue.Append(data); err != nil {
+		return fmt.Errorf("failed to spool message: %w", err)
+	}
+	if msg.Acknowledged && s.sync {
+		if err := s.queue.Sync(); err != nil {
+			return fmt.Errorf("failed to sync spool: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	s.spooled++
+	s.mu.Unlock()
+	return nil
+}
+
+// Stats returns the spool depth and counters
+func (s *SpoolStorage) Stats() SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return SpoolStats{
+		Depth:     s.queue.Len(),
+		SizeBytes: s.queue.Size(),
+		Spooled:   s.spooled,
+		Replayed:  s.replayed,
+		Corrupt:   s.corrupt + s.queue.Corrupt(),
+		LastError: s.lastError,
+	}
+}
+
+// Close stops the replay, flushes the spool and closes the underlying storage
+func (s *SpoolStorage) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	if err := s.queue.Close(); err != nil {
+		log.Printf("Error closing spool: %v", err)
+	}
+	return s.Storage.Close()
+}
+
+// replayLoop moves spooled messages into the storage, backing off while it fails
+func (s *SpoolStorage) replayLoop() {
+	defer s.wg.Done()
+
+	backoff := spoolRetryInitial
+	for {
+		data, err := s.queue.Peek()
+		if err == diskqueue.ErrEmpty {
+			select {
+			case <-s.queue.Notify():
+			case <-time.After(time.Second):
+			case <-s.done:
+				return
+			}
+			continue
+		}
+
+		if err == nil {
+			err = s.replay(data)
+		}
+
+		if err != nil {
+			s.setError(err)
+			select {
+			case <-time.After(backoff):
+			case <-s.done:
+				return
+			}
+			backoff *= 2
+			if backoff > spoolRetryMax {
+				backoff = spoolRetryMax
+			}
+			continue
+		}
+
+		backoff = spoolRetryInitial
+		select {
+		case <-s.done:
+			return
+		default:
+		}
+	}
+}
+
+// replay stores one spooled record and removes it from the spool
+func (s *SpoolStorage) replay(data []byte) error {
+	var msg parser.SyslogMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		log.Printf("Spool: dropping undecodable record: %v", err)
+		s.mu.Lock()
+		s.corrupt++
+		s.mu.Unlock()
+		return s.queue.Ack()
+	}
+
+	msg.ID = 0
+	if err := s.Storage.Store(&msg); err != nil {
+		return err
+	}
+	if err := s.queue.Ack(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.replayed++
+	if s.queue.Len() == 0 {
+		s.lastError = ""
+		log.Printf("Spool drained: %d message(s) replayed into storage", s.replayed)
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *SpoolStorage) setError(err error) {
+	s.mu.Lock()
+	s.lastError = err.Error()
+	s.mu.Unlock()
+}
@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/diskqueue"
+	"syslog-visualizer/internal/parser"
+)
+
+// flakyStorage fails every Store call while down is set
+type flakyStorage struct {
+	*MemoryStorage
+	mu   sync.Mutex
+	down bool
+}
+
+func (f *flakyStorage) Store(msg *parser.SyslogMessage) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("database is locked")
+	}
+	return f.MemoryStorage.Store(msg)
+}
+
+func (f *flakyStorage) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func (f *flakyStorage) stored() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var messages []string
+	for _, msg := range f.messages {
+		messages = append(messages, msg.Message)
+	}
+	return messages
+}
+
+func TestSpoolReplaysAfterRecovery(t *testing.T) {
+	dir := t.TempDir()
+	inner := &flakyStorage{MemoryStorage: NewMemoryStorage(), down: true}
+
+	spool, err := NewSpoolStorage(inner, diskqueue.Options{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewSpoolStorage() error = %v", err)
+	}
+
+	for _, text := range []string{"one", "two"} {
+		if err := spool.Store(&parser.SyslogMessage{Hostname: "host", Message: text}); err != nil {
+			t.Fatalf("Store() error = %v, want message spooled", err)
+		}
+	}
+	if stats := spool.Stats(); stats.Depth != 2 || stats.LastError == "" {
+		t.Errorf("Stats() = %+v, want depth 2 with last error", stats)
+	}
+
+	// Messages pending replay are stored in order once storage recovers
+	inner.setDown(false)
+	spool.Store(&parser.SyslogMessage{Hostname: "host", Message: "three"})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for spool.Stats().Replayed < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	got := inner.stored()
+	if len(got) != 3 || got[0] != "one" || got[1] != "two" || got[2] != "three" {
+		t.Errorf("stored %v, want [one two three]", got)
+	}
+	if stats := spool.Stats(); stats.Replayed != 3 || stats.LastError != "" {
+		t.Errorf("Stats() = %+v after replay", stats)
+	}
+	spool.Close()
+}
@@ -3,6 +3,7 @@ package storage
 import (
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"gorm.io/driver/sqlite"
@@ -25,7 +26,17 @@ type SyslogMessageModel struct {
 	AppName   string    `gorm:"type:text"`
 	ProcID    string    `gorm:"type:text"`
 	MsgID     string    `gorm:"type:text"`
-	CreatedAt time.Time `gorm:"index;autoCreateTime"`
+	PeerPID   int
+	PeerUID   *int
+	Fields    map[string]interface{} `gorm:"type:text;serializer:json"`
+
+	ReceivedAt    time.Time `gorm:"index"`
+	ParseWarnings []string  `gorm:"type:text;serializer:json"`
+	CreatedAt     time.Time `gorm:"index;autoCreateTime"`
+
+	RepeatCount int `gorm:"not null;default:1"`
+	FirstSeen   time.Time
+	LastSeen    time.Time
 }
 
 // TableName overrides the table name
@@ -33,9 +44,85 @@ func (SyslogMessageModel) TableName() string {
 	return "syslog_messages"
 }
 
+// newMessageModel converts a parsed message to its database model
+func newMessageModel(msg *parser.SyslogMessage) *SyslogMessageModel {
+	model := &SyslogMessageModel{
+		Timestamp: msg.Timestamp,
+		Hostname:  msg.Hostname,
+		Facility:  msg.Facility,
+		Severity:  msg.Severity,
+		Tag:       msg.Tag,
+		Message:   msg.Message,
+		Raw:       msg.Raw,
+		PID:       msg.PID,
+		AppName:   msg.AppName,
+		ProcID:    msg.ProcID,
+		MsgID:     msg.MsgID,
+		PeerPID:   msg.PeerPID,
+		PeerUID:   msg.PeerUID,
+		Fields:    msg.Fields,
+
+		ReceivedAt:    msg.ReceivedAt,
+		ParseWarnings: msg.ParseWarnings,
+
+		RepeatCount: 1,
+		FirstSeen:   msg.Timestamp,
+		LastSeen:    msg.Timestamp,
+	}
+	if msg.RepeatCount > 1 {
+		model.RepeatCount = msg.RepeatCount
+	}
+	if !msg.FirstSeen.IsZero() {
+		model.FirstSeen = msg.FirstSeen
+	}
+	if !msg.LastSeen.IsZero() {
+		model.LastSeen = msg.LastSeen
+	}
+	return model
+}
+
+// toMessage converts a database model back to a parsed message
+func (m *SyslogMessageModel) toMessage() *parser.SyslogMessage {
+	msg := &parser.SyslogMessage{
+		ID:        m.ID,
+		Timestamp: m.Timestamp,
+		Hostname:  m.Hostname,
+		Facility:  m.Facility,
+		Severity:  m.Severity,
+		Tag:       m.Tag,
+		Message:   m.Message,
+		Raw:       m.Raw,
+		PID:       m.PID,
+		AppName:   m.AppName,
+		ProcID:    m.ProcID,
+		MsgID:     m.MsgID,
+		PeerPID:   m.PeerPID,
+		PeerUID:   m.PeerUID,
+		Fields:    m.Fields,
+
+		ReceivedAt:    m.ReceivedAt,
+		ParseWarnings: m.ParseWarnings,
+
+		RepeatCount: m.RepeatCount,
+		FirstSeen:   m.FirstSeen,
+		LastSeen:    m.LastSeen,
+	}
+	// Rows stored before deduplication have no first and last seen times
+	if msg.FirstSeen.IsZero() {
+		msg.FirstSeen = m.Timestamp
+	}
+	if msg.LastSeen.IsZero() {
+		msg.LastSeen = m.Timestamp
+	}
+	return msg
+}
+
 // SQLiteStorage is a SQLite-based storage implementation using GORM
 type SQLiteStorage struct {
 	db *gorm.DB
+
+	mu          sync.RWMutex
+	facetFields []string
 }
 
 // NewSQLiteStorage creates a new SQLite storage with GORM
@@ -67,29 +154,33 @@ func NewSQLiteStorage(dbPath string) (*SQLiteStorage, error) {
 
 // migrate runs GORM auto-migration
 func (s *SQLiteStorage) migrate() error {
-	return s.db.AutoMigrate(&SyslogMessageModel{})
+	return s.db.AutoMigrate(&SyslogMessageModel{}, &RejectedMessageModel{})
 }
 
 // Store stores a syslog message in the database
 func (s *SQLiteStorage) Store(msg *parser.SyslogMessage) error {
-	model := &SyslogMessageModel{
-		Timestamp: msg.Timestamp,
-		Hostname:  msg.Hostname,
-		Facility:  msg.Facility,
-		Severity:  msg.Severity,
-		Tag:       msg.Tag,
-		Message:   msg.Message,
-		Raw:       msg.Raw,
-		PID:       msg.PID,
-		AppName:   msg.AppName,
-		ProcID:    msg.ProcID,
-		MsgID:     msg.MsgID,
-	}
+	model := newMessageModel(msg)
 
 	if err := s.db.Create(model).Error; err != nil {
 		return fmt.Errorf("failed to store message: %w", err)
 	}
 
+	msg.ID = model.ID
+	return nil
+}
+
+// AddRepeats adds repeats of a stored message, the last of them seen at lastSeen
+func (s *SQLiteStorage) AddRepeats(id uint, count int, lastSeen time.Time) error {
+	result := s.db.Model(&SyslogMessageModel{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"repeat_count": gorm.Expr("repeat_count + ?", count),
+		"last_seen":    lastSeen,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("failed to add repeats: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("message not found: %d", id)
+	}
 	return nil
 }
 
@@ -133,13 +224,19 @@ func (s *SQLiteStorage) Query(filters QueryFilters) ([]*parser.SyslogMessage, er
 		query = query.Where("tag = ?", filters.Tag)
 	}
 
-	// Search filter (search in message, tag, and hostname)
+	// Search filter (search in message, tag, hostname and structured fields)
 	if filters.Search != "" {
 		searchPattern := "%" + strings.ToLower(filters.Search) + "%"
-		query = query.Where("LOWER(message) LIKE ? OR LOWER(tag) LIKE ? OR LOWER(hostname) LIKE ?",
-			searchPattern, searchPattern, searchPattern)
+		query = query.Where("LOWER(message) LIKE ? OR LOWER(tag) LIKE ? OR LOWER(hostname) LIKE ? OR LOWER(fields) LIKE ?",
+			searchPattern, searchPattern, searchPattern, searchPattern)
+	}
+
+	if filters.MinRepeats > 1 {
+		query = query.Where("repeat_count >= ?", filters.MinRepeats)
 	}
 
+	query = applyFieldFilters(query, filters.Fields)
+
 	query = query.Order("timestamp DESC")
 
 	limit := filters.Limit
@@ -158,26 +255,54 @@ func (s *SQLiteStorage) Query(filters QueryFilters) ([]*parser.SyslogMessage, er
 	}
 
 	messages := make([]*parser.SyslogMessage, len(models))
-	for i, model := range models {
-		messages[i] = &parser.SyslogMessage{
-			ID:        model.ID,
-			Timestamp: model.Timestamp,
-			Hostname:  model.Hostname,
-			Facility:  model.Facility,
-			Severity:  model.Severity,
-			Tag:       model.Tag,
-			Message:   model.Message,
-			Raw:       model.Raw,
-			PID:       model.PID,
-			AppName:   model.AppName,
-			ProcID:    model.ProcID,
-			MsgID:     model.MsgID,
-		}
+	for i := range models {
+		messages[i] = models[i].toMessage()
 	}
 
 	return messages, nil
 }
 
+// applyFieldFilters adds a condition per field filter, evaluated with SQLite's JSON functions
+// The JSON path is bound as a parameter, never written into the SQL
+func applyFieldFilters(query *gorm.DB, fields []FieldFilter) *gorm.DB {
+	for _, f := range fields {
+		if !ValidFieldName(f.Name) {
+			// ParseFieldFilter refuses these names; match nothing rather than trust them
+			query = query.Where("1 = 0")
+			continue
+		}
+		path := fieldPath(f.Name)
+		value := "json_extract(fields, ?)"
+		kind := "json_type(fields, ?)"
+		number, isNumber := f.Number()
+
+		switch f.Op {
+		case FieldExists:
+			query = query.Where(kind+" IS NOT NULL", path)
+		case FieldEqual, FieldNotEqual:
+			condition, args := value+" = ?", []interface{}{path, f.Value}
+			switch {
+			case f.Value == "true" || f.Value == "false":
+				condition = kind + " = ?"
+			case isNumber:
+				condition, args = value+" IN (?, ?)", []interface{}{path, number, f.Value}
+			}
+			if f.Op == FieldNotEqual {
+				// Messages without the field do not equal the value either
+				condition = kind + " IS NULL OR NOT (" + condition + ")"
+				args = append([]interface{}{path}, args...)
+			}
+			query = query.Where(condition, args...)
+		case FieldGreater, FieldGreaterEqual, FieldLess, FieldLessEqual:
+			// Only numbers compare: SQLite orders any text after every number
+			query = query.Where(kind+" IN ('integer', 'real') AND "+value+" "+f.Op+" ?", path, path, number)
+		case FieldContains:
+			query = query.Where("LOWER(CAST("+value+" AS TEXT)) LIKE ?", path, "%"+strings.ToLower(f.Value)+"%")
+		}
+	}
+	return query
+}
+
 // QueryWithCount retrieves syslog messages with total count based on filters
 func (s *SQLiteStorage) QueryWithCount(filters QueryFilters) ([]*parser.SyslogMessage, int64, error) {
 	countQuery := s.db.Model(&SyslogMessageModel{})
@@ -222,14 +347,18 @@ func (s *SQLiteStorage) QueryWithCount(filters QueryFilters) ([]*parser.SyslogMe
 			query = query.Where("tag = ?", filters.Tag)
 		}
 
-		// Search filter (search in message, tag, and hostname)
+		// Search filter (search in message, tag, hostname and structured fields)
 		if filters.Search != "" {
 			searchPattern := "%" + strings.ToLower(filters.Search) + "%"
-			query = query.Where("LOWER(message) LIKE ? OR LOWER(tag) LIKE ? OR LOWER(hostname) LIKE ?",
-				searchPattern, searchPattern, searchPattern)
+			query = query.Where("LOWER(message) LIKE ? OR LOWER(tag) LIKE ? OR LOWER(hostname) LIKE ? OR LOWER(fields) LIKE ?",
+				searchPattern, searchPattern, searchPattern, searchPattern)
 		}
 
-		return query
+		if filters.MinRepeats > 1 {
+			query = query.Where("repeat_count >= ?", filters.MinRepeats)
+		}
+
+		return applyFieldFilters(query, filters.Fields)
 	}
 
 	countQuery = applyFilters(countQuery)
@@ -258,26 +387,20 @@ func (s *SQLiteStorage) QueryWithCount(filters QueryFilters) ([]*parser.SyslogMe
 	}
 
 	messages := make([]*parser.SyslogMessage, len(models))
-	for i, model := range models {
-		messages[i] = &parser.SyslogMessage{
-			ID:        model.ID,
-			Timestamp: model.Timestamp,
-			Hostname:  model.Hostname,
-			Facility:  model.Facility,
-			Severity:  model.Severity,
-			Tag:       model.Tag,
-			Message:   model.Message,
-			Raw:       model.Raw,
-			PID:       model.PID,
-			AppName:   model.AppName,
-			ProcID:    model.ProcID,
-			MsgID:     model.MsgID,
-		}
+	for i := range models {
+		messages[i] = models[i].toMessage()
 	}
 
 	return messages, totalCount, nil
 }
 
+// SetFacetFields sets the structured fields whose values GetFilterOptions returns
+func (s *SQLiteStorage) SetFacetFields(fields []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.facetFields = fields
+}
+
 // GetFilterOptions returns all unique values for filtering
 func (s *SQLiteStorage) GetFilterOptions() (*FilterOptions, error) {
 	options := &FilterOptions{
@@ -324,6 +447,25 @@ func (s *SQLiteStorage) GetFilterOptions() (*FilterOptions, error) {
 	}
 	options.Severities = severities
 
+	s.mu.RLock()
+	facetFields := s.facetFields
+	s.mu.RUnlock()
+	if len(facetFields) > 0 {
+		options.Fields = make(map[string][]string, len(facetFields))
+	}
+	for _, field := range facetFields {
+		if !ValidFieldName(field) {
+			return nil, fmt.Errorf("invalid facet field name: %q", field)
+		}
+		var values []string
+		if err := s.db.Raw("SELECT DISTINCT json_extract(fields, ?) AS value FROM "+SyslogMessageModel{}.TableName()+
+			" WHERE json_extract(fields, ?) IS NOT NULL ORDER BY value ASC", fieldPath(field), fieldPath(field)).
+			Scan(&values).Error; err != nil {
+			return nil, fmt.Errorf("failed to get values of field %s: %w", field, err)
+		}
+		options.Fields[field] = values
+	}
+
 	return options, nil
 }
 
@@ -396,6 +538,13 @@ func (s *SQLiteStorage) DeleteOlderThan(duration time.Duration) (int64, error) {
 
 	rowsAffected := result.RowsAffected
 
+	// Rejected messages follow the same retention
+	rejected := s.db.Where("received_at < ?", cutoffTime).Delete(&RejectedMessageModel{})
+	if rejected.Error != nil {
+		return rowsAffected, fmt.Errorf("failed to delete old rejected messages: %w", rejected.Error)
+	}
+	rowsAffected += rejected.RowsAffected
+
 	// Vacuum to reclaim space
 	if rowsAffected > 0 {
 		sqlDB, err := s.db.DB()
@@ -419,8 +568,8 @@ func (s *SQLiteStorage) SearchMessages(searchTerm string, limit int) ([]*parser.
 	searchPattern := "%" + strings.ToLower(searchTerm) + "%"
 
 	var models []SyslogMessageModel
-	err := s.db.Where("message LIKE ? OR tag LIKE ? OR hostname LIKE ?",
-		searchPattern, searchPattern, searchPattern).
+	err := s.db.Where("message LIKE ? OR tag LIKE ? OR hostname LIKE ? OR fields LIKE ?",
+		searchPattern, searchPattern, searchPattern, searchPattern).
 		Order("timestamp DESC").
 		Limit(limit).
 		Find(&models).Error
@@ -430,21 +579,8 @@ func (s *SQLiteStorage) SearchMessages(searchTerm string, limit int) ([]*parser.
 	}
 
 	messages := make([]*parser.SyslogMessage, len(models))
-	for i, model := range models {
-		messages[i] = &parser.SyslogMessage{
-			ID:        model.ID,
-			Timestamp: model.Timestamp,
-			Hostname:  model.Hostname,
-			Facility:  model.Facility,
-			Severity:  model.Severity,
-			Tag:       model.Tag,
-			Message:   model.Message,
-			Raw:       model.Raw,
-			PID:       model.PID,
-			AppName:   model.AppName,
-			ProcID:    model.ProcID,
-			MsgID:     model.MsgID,
-		}
+	for i := range models {
+		messages[i] = models[i].toMessage()
 	}
 
 	return messages, nil
@@ -0,0 +1,93 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"syslog-visualizer/internal/parser"
+)
+
+func TestAddRepeats(t *testing.T) {
+	store, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	flapping := &parser.SyslogMessage{Timestamp: now, Hostname: "sw1", Tag: "ifmgr", Message: "ge-0/0/1 down", Raw: "x"}
+	single := &parser.SyslogMessage{Timestamp: now, Hostname: "sw1", Tag: "ifmgr", Message: "ge-0/0/2 down", Raw: "x"}
+	for _, msg := range []*parser.SyslogMessage{flapping, single} {
+		if err := store.Store(msg); err != nil {
+			t.Fatalf("Store: %v", err)
+		}
+	}
+
+	lastSeen := now.Add(time.Minute)
+	if err := store.AddRepeats(flapping.ID, 41, lastSeen); err != nil {
+		t.Fatalf("AddRepeats: %v", err)
+	}
+	if err := store.AddRepeats(9999, 1, lastSeen); err == nil {
+		t.Error("AddRepeats to a missing message succeeded, want error")
+	}
+
+	messages, total, err := store.QueryWithCount(QueryFilters{MinRepeats: 2})
+	if err != nil {
+		t.Fatalf("QueryWithCount: %v", err)
+	}
+	if total != 1 || len(messages) != 1 {
+		t.Fatalf("QueryWithCount(MinRepeats: 2) returned %d of %d, want the flapping message", len(messages), total)
+	}
+	got := messages[0]
+	if got.RepeatCount != 42 || !got.FirstSeen.Equal(now) || !got.LastSeen.Equal(lastSeen) {
+		t.Errorf("RepeatCount = %d, FirstSeen = %v, LastSeen = %v, want 42 from %v to %v",
+			got.RepeatCount, got.FirstSeen, got.LastSeen, now, lastSeen)
+	}
+}
+
+func TestFacetFields(t *testing.T) {
+	store, err := NewSQLiteStorage(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStorage: %v", err)
+	}
+	defer store.Close()
+	store.SetFacetFields([]string{"asset.owner", "asset.environment"})
+
+	now := time.Now().UTC()
+	for _, fields := range []map[string]interface{}{
+		{"asset.owner": "payments", "asset.environment": "production"},
+		{"asset.owner": "payments", "asset.environment": "staging"},
+		{"asset.owner": "search"},
+		nil,
+	} {
+		msg := &parser.SyslogMessage{Timestamp: now, Hostname: "h", Severity: 3, Message: "error", Raw: "x", Fields: fields}
+		if err := store.Store(msg); err != nil {
+			t.Fatalf("Store: %v", err)
+		}
+	}
+
+	options, err := store.GetFilterOptions()
+	if err != nil {
+		t.Fatalf("GetFilterOptions: %v", err)
+	}
+	want := map[string][]string{
+		"asset.owner":       {"payments", "search"},
+		"asset.environment": {"production", "staging"},
+	}
+	if !reflect.DeepEqual(options.Fields, want) {
+		t.Errorf("Fields = %v, want %v", options.Fields, want)
+	}
+
+	_, total, err := store.QueryWithCount(QueryFilters{Fields: []FieldFilter{
+		{Name: "asset.owner", Op: FieldEqual, Value: "payments"},
+		{Name: "asset.environment", Op: FieldEqual, Value: "production"},
+	}})
+	if err != nil {
+		t.Fatalf("QueryWithCount: %v", err)
+	}
+	if total != 1 {
+		t.Errorf("production messages owned by payments = %d, want 1", total)
+	}
+}
@@ -21,6 +21,9 @@ type FilterOptions struct {
 	Tags       []string `json:"tags"`
 	Facilities []int    `json:"facilities"`
 	Severities []int    `json:"severities"`
+
+	// Fields holds the values of the faceted structured fields (e.g. asset.owner)
+	Fields map[string][]string `json:"fields,omitempty"`
 }
 
 // QueryFilters defines filters for querying syslog messages
@@ -28,13 +31,15 @@ type QueryFilters struct {
 	StartTime  time.Time
 	EndTime    time.Time
 	Hostname   string
-	Hostnames  []string // Multiple hostnames
-	Severity   *int     // Deprecated: use Severities
-	Severities []int    // Multiple severities
-	Facility   *int     // Deprecated: use Facilities
-	Facilities []int    // Multiple facilities
-	Tag        string   // Filter by tag
-	Search     string   // Search term for message content
+	Hostnames  []string      // Multiple hostnames
+	Severity   *int          // Deprecated: use Severities
+	Severities []int         // Multiple severities
+	Facility   *int          // Deprecated: use Facilities
+	Facilities []int         // Multiple facilities
+	Tag        string        // Filter by tag
+	Search     string        // Search term for message content
+	Fields     []FieldFilter // Filters on structured fields, all must match
+	MinRepeats int           // Only messages collapsed from at least this many repeats
 	Limit      int
 	Offset     int
 }
@@ -1,5 +1,10 @@
 package syslog
 
+import (
+	"strconv"
+	"strings"
+)
+
 // Facility codes as defined in RFC 5424
 const (
 	FacilityKern     = 0  // kernel messages
@@ -83,3 +88,46 @@ func SeverityName(severity int) string {
 	}
 	return "unknown"
 }
+
+// ParseFacility returns the facility code for a name (e.g. "local0") or numeric string
+func ParseFacility(s string) (int, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if code, err := strconv.Atoi(s); err == nil {
+		return code, code >= 0 && code <= FacilityLocal7
+	}
+	for code := FacilityKern; code <= FacilityLocal7; code++ {
+		if FacilityName(code) == s {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// ParseSeverity returns the severity code for a name (e.g. "warning") or numeric string
+// Common aliases such as "warn", "err" and "crit" are accepted
+func ParseSeverity(s string) (int, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if code, err := strconv.Atoi(s); err == nil {
+		return code, code >= SeverityEmergency && code <= SeverityDebug
+	}
+	aliases := map[string]int{
+		"emerg":         SeverityEmergency,
+		"panic":         SeverityEmergency,
+		"fatal":         SeverityCritical,
+		"crit":          SeverityCritical,
+		"err":           SeverityError,
+		"warn":          SeverityWarning,
+		"informational": SeverityInfo,
+		"information":   SeverityInfo,
+		"trace":         SeverityDebug,
+	}
+	if code, ok := aliases[s]; ok {
+		return code, true
+	}
+	for code := SeverityEmergency; code <= SeverityDebug; code++ {
+		if SeverityName(code) == s {
+			return code, true
+		}
+	}
+	return 0, false
+}